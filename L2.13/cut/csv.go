@@ -0,0 +1,84 @@
+package cut
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// csvComma проверяет, что разделитель CSV — ровно один допустимый символ
+func csvComma(option, delim string) (rune, error) {
+	r, size := utf8.DecodeRuneInString(delim)
+	if size == 0 || size != len(delim) || r == utf8.RuneError || r == '"' || r == '\r' || r == '\n' {
+		return 0, &OptionError{Option: option, Reason: "csv delimiter must be a single character: " + strconv.Quote(delim)}
+	}
+	return r, nil
+}
+
+// resolveHeader заменяет имена колонок в списке их номерами из заголовка.
+// Элемент, совпадающий с именем колонки, считается именем, даже если похож на диапазон
+func resolveHeader(spec string, header []string) (string, error) {
+	index := make(map[string]int, len(header))
+	for i, name := range header {
+		if _, ok := index[name]; !ok { // при повторах имён берётся первая колонка
+			index[name] = i + 1
+		}
+	}
+
+	parts := strings.Split(spec, ",")
+	for i, part := range parts {
+		if n, ok := index[part]; ok {
+			parts[i] = strconv.Itoa(n)
+			continue
+		}
+		if _, err := parseRanges(part); err != nil {
+			return "", &SpecError{Spec: spec, Part: part, Reason: "unknown column"}
+		}
+	}
+	return strings.Join(parts, ","), nil
+}
+
+// cutCSV читает записи CSV, выбирает поля и выводит их в out с повторным экранированием
+func (c *Cutter) cutCSV(reader io.Reader, out io.Writer) error {
+	r := csv.NewReader(reader)
+	r.Comma = c.csvComma
+	r.FieldsPerRecord = -1 // число полей в записях может различаться, как и в обычном режиме
+
+	w := csv.NewWriter(out)
+	w.Comma = c.csvOutput
+
+	sel := c.sel
+	for first := true; ; first = false {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		if first && c.opts.Header { // заголовок у каждого потока свой
+			spec, err := resolveHeader(c.spec, record)
+			if err != nil {
+				return err
+			}
+			if sel, err = ParseSelector(spec); err != nil {
+				return err
+			}
+		}
+
+		if c.opts.Separated && len(record) == 1 {
+			continue
+		}
+
+		if err := w.Write(c.selectFields(record, sel)); err != nil {
+			return err
+		}
+	}
+
+	w.Flush()
+	return w.Error()
+}
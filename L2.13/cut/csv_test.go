@@ -0,0 +1,89 @@
+package cut
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestResolveHeader(t *testing.T) {
+	header := []string{"id", "name", "e-mail", "2024", "name"}
+
+	tests := []struct {
+		spec     string
+		expected string
+		hasErr   bool
+	}{
+		{"name,id", "2,1", false},
+		{"e-mail", "3", false},     // имя с дефисом не считается диапазоном
+		{"2024,5-", "4,5-", false}, // имя важнее номера
+		{"1,name", "1,2", false},
+		{"phone", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := resolveHeader(tt.spec, header)
+		if tt.hasErr {
+			if err == nil {
+				t.Errorf("expected error for %q", tt.spec)
+			}
+			continue
+		}
+		if err != nil || got != tt.expected {
+			t.Errorf("resolveHeader(%q) = %q, %v; expected %q", tt.spec, got, err, tt.expected)
+		}
+	}
+}
+
+func TestCutCSVQuoting(t *testing.T) {
+	input := "a,\"b,1\",c\n\"x\ny\",\"say \"\"hi\"\"\",z\n"
+	expected := "\"b,1\",a\n\"say \"\"hi\"\"\",\"x\ny\"\n"
+
+	out := cutString(t, "2,1", Options{CSV: true, Ordered: true}, input)
+	if out != expected {
+		t.Errorf("expected %q, got %q", expected, out)
+	}
+}
+
+func TestCutCSVHeader(t *testing.T) {
+	input := "name,email,age\n\"Doe, John\",john@example.com,42\n"
+	expected := "name,email\n\"Doe, John\",john@example.com\n"
+
+	out := cutString(t, "email,name", Options{CSV: true, Header: true}, input)
+	if out != expected {
+		t.Errorf("expected %q, got %q", expected, out)
+	}
+}
+
+func TestCutTSVToCSV(t *testing.T) {
+	input := "a\tb,c\td\n"
+	expected := "a,\"b,c\"\n"
+
+	opts := Options{Delimiter: "\t", CSV: true, OutputDelimiter: ",", HasOutputDelimiter: true}
+	out := cutString(t, "1-2", opts, input)
+	if out != expected {
+		t.Errorf("expected %q, got %q", expected, out)
+	}
+}
+
+func TestCutCSVErrors(t *testing.T) {
+	c, err := New("1", Options{CSV: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var parseErr *csv.ParseError
+	if err := c.Cut(strings.NewReader("\"unterminated\n"), &bytes.Buffer{}); !errors.As(err, &parseErr) {
+		t.Errorf("expected *csv.ParseError for unterminated quote, got %v", err)
+	}
+
+	c, err = New("phone", Options{CSV: true, Header: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var specErr *SpecError
+	if err := c.Cut(strings.NewReader("name\nx\n"), &bytes.Buffer{}); !errors.As(err, &specErr) || specErr.Reason != "unknown column" {
+		t.Errorf("expected unknown column error, got %v", err)
+	}
+}
@@ -0,0 +1,346 @@
+// Package cut выбирает поля, байты или символы из записей потока, как утилита cut
+package cut
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Mode — что именно выбирает список: поля, байты или символы
+type Mode int
+
+const (
+	ModeFields Mode = iota // -f
+	ModeBytes              // -b
+	ModeChars              // -c
+)
+
+// Options — параметры обработки. Нулевое значение: поля через табуляцию, записи через '\n'
+type Options struct {
+	Mode       Mode           // режим выбора
+	Delimiter  string         // разделитель полей, может состоять из нескольких символов; пусто — табуляция (для CSV — ',')
+	DelimRegex *regexp.Regexp // разделитель-регулярное выражение вместо Delimiter
+	Separated  bool           // пропускать записи без разделителя (-s)
+	Complement bool           // выводить все позиции, кроме перечисленных
+	NoSplit    bool           // не разрывать многобайтовые символы в режиме байтов (-n)
+	Ordered    bool           // выводить поля в порядке списка, с повторами: "3,1,3"
+	CSV        bool           // разбор по RFC 4180 с повторным экранированием на выводе
+	Header     bool           // первая запись CSV — заголовок, список может содержать имена колонок
+	Zero       bool           // записи разделяются NUL, а не переводом строки (-z)
+
+	OutputDelimiter    string // разделитель на выводе
+	HasOutputDelimiter bool   // задан ли OutputDelimiter явно (пустая строка — допустимое значение)
+}
+
+// Cutter преобразует поток записей по списку позиций. Безопасен для одновременного использования
+type Cutter struct {
+	opts      Options
+	spec      string    // исходный список: с Header разбирается заново для каждого потока
+	sel       *Selector // nil, если список зависит от заголовка
+	outDelim  string    // итоговый разделитель полей на выводе
+	csvComma  rune      // разделитель CSV на вводе
+	csvOutput rune      // разделитель CSV на выводе
+}
+
+// New проверяет параметры и разбирает список позиций
+func New(spec string, opts Options) (*Cutter, error) {
+	if err := validate(opts); err != nil {
+		return nil, err
+	}
+
+	c := &Cutter{opts: opts, spec: spec}
+
+	if c.opts.Delimiter == "" {
+		c.opts.Delimiter = "\t"
+		if opts.CSV {
+			c.opts.Delimiter = ","
+		}
+	}
+
+	switch {
+	case opts.HasOutputDelimiter:
+		c.outDelim = opts.OutputDelimiter
+	case opts.DelimRegex != nil:
+		c.outDelim = " " // совпадения регулярного выражения разные, выводим через пробел
+	default:
+		c.outDelim = c.opts.Delimiter
+	}
+
+	if opts.CSV {
+		var err error
+		if c.csvComma, err = csvComma("Delimiter", c.opts.Delimiter); err != nil {
+			return nil, err
+		}
+		if c.csvOutput, err = csvComma("OutputDelimiter", c.outDelim); err != nil {
+			return nil, err
+		}
+	}
+
+	if !opts.Header { // с Header список разбирается после чтения заголовка
+		sel, err := ParseSelector(spec)
+		if err != nil {
+			return nil, err
+		}
+		c.sel = sel
+	}
+	return c, nil
+}
+
+// validate проверяет сочетание параметров
+func validate(opts Options) error {
+	fieldsOnly := opts.Delimiter != "" || opts.DelimRegex != nil || opts.Separated ||
+		opts.Ordered || opts.CSV
+
+	switch {
+	case opts.Mode < ModeFields || opts.Mode > ModeChars:
+		return &OptionError{Option: "Mode", Reason: "unknown mode"}
+	case opts.Mode != ModeFields && fieldsOnly:
+		return &OptionError{Option: "Mode", Reason: "delimiter, separated, ordered and csv options are only valid when operating on fields"}
+	case opts.Ordered && opts.Complement:
+		return &OptionError{Option: "Ordered", Reason: "ordered output is not compatible with complement"}
+	case opts.CSV && opts.DelimRegex != nil:
+		return &OptionError{Option: "CSV", Reason: "csv mode does not support regex delimiters"}
+	case opts.CSV && opts.Zero:
+		return &OptionError{Option: "Zero", Reason: "csv mode does not support zero-terminated records"}
+	case opts.Header && !opts.CSV:
+		return &OptionError{Option: "Header", Reason: "header is only valid in csv mode"}
+	case opts.DelimRegex != nil && opts.DelimRegex.MatchString(""):
+		return &OptionError{Option: "DelimRegex", Reason: "regex delimiter must not match an empty string"}
+	}
+	return nil
+}
+
+// Cut читает записи из r и пишет выбранные позиции в w через буфер
+func (c *Cutter) Cut(r io.Reader, w io.Writer) error {
+	bw, ok := w.(*bufio.Writer)
+	if !ok {
+		bw = bufio.NewWriterSize(w, 64*1024)
+	}
+
+	var err error
+	if c.opts.CSV {
+		err = c.cutCSV(r, bw)
+	} else {
+		err = c.cutRecords(r, bw)
+	}
+
+	if ferr := bw.Flush(); err == nil {
+		err = ferr
+	}
+	return err
+}
+
+// cutRecords обрабатывает поток записей, разделённых '\n' или NUL
+func (c *Cutter) cutRecords(r io.Reader, w *bufio.Writer) error {
+	rr := newRecordReader(r, c.terminator())
+	var out []byte // буфер результата переиспользуется между записями
+	for {
+		line, err := rr.next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		out = c.appendRecord(out[:0], line)
+		if _, err := w.Write(out); err != nil {
+			return err
+		}
+	}
+}
+
+// terminator возвращает разделитель записей на вводе и выводе
+func (c *Cutter) terminator() byte {
+	if c.opts.Zero {
+		return 0
+	}
+	return '\n'
+}
+
+// appendRecord обрабатывает одну запись и дописывает результат вместе с терминатором в dst.
+// Если запись пропускается (Separated без разделителя), dst возвращается без изменений
+func (c *Cutter) appendRecord(dst, line []byte) []byte {
+	switch c.opts.Mode {
+	case ModeBytes:
+		dst = c.cutBytes(dst, line)
+	case ModeChars:
+		dst = c.cutChars(dst, line)
+	default:
+		var ok bool
+		if dst, ok = c.cutFields(dst, line); !ok {
+			return dst
+		}
+	}
+	return append(dst, c.terminator())
+}
+
+// splitFields делит строку на поля по строке-разделителю или регулярному выражению
+func (c *Cutter) splitFields(line string) []string {
+	if c.opts.DelimRegex != nil {
+		return c.opts.DelimRegex.Split(line, -1)
+	}
+	return strings.Split(line, c.opts.Delimiter)
+}
+
+// indexDelim ищет разделитель в записи; однобайтовый разделитель ищется быстрее
+func indexDelim(line []byte, delim string) int {
+	if len(delim) == 1 {
+		return bytes.IndexByte(line, delim[0])
+	}
+	return bytes.Index(line, []byte(delim))
+}
+
+// cutFields дописывает в dst выбранные поля записи; второй результат false, если запись нужно пропустить.
+// Для строкового разделителя поля перебираются прямо в записи без выделения []string
+func (c *Cutter) cutFields(dst, line []byte) ([]byte, bool) {
+	if c.opts.DelimRegex != nil || c.opts.Ordered { // общий путь через срез полей
+		cols := c.splitFields(string(line))
+		if c.opts.Separated && len(cols) == 1 { // если разделителя нет то игнорируем
+			return dst, false
+		}
+		for i, col := range c.selectFields(cols, c.sel) {
+			if i > 0 {
+				dst = append(dst, c.outDelim...)
+			}
+			dst = append(dst, col...)
+		}
+		return dst, true
+	}
+
+	fields := c.sel.ranges
+	complement := c.opts.Complement
+
+	delim := c.opts.Delimiter
+	j := indexDelim(line, delim)
+	if j < 0 { // разделителя нет: вся запись — первое поле
+		if c.opts.Separated {
+			return dst, false
+		}
+		if c.sel.Contains(1) != complement {
+			dst = append(dst, line...)
+		}
+		return dst, true
+	}
+
+	k := 0 // текущий диапазон: номера полей только растут, поэтому двоичный поиск не нужен
+	written := false
+	for i := 1; ; i++ {
+		for k < len(fields) && fields[k].Hi < i {
+			k++
+		}
+		if k == len(fields) && !complement { // дальше выбранных полей нет
+			break
+		}
+
+		col := line
+		if j >= 0 {
+			col = line[:j]
+		}
+
+		if (k < len(fields) && fields[k].Lo <= i) != complement { // при Complement выбор инвертируется
+			if written {
+				dst = append(dst, c.outDelim...)
+			}
+			dst = append(dst, col...)
+			written = true
+		}
+
+		if j < 0 {
+			break
+		}
+		line = line[j+len(delim):]
+		j = indexDelim(line, delim)
+	}
+	return dst, true // если нет подходящих полей — выводим пустую строку
+}
+
+// selectFields возвращает выбранные поля в порядке вывода
+func (c *Cutter) selectFields(cols []string, sel *Selector) []string {
+	var out []string
+
+	if c.opts.Ordered { // порядок и повторы как в списке, открытые диапазоны обрезаются по длине строки
+		for _, r := range sel.order {
+			for i := r.Lo; i <= min(r.Hi, len(cols)); i++ {
+				out = append(out, cols[i-1])
+			}
+		}
+		return out
+	}
+
+	for i := 1; i <= len(cols); i++ {
+		if sel.Contains(i) != c.opts.Complement { // при Complement выбор инвертируется
+			out = append(out, cols[i-1])
+		}
+	}
+	return out
+}
+
+// cutBytes дописывает в dst выбранные байты записи.
+// С NoSplit многобайтовый символ выводится целиком, если выбран хотя бы один его байт
+// и все байты после первого выбранного тоже выбраны, иначе пропускается целиком.
+// Явный OutputDelimiter вставляется между несмежными выбранными участками
+func (c *Cutter) cutBytes(dst, line []byte) []byte {
+	last := 0 // позиция последнего выведенного байта
+	for i := 0; i < len(line); {
+		size := 1
+		if c.opts.NoSplit {
+			_, size = utf8.DecodeRune(line[i:])
+		}
+
+		if c.charSelected(i+1, size) {
+			if c.opts.HasOutputDelimiter && last > 0 && last != i {
+				dst = append(dst, c.opts.OutputDelimiter...)
+			}
+			dst = append(dst, line[i:i+size]...)
+			last = i + size
+		}
+		i += size
+	}
+	return dst
+}
+
+// charSelected проверяет байты pos..pos+size-1 одного символа по правилу NoSplit
+func (c *Cutter) charSelected(pos, size int) bool {
+	first := -1
+	for k := 0; k < size; k++ {
+		if c.sel.Contains(pos+k) != c.opts.Complement {
+			first = k
+			break
+		}
+	}
+	if first < 0 {
+		return false
+	}
+	for k := first + 1; k < size; k++ {
+		if c.sel.Contains(pos+k) == c.opts.Complement {
+			return false
+		}
+	}
+	return true
+}
+
+// cutChars дописывает в dst выбранные символы записи. Некорректный UTF-8 байт считается одним символом.
+// Явный OutputDelimiter вставляется между несмежными выбранными участками
+func (c *Cutter) cutChars(dst, line []byte) []byte {
+	pos, last := 0, 0 // номер текущего и последнего выведенного символа
+	for i := 0; i < len(line); {
+		_, size := utf8.DecodeRune(line[i:])
+		pos++
+
+		if c.sel.Contains(pos) != c.opts.Complement {
+			if c.opts.HasOutputDelimiter && last > 0 && last != pos-1 {
+				dst = append(dst, c.opts.OutputDelimiter...)
+			}
+			dst = append(dst, line[i:i+size]...)
+			last = pos
+		}
+		i += size
+	}
+	return dst
+}
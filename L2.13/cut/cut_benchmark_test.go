@@ -0,0 +1,104 @@
+package cut
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// benchLines генерирует строки из cols полей через разделитель ","
+func benchLines(n, cols int) []byte {
+	var sb strings.Builder
+	for i := 0; i < n; i++ {
+		for c := 0; c < cols; c++ {
+			if c > 0 {
+				sb.WriteByte(',')
+			}
+			sb.WriteString("field")
+			sb.WriteString(strconv.Itoa(i * c))
+		}
+		sb.WriteByte('\n')
+	}
+	return []byte(sb.String())
+}
+
+// benchRecords прогоняет все строки данных через appendRecord
+func benchRecords(b *testing.B, data []byte, spec string, opts Options) {
+	c, err := New(spec, opts)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	records := make([][]byte, len(lines))
+	for i, l := range lines {
+		records[i] = []byte(l)
+	}
+
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	var out []byte
+	for i := 0; i < b.N; i++ {
+		for _, rec := range records {
+			out = c.appendRecord(out[:0], rec)
+		}
+	}
+}
+
+func BenchmarkCutFields(b *testing.B) {
+	benchRecords(b, benchLines(10000, 20), "2,5-7", Options{Delimiter: ","})
+}
+
+func BenchmarkCutFieldsComplement(b *testing.B) {
+	benchRecords(b, benchLines(10000, 20), "2,5-7", Options{Delimiter: ",", Complement: true})
+}
+
+func BenchmarkCutFieldsMultiCharDelimiter(b *testing.B) {
+	data := []byte(strings.ReplaceAll(string(benchLines(10000, 20)), ",", "::"))
+	benchRecords(b, data, "2,5-7", Options{Delimiter: "::"})
+}
+
+func BenchmarkCutFieldsOrdered(b *testing.B) {
+	benchRecords(b, benchLines(10000, 20), "7,2,5-6", Options{Delimiter: ",", Ordered: true})
+}
+
+func BenchmarkCutBytes(b *testing.B) {
+	benchRecords(b, benchLines(10000, 20), "1-8,20-30", Options{Mode: ModeBytes})
+}
+
+func BenchmarkCutChars(b *testing.B) {
+	benchRecords(b, benchLines(10000, 20), "1-8,20-30", Options{Mode: ModeChars})
+}
+
+func BenchmarkCut(b *testing.B) {
+	data := benchLines(100000, 20)
+	name := filepath.Join(b.TempDir(), "input")
+	if err := os.WriteFile(name, data, 0644); err != nil {
+		b.Fatal(err)
+	}
+
+	c, err := New("2,5-7", Options{Delimiter: ","})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		f, err := os.Open(name)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if err := c.Cut(f, io.Discard); err != nil {
+			b.Fatal(err)
+		}
+		f.Close()
+	}
+}
@@ -0,0 +1,388 @@
+package cut
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// helper: прогоняет input через Cutter и возвращает вывод
+func cutString(t *testing.T, spec string, opts Options, input string) string {
+	t.Helper()
+	c, err := New(spec, opts)
+	if err != nil {
+		t.Fatalf("New(%q): %v", spec, err)
+	}
+	var buf bytes.Buffer
+	if err := c.Cut(strings.NewReader(input), &buf); err != nil {
+		t.Fatalf("Cut error: %v", err)
+	}
+	return buf.String()
+}
+
+func TestCutFields(t *testing.T) {
+	out := cutString(t, "1,3", Options{Delimiter: ","}, "a,b,c,d")
+
+	expected := "a,c\n"
+	if out != expected {
+		t.Errorf("expected %q, got %q", expected, out)
+	}
+}
+
+func TestCutSeparated(t *testing.T) {
+	out := cutString(t, "1", Options{Delimiter: ",", Separated: true}, "abc")
+
+	if out != "" {
+		t.Errorf("expected empty output, got %q", out)
+	}
+}
+
+func TestDefaultDelimiterIsTab(t *testing.T) {
+	out := cutString(t, "2", Options{}, "a\tb\tc\n1\t2\t3")
+
+	expected := "b\n2\n"
+	if out != expected {
+		t.Errorf("expected %q, got %q", expected, out)
+	}
+}
+
+// --- интеграционные тесты ---
+
+func TestIntegration_MultipleRanges(t *testing.T) {
+	out := cutString(t, "1,3,5", Options{Delimiter: ","}, "a,b,c,d,e")
+
+	expected := "a,c,e\n"
+	if out != expected {
+		t.Errorf("expected %q, got %q", expected, out)
+	}
+}
+
+func TestIntegration_FieldOutOfRange(t *testing.T) {
+	out := cutString(t, "2,3", Options{Delimiter: ","}, "x,y")
+
+	expected := "y\n"
+	if out != expected {
+		t.Errorf("expected %q, got %q", expected, out)
+	}
+}
+
+func TestIntegration_EmptyLines(t *testing.T) {
+	out := cutString(t, "1", Options{Delimiter: ","}, "\na,b,c\n\n")
+
+	expected := "\na\n\n"
+	if out != expected {
+		t.Errorf("expected %q, got %q", expected, out)
+	}
+}
+
+func TestIntegration_ConsecutiveDelimiters(t *testing.T) {
+	out := cutString(t, "1,3,5", Options{Delimiter: ","}, "a,,c,,e")
+
+	expected := "a,c,e\n"
+	if out != expected {
+		t.Errorf("expected %q, got %q", expected, out)
+	}
+}
+
+func TestIntegration_SeparatedSkip(t *testing.T) {
+	out := cutString(t, "1", Options{Delimiter: ",", Separated: true}, "abc\n1,2,3\nxyz")
+
+	expected := "1\n"
+	if out != expected {
+		t.Errorf("expected %q, got %q", expected, out)
+	}
+}
+
+func TestIntegration_UTF8Delimiter(t *testing.T) {
+	out := cutString(t, "2", Options{Delimiter: "☆"}, "a☆b☆c")
+
+	expected := "b\n"
+	if out != expected {
+		t.Errorf("expected %q, got %q", expected, out)
+	}
+}
+
+func TestIntegration_NoMatchingFields(t *testing.T) {
+	out := cutString(t, "10", Options{Delimiter: ","}, "1,2,3")
+
+	if out != "\n" {
+		t.Errorf("expected empty line, got %q", out)
+	}
+}
+
+func TestIntegration_NoDelimiter(t *testing.T) {
+	out := cutString(t, "1", Options{Delimiter: ","}, "hello")
+
+	expected := "hello\n"
+	if out != expected {
+		t.Errorf("expected %q, got %q", expected, out)
+	}
+}
+
+func TestIntegration_OpenRanges(t *testing.T) {
+	out := cutString(t, "-2,4-", Options{Delimiter: ","}, "a,b,c,d,e")
+
+	expected := "a,b,d,e\n"
+	if out != expected {
+		t.Errorf("expected %q, got %q", expected, out)
+	}
+}
+
+func TestIntegration_Complement(t *testing.T) {
+	out := cutString(t, "2-4", Options{Delimiter: ",", Complement: true}, "a,b,c,d,e")
+
+	expected := "a,e\n"
+	if out != expected {
+		t.Errorf("expected %q, got %q", expected, out)
+	}
+}
+
+func TestCutBytes(t *testing.T) {
+	tests := []struct {
+		line       string
+		list       string
+		complement bool
+		noSplit    bool
+		expected   string
+	}{
+		{"abcdef", "2-4", false, false, "bcd"},
+		{"abcdef", "-2,5-", false, false, "abef"},
+		{"abcdef", "2-4", true, false, "aef"},
+		{"abc", "5-", false, false, ""},
+		{"aПb", "1-2", false, false, "a\xd0"}, // без -n символ разрывается
+		{"aПb", "1-2", false, true, "a"},      // с -n неполный символ пропускается
+		{"aПb", "1-3", false, true, "aП"},
+		{"aПb", "3-", false, true, "Пb"}, // выбран хвост символа
+		{"aПb", "3", true, true, "ab"},
+	}
+
+	for _, tt := range tests {
+		opts := Options{Mode: ModeBytes, Complement: tt.complement, NoSplit: tt.noSplit}
+		got := cutString(t, tt.list, opts, tt.line)
+		if got != tt.expected+"\n" {
+			t.Errorf("bytes(%q, %q, complement=%v, n=%v) = %q, expected %q",
+				tt.line, tt.list, tt.complement, tt.noSplit, got, tt.expected)
+		}
+	}
+}
+
+func TestCutChars(t *testing.T) {
+	tests := []struct {
+		line       string
+		list       string
+		complement bool
+		expected   string
+	}{
+		{"привет", "1-3", false, "при"},
+		{"привет", "-2,6", false, "прт"},
+		{"привет", "2-5", true, "пт"},
+		{"a☆b☆c", "2", false, "☆"},
+		{"ab\xffcd", "3-4", false, "\xffc"},
+	}
+
+	for _, tt := range tests {
+		got := cutString(t, tt.list, Options{Mode: ModeChars, Complement: tt.complement}, tt.line)
+		if got != tt.expected+"\n" {
+			t.Errorf("chars(%q, %q) = %q, expected %q", tt.line, tt.list, got, tt.expected)
+		}
+	}
+}
+
+func TestIntegration_ByteMode(t *testing.T) {
+	out := cutString(t, "9-12", Options{Mode: ModeBytes}, "20251206ACME  0042")
+
+	expected := "ACME\n"
+	if out != expected {
+		t.Errorf("expected %q, got %q", expected, out)
+	}
+}
+
+func TestIntegration_MultiCharDelimiter(t *testing.T) {
+	out := cutString(t, "1,3", Options{Delimiter: "::"}, "a::b::c:d")
+
+	expected := "a::c:d\n"
+	if out != expected {
+		t.Errorf("expected %q, got %q", expected, out)
+	}
+}
+
+func TestIntegration_OutputDelimiter(t *testing.T) {
+	opts := Options{Delimiter: ",", OutputDelimiter: " | ", HasOutputDelimiter: true}
+	out := cutString(t, "1,3", opts, "a,b,c")
+
+	expected := "a | c\n"
+	if out != expected {
+		t.Errorf("expected %q, got %q", expected, out)
+	}
+}
+
+func TestIntegration_RegexDelimiter(t *testing.T) {
+	opts := Options{
+		DelimRegex:         regexp.MustCompile(`\s+`),
+		Separated:          true,
+		OutputDelimiter:    ",",
+		HasOutputDelimiter: true,
+	}
+	out := cutString(t, "2,4", opts, "root     1234  0.0 /sbin/init\nplain")
+
+	expected := "1234,/sbin/init\n"
+	if out != expected {
+		t.Errorf("expected %q, got %q", expected, out)
+	}
+}
+
+func TestOutputDelimiterBetweenRanges(t *testing.T) {
+	opts := Options{Mode: ModeBytes, OutputDelimiter: ":", HasOutputDelimiter: true}
+	if got := cutString(t, "1-2,3,6-", opts, "abcdefg"); got != "abc:fg\n" {
+		t.Errorf("bytes: got %q, expected %q", got, "abc:fg\n")
+	}
+
+	opts.Mode = ModeChars
+	if got := cutString(t, "1-2,3,6-", opts, "абвгдеж"); got != "абв:еж\n" {
+		t.Errorf("chars: got %q, expected %q", got, "абв:еж\n")
+	}
+}
+
+func TestIntegration_Ordered(t *testing.T) {
+	tests := []struct {
+		list     string
+		input    string
+		expected string
+	}{
+		{"3,1,3", "a,b,c,d", "c,a,c\n"},
+		{"4-,1", "a,b,c,d,e", "d,e,a\n"},
+		{"2-1000000000", "a,b,c", "b,c\n"},
+		{"9,2", "a,b", "b\n"},
+	}
+
+	for _, tt := range tests {
+		out := cutString(t, tt.list, Options{Delimiter: ",", Ordered: true}, tt.input)
+		if out != tt.expected {
+			t.Errorf("-f %s: expected %q, got %q", tt.list, tt.expected, out)
+		}
+	}
+}
+
+func TestRecordReader(t *testing.T) {
+	long := strings.Repeat("x", 200*1024) // длиннее буфера bufio.Reader и лимита bufio.Scanner
+	input := "a\r\n" + long + "\n\nlast"
+
+	rr := newRecordReader(strings.NewReader(input), '\n')
+	var got []string
+	for {
+		line, err := rr.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, string(line))
+	}
+
+	expected := []string{"a", long, "", "last"}
+	if len(got) != len(expected) {
+		t.Fatalf("expected %d records, got %d", len(expected), len(got))
+	}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Errorf("record %d: got %d bytes, expected %d", i, len(got[i]), len(expected[i]))
+		}
+	}
+}
+
+func TestIntegration_ZeroTerminated(t *testing.T) {
+	out := cutString(t, "2", Options{Delimiter: ",", Zero: true}, "a,b\nc\x00d,e\x00")
+
+	expected := "b\nc\x00e\x00"
+	if out != expected {
+		t.Errorf("expected %q, got %q", expected, out)
+	}
+}
+
+func TestIntegration_LongLine(t *testing.T) {
+	cols := make([]string, 50000)
+	for i := range cols {
+		cols[i] = strconv.Itoa(i + 1)
+	}
+
+	out := cutString(t, "2,49999-", Options{Delimiter: ","}, strings.Join(cols, ",")+"\n")
+	if out != "2,49999,50000\n" {
+		t.Errorf("unexpected output %q", out)
+	}
+}
+
+func TestFastPathMatchesSplit(t *testing.T) {
+	lines := []string{"", "a", "a,b", ",,", "a,b,c,d,e,f", "x,,y,", "1,2,3,4,5,6,7,8,9,10"}
+	specs := []string{"1", "2", "-2", "3-", "1,3,5", "2-4,6-", "10"}
+
+	for _, complement := range []bool{false, true} {
+		for _, spec := range specs {
+			c, _ := New(spec, Options{Delimiter: ",", Complement: complement})
+			for _, line := range lines {
+				// эталон: посчитать через strings.Split и selectFields
+				expected := strings.Join(c.selectFields(strings.Split(line, ","), c.sel), ",") + "\n"
+
+				if got := string(c.appendRecord(nil, []byte(line))); got != expected {
+					t.Errorf("-f %s complement=%v %q: got %q, expected %q", spec, complement, line, got, expected)
+				}
+			}
+		}
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	tests := []struct {
+		spec   string
+		opts   Options
+		option string // ожидаемое OptionError.Option; пусто — ожидается SpecError
+	}{
+		{"1-x", Options{}, ""},
+		{"1", Options{Mode: ModeBytes, Delimiter: ","}, "Mode"},
+		{"1", Options{Mode: ModeChars, Ordered: true}, "Mode"},
+		{"1", Options{Ordered: true, Complement: true}, "Ordered"},
+		{"1", Options{CSV: true, Zero: true}, "Zero"},
+		{"1", Options{Header: true}, "Header"},
+		{"1", Options{CSV: true, Delimiter: "::"}, "Delimiter"},
+		{"1", Options{DelimRegex: regexp.MustCompile(`\s*`)}, "DelimRegex"},
+	}
+
+	for _, tt := range tests {
+		_, err := New(tt.spec, tt.opts)
+
+		var optErr *OptionError
+		var specErr *SpecError
+		switch {
+		case tt.option == "" && !errors.As(err, &specErr):
+			t.Errorf("New(%q, %+v): expected *SpecError, got %v", tt.spec, tt.opts, err)
+		case tt.option != "" && (!errors.As(err, &optErr) || optErr.Option != tt.option):
+			t.Errorf("New(%q, %+v): expected OptionError for %s, got %v", tt.spec, tt.opts, tt.option, err)
+		}
+	}
+}
+
+func TestCutterConcurrentUse(t *testing.T) {
+	c, err := New("2", Options{Delimiter: ","})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan string)
+	for i := 0; i < 8; i++ {
+		go func(i int) {
+			var buf bytes.Buffer
+			c.Cut(strings.NewReader(strings.Repeat("x,"+strconv.Itoa(i)+"\n", 1000)), &buf)
+			done <- buf.String()
+		}(i)
+	}
+	for i := 0; i < 8; i++ {
+		out := <-done
+		if len(strings.Split(strings.TrimSuffix(out, "\n"), "\n")) != 1000 {
+			t.Errorf("unexpected output length %d", len(out))
+		}
+	}
+}
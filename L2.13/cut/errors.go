@@ -0,0 +1,22 @@
+package cut
+
+// SpecError — ошибка в списке позиций (-f, -b, -c)
+type SpecError struct {
+	Spec   string // список целиком
+	Part   string // ошибочный элемент списка
+	Reason string // причина: "invalid range", "invalid position", "unknown column"
+}
+
+func (e *SpecError) Error() string {
+	return e.Reason + ": " + e.Part
+}
+
+// OptionError — недопустимое значение или сочетание параметров Options
+type OptionError struct {
+	Option string // имя поля Options, к которому относится ошибка
+	Reason string // описание ошибки
+}
+
+func (e *OptionError) Error() string {
+	return e.Reason
+}
@@ -0,0 +1,45 @@
+package cut
+
+import (
+	"bufio"
+	"errors"
+	"io"
+)
+
+// recordReader читает записи произвольной длины, в отличие от bufio.Scanner с лимитом 64 KiB
+type recordReader struct {
+	r    *bufio.Reader
+	term byte   // терминатор записи: '\n' или NUL для -z
+	buf  []byte // склейка записей, не поместившихся в буфер bufio.Reader
+}
+
+// newRecordReader создаёт reader с буфером побольше стандартного для длинных строк
+func newRecordReader(r io.Reader, term byte) *recordReader {
+	return &recordReader{r: bufio.NewReaderSize(r, 64*1024), term: term}
+}
+
+// next возвращает следующую запись без терминатора. Срез действителен до следующего вызова.
+// Последняя запись без терминатора тоже возвращается; после неё — io.EOF
+func (rr *recordReader) next() ([]byte, error) {
+	line, err := rr.r.ReadSlice(rr.term)
+	if errors.Is(err, bufio.ErrBufferFull) { // длинная запись: собираем по кускам
+		rr.buf = append(rr.buf[:0], line...)
+		for errors.Is(err, bufio.ErrBufferFull) {
+			line, err = rr.r.ReadSlice(rr.term)
+			rr.buf = append(rr.buf, line...)
+		}
+		line = rr.buf
+	}
+
+	if err != nil && !(errors.Is(err, io.EOF) && len(line) > 0) {
+		return nil, err
+	}
+
+	if n := len(line); n > 0 && line[n-1] == rr.term {
+		line = line[:n-1]
+		if rr.term == '\n' && n > 1 && line[n-2] == '\r' { // как bufio.ScanLines, отбрасываем \r перед \n
+			line = line[:n-2]
+		}
+	}
+	return line, nil
+}
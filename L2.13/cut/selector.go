@@ -0,0 +1,119 @@
+package cut
+
+import (
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// OpenEnd — верхняя граница открытого диапазона вида "5-"
+const OpenEnd = math.MaxInt
+
+// Range — диапазон позиций [Lo, Hi] включительно
+type Range struct {
+	Lo, Hi int
+}
+
+// Selector — разобранный список позиций полей, байтов или символов.
+// Позиции не разворачиваются поштучно, поэтому "1-1000000000" занимает один диапазон
+type Selector struct {
+	ranges []Range // отсортированные непересекающиеся диапазоны
+	order  []Range // диапазоны в порядке записи, с повторами — для Options.Ordered
+}
+
+// ParseSelector разбирает список вида "1,3-5,-2,7-"
+func ParseSelector(spec string) (*Selector, error) {
+	order, err := parseRanges(spec)
+	if err != nil {
+		return nil, err
+	}
+	return &Selector{
+		ranges: mergeRanges(slices.Clone(order)), // mergeRanges сортирует на месте
+		order:  order,
+	}, nil
+}
+
+// Contains сообщает, входит ли позиция n в список
+func (s *Selector) Contains(n int) bool {
+	i := sort.Search(len(s.ranges), func(i int) bool { return s.ranges[i].Hi >= n })
+	return i < len(s.ranges) && s.ranges[i].Lo <= n
+}
+
+// Ranges возвращает отсортированные непересекающиеся диапазоны
+func (s *Selector) Ranges() []Range {
+	return slices.Clone(s.ranges)
+}
+
+// Order возвращает диапазоны в порядке записи, без склейки
+func (s *Selector) Order() []Range {
+	return slices.Clone(s.order)
+}
+
+// parsePosition разбирает номер позиции: только цифры, больше нуля
+func parsePosition(s string) (int, bool) {
+	if s == "" || strings.TrimLeft(s, "0123456789") != "" {
+		return 0, false
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n <= 0 {
+		return 0, false
+	}
+	return n, true
+}
+
+// parseRanges разбирает список в диапазоны в порядке их записи, без сортировки и склейки
+func parseRanges(spec string) ([]Range, error) {
+	var result []Range
+
+	parts := strings.Split(spec, ",")
+	for _, part := range parts {
+
+		if lo, hi, ok := strings.Cut(part, "-"); ok { // диапазон
+			start, end := 1, OpenEnd // "-3" начинается с первой позиции, "5-" идёт до конца строки
+			ok1, ok2 := true, true
+
+			if lo == "" && hi == "" {
+				return nil, &SpecError{Spec: spec, Part: part, Reason: "invalid range"}
+			}
+			if lo != "" {
+				start, ok1 = parsePosition(lo)
+			}
+			if hi != "" {
+				end, ok2 = parsePosition(hi)
+			}
+
+			if !ok1 || !ok2 || start > end {
+				return nil, &SpecError{Spec: spec, Part: part, Reason: "invalid range"}
+			}
+
+			result = append(result, Range{Lo: start, Hi: end})
+		} else { // одиночная позиция
+			n, ok := parsePosition(part)
+			if !ok {
+				return nil, &SpecError{Spec: spec, Part: part, Reason: "invalid position"}
+			}
+			result = append(result, Range{Lo: n, Hi: n})
+		}
+	}
+	return result, nil
+}
+
+// mergeRanges сортирует диапазоны и склеивает пересекающиеся и соседние
+func mergeRanges(ranges []Range) []Range {
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].Lo < ranges[j].Lo })
+
+	merged := ranges[:0]
+	for _, r := range ranges {
+		if n := len(merged); n > 0 {
+			last := &merged[n-1]
+			if last.Hi == OpenEnd || r.Lo <= last.Hi+1 {
+				last.Hi = max(last.Hi, r.Hi)
+				continue
+			}
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
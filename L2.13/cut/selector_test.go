@@ -0,0 +1,98 @@
+package cut
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseSelector(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []Range
+		hasErr   bool
+	}{
+		{"1", []Range{{1, 1}}, false},
+		{"1,3", []Range{{1, 1}, {3, 3}}, false},
+		{"1-3", []Range{{1, 3}}, false},
+		{"1,3-5", []Range{{1, 1}, {3, 5}}, false},
+		{"2-2", []Range{{2, 2}}, false},
+		{"-3", []Range{{1, 3}}, false},
+		{"5-", []Range{{5, OpenEnd}}, false},
+		{"5-,2", []Range{{2, 2}, {5, OpenEnd}}, false},
+		{"3,1-2,7-,8", []Range{{1, 3}, {7, OpenEnd}}, false},
+		{"1-1000000000", []Range{{1, 1000000000}}, false},
+		{"0", nil, true},
+		{"3-a", nil, true},
+		{"5-1", nil, true},
+		{"1--3", nil, true},
+		{"-", nil, true},
+		{"+2", nil, true},
+		{"1,,2", nil, true},
+	}
+
+	for _, tt := range tests {
+		sel, err := ParseSelector(tt.input)
+
+		if tt.hasErr && err == nil {
+			t.Errorf("expected error for input %s", tt.input)
+		}
+		if !tt.hasErr && err != nil {
+			t.Errorf("unexpected error for %s: %v", tt.input, err)
+		}
+		if !tt.hasErr {
+			result := sel.Ranges()
+			if len(result) != len(tt.expected) {
+				t.Errorf("wrong length for %s: got %d, expected %d",
+					tt.input, len(result), len(tt.expected))
+				continue
+			}
+			for i := range tt.expected {
+				if result[i] != tt.expected[i] {
+					t.Errorf("range %d for %s: got %v, expected %v", i, tt.input, result[i], tt.expected[i])
+				}
+			}
+		}
+	}
+}
+
+func TestSelectorContains(t *testing.T) {
+	sel, _ := ParseSelector("2-4,10-")
+
+	for n, want := range map[int]bool{1: false, 2: true, 4: true, 5: false, 9: false, 10: true, 1 << 40: true} {
+		if got := sel.Contains(n); got != want {
+			t.Errorf("Contains(%d) = %v, expected %v", n, got, want)
+		}
+	}
+}
+
+func TestSelectorKeepsOrder(t *testing.T) {
+	sel, err := ParseSelector("3,1,3,5-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Range{{3, 3}, {1, 1}, {3, 3}, {5, OpenEnd}}
+	ranges := sel.Order()
+	if len(ranges) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, ranges)
+	}
+	for i := range expected {
+		if ranges[i] != expected[i] {
+			t.Errorf("range %d: expected %v, got %v", i, expected[i], ranges[i])
+		}
+	}
+}
+
+func TestSpecError(t *testing.T) {
+	_, err := ParseSelector("1,5-2")
+
+	var specErr *SpecError
+	if !errors.As(err, &specErr) {
+		t.Fatalf("expected *SpecError, got %T", err)
+	}
+	if specErr.Spec != "1,5-2" || specErr.Part != "5-2" || specErr.Reason != "invalid range" {
+		t.Errorf("unexpected error fields: %+v", specErr)
+	}
+	if err.Error() != "invalid range: 5-2" {
+		t.Errorf("unexpected message %q", err.Error())
+	}
+}
@@ -7,136 +7,160 @@ import (
 	"fmt"
 	"io"
 	"os"
-	"strconv"
-	"strings"
-)
+	"regexp"
 
-// crash выводит сообщение об ошибке и завершает программу
-func crash(err error) {
-	fmt.Fprintln(os.Stderr, err)
-	os.Exit(1)
-}
+	"cut/cut"
+)
 
 // Flags хранит значения флагов
 type Flags struct {
-	Fields    string // строка -f: "1,3-5"
-	Delimiter rune   // разделитель
-	Separated bool   // флаг -s
-	File      string // входной файл
+	List    string      // строка списка: "1,3-5"
+	Options cut.Options // параметры обработки
+	Files   []string    // входные файлы, "-" — stdin; пусто — только stdin
 }
 
-// parseFlags парсит флаги командной строки
-func parseFlags() Flags {
-	fields := flag.String("f", "", "номера колонок, которые нужно вывести, например 1,3-5 (обязательный)")
-	delimiter := flag.String("d", "\t", "разделитель")
-	separated := flag.Bool("s", false, "только строки содержащие разделитель")
+const usage = "usage: cut -b list [-n] | -c list | -f list [-s] [-d delim] [--regex-delimiter | --csv | --tsv] " +
+	"[--header] [--complement | --ordered] [--output-delimiter=str] [-z] [file ...]"
+
+// parseFlags парсит флаги командной строки. Ошибки разбора флагов flag уже вывел в stderr
+func parseFlags(args []string, stderr io.Writer) (Flags, error) {
+	fs := flag.NewFlagSet("cut", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+
+	fields := fs.String("f", "", "номера колонок, которые нужно вывести, например 1,3-5")
+	bytesList := fs.String("b", "", "номера байтов, которые нужно вывести")
+	chars := fs.String("c", "", "номера символов, которые нужно вывести")
+	delimiter := fs.String("d", "\t", "разделитель")
+	separated := fs.Bool("s", false, "только строки содержащие разделитель")
+	complement := fs.Bool("complement", false, "вывести все позиции, кроме перечисленных")
+	noSplit := fs.Bool("n", false, "не разрывать многобайтовые символы (с -b)")
+	regexDelim := fs.Bool("regex-delimiter", false, "трактовать -d как регулярное выражение, например '\\s+'")
+	outputDelimiter := fs.String("output-delimiter", "", "разделитель для вывода (по умолчанию совпадает с -d)")
+	ordered := fs.Bool("ordered", false, "выводить поля в порядке из -f, с повторами: -f 3,1,3")
+	csvMode := fs.Bool("csv", false, "разбирать ввод как CSV (RFC 4180), разделитель по умолчанию ','")
+	tsvMode := fs.Bool("tsv", false, "то же, что --csv с разделителем табуляции")
+	header := fs.Bool("header", false, "первая запись CSV — заголовок, -f может содержать имена колонок")
+	zero := fs.Bool("z", false, "записи разделяются NUL, а не переводом строки")
+
+	if err := fs.Parse(args); err != nil {
+		return Flags{}, err
+	}
 
-	flag.Parse()
+	var mode cut.Mode
+	var list string
+	selected := 0
+	for _, m := range []struct {
+		mode cut.Mode
+		list string
+	}{{cut.ModeFields, *fields}, {cut.ModeBytes, *bytesList}, {cut.ModeChars, *chars}} {
+		if m.list != "" {
+			mode, list = m.mode, m.list
+			selected++
+		}
+	}
 
-	if *fields == "" {
-		crash(errors.New("usage: cut -f list [-s] [-d delim] [file ...]"))
+	if selected == 0 {
+		return Flags{}, errors.New(usage)
+	}
+	if selected > 1 {
+		return Flags{}, errors.New("only one type of list may be specified")
 	}
 
-	runes := []rune(*delimiter)
-	if len(runes) != 1 {
-		crash(errors.New("delimiter must be a single character"))
+	set := make(map[string]bool) // флаги, заданные явно
+	fs.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+
+	opts := cut.Options{
+		Mode:       mode,
+		Separated:  *separated,
+		Complement: *complement,
+		NoSplit:    *noSplit,
+		Ordered:    *ordered,
+		CSV:        *csvMode || *tsvMode,
+		Header:     *header,
+		Zero:       *zero,
+
+		OutputDelimiter:    *outputDelimiter,
+		HasOutputDelimiter: set["output-delimiter"],
 	}
 
-	file := ""
-	if flag.NArg() > 0 {
-		file = flag.Arg(0)
+	if set["d"] { // иначе разделитель по умолчанию выбирает пакет cut: табуляция или ',' для CSV
+		if *delimiter == "" {
+			return Flags{}, errors.New("delimiter must not be empty")
+		}
+		opts.Delimiter = *delimiter
 	}
 
-	return Flags{
-		Fields:    *fields,
-		Delimiter: runes[0],
-		Separated: *separated,
-		File:      file,
+	switch {
+	case *csvMode && *tsvMode:
+		return Flags{}, errors.New("--csv and --tsv are mutually exclusive")
+	case *tsvMode && set["d"]:
+		return Flags{}, errors.New("--tsv does not accept -d")
+	case *tsvMode:
+		opts.Delimiter = "\t"
 	}
-}
 
-// parseFields преобразует "-f 1,3-5" в map[int]bool
-func parseFields(spec string) (map[int]bool, error) {
-	result := make(map[int]bool)
-
-	parts := strings.Split(spec, ",")
-	for _, part := range parts {
-
-		if strings.Contains(part, "-") { // диапазон
-			bounds := strings.Split(part, "-")
-			if len(bounds) != 2 {
-				return nil, fmt.Errorf("invalid range: %s", part)
-			}
-			start, err1 := strconv.Atoi(bounds[0])
-			end, err2 := strconv.Atoi(bounds[1])
-
-			if err1 != nil || err2 != nil || start <= 0 || end <= 0 || start > end {
-				return nil, fmt.Errorf("invalid range: %s", part)
-			}
-
-			for i := start; i <= end; i++ {
-				result[i] = true
-			}
-		} else { // одиночное поле
-			n, err := strconv.Atoi(part)
-			if err != nil || n <= 0 {
-				return nil, fmt.Errorf("invalid field: %s", part)
-			}
-			result[n] = true
+	if *regexDelim {
+		re, err := regexp.Compile(*delimiter)
+		if err != nil {
+			return Flags{}, fmt.Errorf("invalid regex delimiter: %w", err)
 		}
+		opts.Delimiter, opts.DelimRegex = "", re
 	}
-	return result, nil
+
+	return Flags{List: list, Options: opts, Files: fs.Args()}, nil
 }
 
-// processLine обрабатывает одну строку
-func processLine(line string, flags Flags, fields map[int]bool) {
-	if flags.Separated && !strings.ContainsRune(line, flags.Delimiter) { // если -s и разделителя нет то игнорируем
-		return
+// run выполняет cut с аргументами args и возвращает код выхода
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags, err := parseFlags(args, stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
 	}
 
-	cols := strings.Split(line, string(flags.Delimiter))
-
-	var out []string
-
-	for i := 1; i <= len(cols); i++ {
-		if fields[i] {
-			out = append(out, cols[i-1])
-		}
+	c, err := cut.New(flags.List, flags.Options)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
 	}
 
-	fmt.Println(strings.Join(out, string(flags.Delimiter))) // если нет подходящих полей — выводим пустую строку
-}
+	w := bufio.NewWriterSize(stdout, 64*1024)
 
-// processFile определение ввода из файла или из stdin и считывание данных
-func processFile(filename string, flags Flags, fields map[int]bool) error {
-	var reader io.Reader
-	if filename != "" {
-		f, err := os.Open(filename)
-		if err != nil {
-			return err
-		}
-		defer f.Close()
-		reader = f
-	} else {
-		reader = os.Stdin
+	files := flags.Files
+	if len(files) == 0 {
+		files = []string{"-"}
 	}
 
-	scanner := bufio.NewScanner(reader)
-	for scanner.Scan() {
-		processLine(scanner.Text(), flags, fields)
+	status := 0
+	for _, name := range files { // ошибка в одном файле не прерывает обработку остальных
+		if err := processFile(c, name, stdin, w); err != nil {
+			fmt.Fprintln(stderr, err)
+			status = 1
+		}
 	}
-	return scanner.Err()
+	return status
 }
 
-func main() {
-	flags := parseFlags()
+// processFile определение ввода из файла или из stdin ("-") и обработка всех записей
+func processFile(c *cut.Cutter, filename string, stdin io.Reader, w *bufio.Writer) error {
+	if filename == "-" {
+		return c.Cut(stdin, w)
+	}
 
-	fields, err := parseFields(flags.Fields)
+	f, err := os.Open(filename)
 	if err != nil {
-		crash(err)
+		return err
 	}
+	defer f.Close()
 
-	if err := processFile(flags.File, flags, fields); err != nil {
-		crash(err)
-	}
+	return c.Cut(f, w)
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
 }
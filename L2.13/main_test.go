@@ -3,309 +3,96 @@ package main
 import (
 	"bytes"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 )
 
-// helper
-func captureOutput(f func()) string {
-	var buf bytes.Buffer
-	old := os.Stdout
-	r, w, _ := os.Pipe()
-	os.Stdout = w
-
-	f()
-
-	w.Close()
-	os.Stdout = old
-	buf.ReadFrom(r)
-	return buf.String()
-}
-
-func TestParseFields(t *testing.T) {
-	tests := []struct {
-		input    string
-		expected map[int]bool
-		hasErr   bool
-	}{
-		{"1", map[int]bool{1: true}, false},
-		{"1,3", map[int]bool{1: true, 3: true}, false},
-		{"1-3", map[int]bool{1: true, 2: true, 3: true}, false},
-		{"1,3-5", map[int]bool{1: true, 3: true, 4: true, 5: true}, false},
-		{"2-2", map[int]bool{2: true}, false},
-		{"0", nil, true},
-		{"3-a", nil, true},
-		{"5-1", nil, true},
-		{"1--3", nil, true},
-	}
-
-	for _, tt := range tests {
-		result, err := parseFields(tt.input)
-
-		if tt.hasErr && err == nil {
-			t.Errorf("expected error for input %s", tt.input)
-		}
-		if !tt.hasErr && err != nil {
-			t.Errorf("unexpected error for %s: %v", tt.input, err)
-		}
-		if !tt.hasErr {
-			if len(result) != len(tt.expected) {
-				t.Errorf("wrong length for %s: got %d, expected %d",
-					tt.input, len(result), len(tt.expected))
-			}
-			for k := range tt.expected {
-				if !result[k] {
-					t.Errorf("missing field %d for %s", k, tt.input)
-				}
-			}
-		}
-	}
-}
-
-func TestProcessLine(t *testing.T) {
-	flags := Flags{
-		Delimiter: ',',
-		Separated: false,
-	}
-
-	fields := map[int]bool{1: true, 3: true}
-
-	line := "a,b,c,d"
-
-	out := captureOutput(func() {
-		processLine(line, flags, fields)
-	})
-
-	expected := "a,c\n"
-	if out != expected {
-		t.Errorf("expected %q, got %q", expected, out)
-	}
-}
-
-func TestProcessLineSeparated(t *testing.T) {
-	flags := Flags{
-		Delimiter: ',',
-		Separated: true,
-	}
-
-	fields := map[int]bool{1: true}
-
-	line := "abc"
-
-	out := captureOutput(func() {
-		processLine(line, flags, fields)
-	})
-
-	if out != "" {
-		t.Errorf("expected empty output, got %q", out)
-	}
+// helper: запускает cut с аргументами и stdin, возвращает stdout, stderr и код выхода
+func runCut(args []string, stdin string) (string, string, int) {
+	var stdout, stderr bytes.Buffer
+	code := run(args, strings.NewReader(stdin), &stdout, &stderr)
+	return stdout.String(), stderr.String(), code
 }
 
 func TestProcessFile(t *testing.T) {
-	content := "a,b,c\n1,2,3"
-	tmp, err := os.CreateTemp("", "cut_test")
-	if err != nil {
-		t.Fatal(err)
-	}
-	defer os.Remove(tmp.Name())
-
-	tmp.WriteString(content)
-	tmp.Close()
+	tmp := filepath.Join(t.TempDir(), "cut_test")
+	os.WriteFile(tmp, []byte("a,b,c\n1,2,3"), 0644)
 
-	flags := Flags{
-		Delimiter: ',',
-		Separated: false,
-		File:      tmp.Name(),
-	}
-
-	fields := map[int]bool{2: true}
-
-	out := captureOutput(func() {
-		err := processFile(tmp.Name(), flags, fields)
-		if err != nil {
-			t.Fatalf("processFile error: %v", err)
-		}
-	})
+	out, _, code := runCut([]string{"-d", ",", "-f", "2", tmp}, "")
 
 	expected := "b\n2\n"
-	if strings.TrimSpace(out) != strings.TrimSpace(expected) {
-		t.Errorf("expected:\n%q\ngot:\n%q", expected, out)
+	if code != 0 || out != expected {
+		t.Errorf("expected %q (code 0), got %q (code %d)", expected, out, code)
 	}
 }
 
 // --- интеграционные тесты ---
 
 func TestIntegration_FromSTDIN(t *testing.T) {
-	input := "a,b,c\n1,2,3"
-	expected := "b\n2\n"
-
-	flags := Flags{
-		Fields:    "2",
-		Delimiter: ',',
-		Separated: false,
-		File:      "",
-	}
-
-	fields, _ := parseFields("2")
-
-	oldStdin := os.Stdin
-	r, w, _ := os.Pipe()
-	os.Stdin = r
+	out, _, code := runCut([]string{"-d", ",", "-f", "2"}, "a,b,c\n1,2,3")
 
-	w.WriteString(input)
-	w.Close()
-
-	out := captureOutput(func() {
-		processFile("", flags, fields)
-	})
-
-	os.Stdin = oldStdin
-
-	if out != expected {
+	expected := "b\n2\n"
+	if code != 0 || out != expected {
 		t.Errorf("expected %q, got %q", expected, out)
 	}
 }
 
-func TestIntegration_MultipleRanges(t *testing.T) {
-	input := "a,b,c,d,e"
-	expected := "a,c,e\n"
+func TestIntegration_MultipleFiles(t *testing.T) {
+	dir := t.TempDir()
+	first := filepath.Join(dir, "first")
+	second := filepath.Join(dir, "second")
+	os.WriteFile(first, []byte("a,1\n"), 0644)
+	os.WriteFile(second, []byte("b,2"), 0644)
 
-	flags := Flags{
-		Delimiter: ',',
-	}
+	out, errOut, code := runCut([]string{"-d", ",", "-f", "2", first, "-", filepath.Join(dir, "missing"), second}, "c,3\n")
 
-	fields, _ := parseFields("1,3,5")
-
-	out := captureOutput(func() {
-		processLine(input, flags, fields)
-	})
-
-	if out != expected {
-		t.Errorf("expected %q, got %q", expected, out)
+	if out != "1\n3\n2\n" {
+		t.Errorf("unexpected output %q", out)
 	}
-}
-
-func TestIntegration_FieldOutOfRange(t *testing.T) {
-	input := "x,y"
-	expected := "y\n"
-
-	flags := Flags{Delimiter: ','}
-	fields, _ := parseFields("2,3")
-
-	out := captureOutput(func() {
-		processLine(input, flags, fields)
-	})
-
-	if out != expected {
-		t.Errorf("expected %q, got %q", expected, out)
+	if code != 1 || !strings.Contains(errOut, "missing") {
+		t.Errorf("expected error for missing file and code 1, got %q (code %d)", errOut, code)
 	}
 }
 
-func TestIntegration_EmptyLines(t *testing.T) {
-	input := "\na,b,c\n\n"
-	expected := "\na\n\n\n"
-
-	flags := Flags{Delimiter: ','}
-	fields, _ := parseFields("1")
-
-	out := captureOutput(func() {
-		for _, line := range strings.Split(input, "\n") {
-			processLine(line, flags, fields)
-		}
-	})
+func TestIntegration_TSVHeader(t *testing.T) {
+	out, _, code := runCut([]string{"--tsv", "--header", "--ordered", "-f", "email,name", "--output-delimiter=,"},
+		"name\temail\nDoe, John\tjohn@example.com\n")
 
-	if out != expected {
-		t.Errorf("expected %q, got %q", expected, out)
+	expected := "email,name\njohn@example.com,\"Doe, John\"\n"
+	if code != 0 || out != expected {
+		t.Errorf("expected %q, got %q (code %d)", expected, out, code)
 	}
 }
 
-func TestIntegration_ConsecutiveDelimiters(t *testing.T) {
-	input := "a,,c,,e"
-	expected := "a,c,e\n"
-
-	flags := Flags{Delimiter: ','}
-	fields, _ := parseFields("1,3,5")
-
-	out := captureOutput(func() {
-		processLine(input, flags, fields)
-	})
+func TestIntegration_RegexDelimiterFlag(t *testing.T) {
+	out, _, code := runCut([]string{"-d", `\s+`, "--regex-delimiter", "-f", "2"}, "a   b c\n")
 
-	if out != expected {
-		t.Errorf("expected %q, got %q", expected, out)
+	if code != 0 || out != "b\n" {
+		t.Errorf("expected %q, got %q (code %d)", "b\n", out, code)
 	}
 }
 
-func TestIntegration_SeparatedSkip(t *testing.T) {
-	input := "abc\n1,2,3\nxyz"
-	expected := "1\n"
-
-	flags := Flags{
-		Delimiter: ',',
-		Separated: true,
+func TestFlagErrors(t *testing.T) {
+	tests := []struct {
+		args   []string
+		errOut string
+	}{
+		{[]string{}, "usage"},
+		{[]string{"-f", "1", "-b", "2"}, "only one type of list"},
+		{[]string{"-f", "1", "-d", ""}, "delimiter must not be empty"},
+		{[]string{"-b", "1", "-d", ","}, "only valid when operating on fields"},
+		{[]string{"-f", "1", "--csv", "--tsv"}, "mutually exclusive"},
+		{[]string{"-f", "1", "--tsv", "-d", ","}, "--tsv does not accept -d"},
+		{[]string{"-f", "1", "--regex-delimiter", "-d", "("}, "invalid regex delimiter"},
+		{[]string{"-f", "1,x"}, "invalid position: x"},
+		{[]string{"-f", "1", "--ordered", "--complement"}, "not compatible"},
 	}
 
-	fields, _ := parseFields("1")
-
-	out := captureOutput(func() {
-		for _, line := range strings.Split(input, "\n") {
-			processLine(line, flags, fields)
+	for _, tt := range tests {
+		_, errOut, code := runCut(tt.args, "")
+		if code == 0 || !strings.Contains(errOut, tt.errOut) {
+			t.Errorf("%v: expected error containing %q, got %q (code %d)", tt.args, tt.errOut, errOut, code)
 		}
-	})
-
-	if out != expected {
-		t.Errorf("expected %q, got %q", expected, out)
-	}
-}
-
-func TestIntegration_UTF8Delimiter(t *testing.T) {
-	input := "a☆b☆c"
-	expected := "b\n"
-
-	flags := Flags{
-		Delimiter: '☆',
-	}
-
-	fields, _ := parseFields("2")
-
-	out := captureOutput(func() {
-		processLine(input, flags, fields)
-	})
-
-	if out != expected {
-		t.Errorf("expected %q, got %q", expected, out)
-	}
-}
-
-func TestIntegration_NoMatchingFields(t *testing.T) {
-	input := "1,2,3"
-	expected := "\n"
-
-	flags := Flags{Delimiter: ','}
-	fields, _ := parseFields("10")
-
-	out := captureOutput(func() {
-		processLine(input, flags, fields)
-	})
-
-	if out != expected {
-		t.Errorf("expected empty line, got %q", out)
-	}
-}
-
-func TestIntegration_NoDelimiter(t *testing.T) {
-	input := "hello"
-	flags := Flags{
-		Delimiter: ',',
-	}
-
-	fields, _ := parseFields("1")
-
-	out := captureOutput(func() {
-		processLine(input, flags, fields)
-	})
-
-	expected := "hello\n"
-	if out != expected {
-		t.Errorf("expected %q, got %q", expected, out)
 	}
 }
@@ -0,0 +1,122 @@
+package minishell
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// commandStart — позиция первого слова команды после присваиваний NAME=value
+func (p *parser) commandStart() int {
+	i := p.pos
+	for i < len(p.toks) && p.toks[i].Kind == TK_WORD && isAssignment(p.toks[i].Raw) {
+		i++
+	}
+	return i
+}
+
+// expandAliases — заменяет слово toks[i] токенами алиаса. Первое слово подстановки раскрывается снова,
+// кроме уже раскрытых в этой цепочке алиасов (seen); если текст алиаса кончается пробелом, раскрывается
+// и слово после него. Подставленные токены получают позицию заменённого слова — исходный текст команды
+// остаётся прежним
+func (p *parser) expandAliases(i int, seen []string) {
+	if i >= len(p.toks) {
+		return
+	}
+	t := p.toks[i]
+	value, ok := p.aliases[t.Val]
+	if t.Kind != TK_WORD || t.Quoted || !ok || slices.Contains(seen, t.Val) || slices.Contains(shellKeywords, t.Val) {
+		return
+	}
+	toks, err := tokenize(value)
+	if err != nil {
+		return
+	}
+	for j := range toks {
+		toks[j].Pos, toks[j].End = t.Pos, t.End
+	}
+	p.toks = slices.Concat(p.toks[:i:i], toks, p.toks[i+1:])
+	seen = append(seen[:len(seen):len(seen)], t.Val)
+
+	// сначала слово после подстановки: раскрытие первого слова сдвигает позиции
+	if strings.HasSuffix(value, " ") || strings.HasSuffix(value, "\t") {
+		p.expandAliases(i+len(toks), seen)
+	}
+	if len(toks) > 0 {
+		p.expandAliases(i, seen)
+	}
+}
+
+// builtinAlias — alias [name[=value]...]: без аргументов печатает все алиасы, name — один, name=value — задаёт
+func (sh *Interpreter) builtinAlias(args []string, out io.Writer) (int, error) {
+	if len(args) > 0 && args[0] == "-p" {
+		args = args[1:]
+	}
+	if len(args) == 0 {
+		names := make([]string, 0, len(sh.aliases))
+		for name := range sh.aliases {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			sh.printAlias(out, name)
+		}
+		return 0, nil
+	}
+
+	var err error
+	for _, a := range args {
+		name, value, ok := strings.Cut(a, "=")
+		switch {
+		case ok && (name == "" || strings.ContainsAny(name, " \t\n/$`'\"\\=")):
+			err = fmt.Errorf("alias: `%s': invalid alias name", name)
+		case ok:
+			sh.aliases[name] = value
+		case !sh.hasAlias(name):
+			err = fmt.Errorf("alias: %s: not found", name)
+		default:
+			sh.printAlias(out, name)
+		}
+	}
+	if err != nil {
+		return 1, err
+	}
+	return 0, nil
+}
+
+// hasAlias — алиас name задан
+func (sh *Interpreter) hasAlias(name string) bool {
+	_, ok := sh.aliases[name]
+	return ok
+}
+
+// printAlias — алиас в виде команды, которая его задаёт
+func (sh *Interpreter) printAlias(out io.Writer, name string) {
+	fmt.Fprintf(out, "alias %s='%s'\n", name, strings.ReplaceAll(sh.aliases[name], "'", `'\''`))
+}
+
+// builtinUnalias — unalias name... удаляет алиасы, unalias -a — все
+func (sh *Interpreter) builtinUnalias(args []string) (int, error) {
+	if len(args) == 1 && args[0] == "-a" {
+		clear(sh.aliases)
+		return 0, nil
+	}
+	if len(args) == 0 {
+		return 2, fmt.Errorf("unalias: usage: unalias [-a] name [name ...]")
+	}
+
+	var err error
+	for _, name := range args {
+		if !sh.hasAlias(name) {
+			err = fmt.Errorf("unalias: %s: not found", name)
+			continue
+		}
+		delete(sh.aliases, name)
+	}
+	if err != nil {
+		return 1, err
+	}
+	return 0, nil
+}
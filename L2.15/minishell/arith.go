@@ -0,0 +1,427 @@
+package minishell
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Операторы арифметики, длинные раньше коротких
+var arithOps = []string{
+	"<<=", ">>=",
+	"**", "<<", ">>", "<=", ">=", "==", "!=", "&&", "||", "++", "--",
+	"+=", "-=", "*=", "/=", "%=", "&=", "^=", "|=",
+	"+", "-", "*", "/", "%", "<", ">", "&", "^", "|", "!", "~", "?", ":", "=", "(", ")", ",",
+}
+
+// arith — вычисление целочисленного выражения $((...)) как в C: переменные по имени,
+// присваивания, тернарный оператор, логические операторы с коротким замыканием
+type arith struct {
+	sh    *Interpreter // откуда берутся переменные
+	toks  []string
+	pos   int
+	skip  int // > 0 — ветка не вычисляется: без присваиваний и ошибок деления
+	depth int // вложенность вычисления значений переменных
+}
+
+// evalArith — значение выражения
+func (sh *Interpreter) evalArith(expr string) (int64, error) {
+	return sh.evalArithDepth(expr, 0)
+}
+
+func (sh *Interpreter) evalArithDepth(expr string, depth int) (int64, error) {
+	if depth > 16 {
+		return 0, fmt.Errorf("%s: expression recursion level exceeded", expr)
+	}
+
+	toks, err := arithTokens(expr)
+	if err != nil {
+		return 0, err
+	}
+	if len(toks) == 0 {
+		return 0, nil
+	}
+
+	a := &arith{sh: sh, toks: toks, depth: depth}
+	v, err := a.comma()
+	if err == nil && a.pos < len(a.toks) {
+		err = fmt.Errorf("%s: syntax error in expression (error token is %q)", expr, a.toks[a.pos])
+	}
+	return v, err
+}
+
+// arithTokens — числа, имена и операторы выражения
+func arithTokens(expr string) ([]string, error) {
+	var toks []string
+	i := 0
+	for i < len(expr) {
+		c := expr[i]
+		switch {
+		case isSpace(c) || c == '\n':
+			i++
+
+		case isAlnum(c) || c == '_':
+			j := i
+			for j < len(expr) && (isAlnum(expr[j]) || expr[j] == '_') {
+				j++
+			}
+			toks = append(toks, expr[i:j])
+			i = j
+
+		default:
+			op := ""
+			for _, o := range arithOps {
+				if strings.HasPrefix(expr[i:], o) {
+					op = o
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("%s: syntax error: invalid arithmetic operator (error token is %q)", expr, expr[i:])
+			}
+			toks = append(toks, op)
+			i += len(op)
+		}
+	}
+	return toks, nil
+}
+
+// peek — текущий токен
+func (a *arith) peek() string {
+	if a.pos < len(a.toks) {
+		return a.toks[a.pos]
+	}
+	return ""
+}
+
+// accept — пропускает токен, если он один из ops
+func (a *arith) accept(ops ...string) (string, bool) {
+	t := a.peek()
+	for _, op := range ops {
+		if t == op {
+			a.pos++
+			return t, true
+		}
+	}
+	return "", false
+}
+
+// comma — expr, expr: значение последнего
+func (a *arith) comma() (int64, error) {
+	v, err := a.assign()
+	for err == nil {
+		if _, ok := a.accept(","); !ok {
+			break
+		}
+		v, err = a.assign()
+	}
+	return v, err
+}
+
+// assign — name = expr и составные присваивания
+func (a *arith) assign() (int64, error) {
+	if a.pos+1 < len(a.toks) && isName(a.toks[a.pos]) {
+		switch op := a.toks[a.pos+1]; op {
+		case "=", "+=", "-=", "*=", "/=", "%=", "<<=", ">>=", "&=", "^=", "|=":
+			name := a.toks[a.pos]
+			a.pos += 2
+			v, err := a.assign()
+			if err != nil {
+				return 0, err
+			}
+			if op != "=" {
+				old, err := a.variable(name)
+				if err != nil {
+					return 0, err
+				}
+				if v, err = a.binary(strings.TrimSuffix(op, "="), old, v); err != nil {
+					return 0, err
+				}
+			}
+			a.set(name, v)
+			return v, nil
+		}
+	}
+	return a.ternary()
+}
+
+// ternary — cond ? a : b; невыбранная ветка не вычисляется
+func (a *arith) ternary() (int64, error) {
+	cond, err := a.binaryLevel(0)
+	if err != nil {
+		return 0, err
+	}
+	if _, ok := a.accept("?"); !ok {
+		return cond, nil
+	}
+
+	if cond == 0 {
+		a.skip++
+	}
+	yes, err := a.comma()
+	if cond == 0 {
+		a.skip--
+	}
+	if err != nil {
+		return 0, err
+	}
+	if _, ok := a.accept(":"); !ok {
+		return 0, fmt.Errorf("syntax error in expression: `:' expected")
+	}
+
+	if cond != 0 {
+		a.skip++
+	}
+	no, err := a.ternary()
+	if cond != 0 {
+		a.skip--
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if cond != 0 {
+		return yes, nil
+	}
+	return no, nil
+}
+
+// Уровни двоичных операторов от низшего приоритета к высшему
+var arithLevels = [][]string{
+	{"||"},
+	{"&&"},
+	{"|"},
+	{"^"},
+	{"&"},
+	{"==", "!="},
+	{"<", "<=", ">", ">="},
+	{"<<", ">>"},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+// binaryLevel — левоассоциативные операторы уровня level и выше
+func (a *arith) binaryLevel(level int) (int64, error) {
+	if level == len(arithLevels) {
+		return a.power()
+	}
+
+	left, err := a.binaryLevel(level + 1)
+	if err != nil {
+		return 0, err
+	}
+	for {
+		op, ok := a.accept(arithLevels[level]...)
+		if !ok {
+			return left, nil
+		}
+
+		// && и || не вычисляют правую часть, если результат уже известен
+		short := op == "&&" && left == 0 || op == "||" && left != 0
+		if short {
+			a.skip++
+		}
+		right, err := a.binaryLevel(level + 1)
+		if short {
+			a.skip--
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		if left, err = a.binary(op, left, right); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// power — a ** b, правоассоциативно
+func (a *arith) power() (int64, error) {
+	base, err := a.unary()
+	if err != nil {
+		return 0, err
+	}
+	if _, ok := a.accept("**"); !ok {
+		return base, nil
+	}
+	exp, err := a.power()
+	if err != nil {
+		return 0, err
+	}
+	return a.binary("**", base, exp)
+}
+
+// unary — + - ! ~ и префиксные ++ --
+func (a *arith) unary() (int64, error) {
+	op, ok := a.accept("+", "-", "!", "~", "++", "--")
+	if !ok {
+		return a.postfix()
+	}
+
+	if op == "++" || op == "--" {
+		name := a.peek()
+		if !isName(name) {
+			return 0, fmt.Errorf("syntax error: operand expected (error token is %q)", op)
+		}
+		a.pos++
+		v, err := a.variable(name)
+		if err != nil {
+			return 0, err
+		}
+		v = increment(v, op)
+		a.set(name, v)
+		return v, nil
+	}
+
+	v, err := a.unary()
+	if err != nil {
+		return 0, err
+	}
+	switch op {
+	case "-":
+		v = -v
+	case "!":
+		v = boolInt(v == 0)
+	case "~":
+		v = ^v
+	}
+	return v, nil
+}
+
+// postfix — число, переменная, (expr) и постфиксные ++ --
+func (a *arith) postfix() (int64, error) {
+	t := a.peek()
+	switch {
+	case t == "":
+		return 0, fmt.Errorf("syntax error: operand expected")
+
+	case t == "(":
+		a.pos++
+		v, err := a.comma()
+		if err != nil {
+			return 0, err
+		}
+		if _, ok := a.accept(")"); !ok {
+			return 0, fmt.Errorf("syntax error: `)' expected")
+		}
+		return v, nil
+
+	case t[0] >= '0' && t[0] <= '9':
+		a.pos++
+		v, err := strconv.ParseInt(t, 0, 64)
+		if err != nil {
+			return 0, fmt.Errorf("%s: value too great for base (error token is %q)", t, t)
+		}
+		return v, nil
+
+	case isName(t):
+		a.pos++
+		v, err := a.variable(t)
+		if err != nil {
+			return 0, err
+		}
+		if op, ok := a.accept("++", "--"); ok {
+			a.set(t, increment(v, op))
+		}
+		return v, nil
+	}
+	return 0, fmt.Errorf("syntax error: operand expected (error token is %q)", t)
+}
+
+// variable — значение переменной как числа; пустая — 0, нечисловая вычисляется как выражение
+func (a *arith) variable(name string) (int64, error) {
+	s, _ := a.sh.lookupVar(name)
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+	if v, err := strconv.ParseInt(s, 0, 64); err == nil {
+		return v, nil
+	}
+	return a.sh.evalArithDepth(s, a.depth+1)
+}
+
+// set — присваивание из выражения
+func (a *arith) set(name string, v int64) {
+	if a.skip == 0 {
+		a.sh.setVar(name, strconv.FormatInt(v, 10))
+	}
+}
+
+// binary — двоичный оператор
+func (a *arith) binary(op string, x, y int64) (int64, error) {
+	switch op {
+	case "+":
+		return x + y, nil
+	case "-":
+		return x - y, nil
+	case "*":
+		return x * y, nil
+	case "/", "%":
+		if y == 0 {
+			if a.skip > 0 {
+				return 0, nil
+			}
+			return 0, fmt.Errorf("division by 0")
+		}
+		if op == "/" {
+			return x / y, nil
+		}
+		return x % y, nil
+	case "**":
+		if y < 0 {
+			if a.skip > 0 {
+				return 0, nil
+			}
+			return 0, fmt.Errorf("exponent less than 0")
+		}
+		r := int64(1)
+		for ; y > 0; y-- {
+			r *= x
+		}
+		return r, nil
+	case "<<":
+		return x << uint64(y&63), nil
+	case ">>":
+		return x >> uint64(y&63), nil
+	case "<":
+		return boolInt(x < y), nil
+	case "<=":
+		return boolInt(x <= y), nil
+	case ">":
+		return boolInt(x > y), nil
+	case ">=":
+		return boolInt(x >= y), nil
+	case "==":
+		return boolInt(x == y), nil
+	case "!=":
+		return boolInt(x != y), nil
+	case "&":
+		return x & y, nil
+	case "^":
+		return x ^ y, nil
+	case "|":
+		return x | y, nil
+	case "&&":
+		return boolInt(x != 0 && y != 0), nil
+	case "||":
+		return boolInt(x != 0 || y != 0), nil
+	}
+	return 0, fmt.Errorf("unknown operator %s", op)
+}
+
+// increment — значение после ++ или --
+func increment(v int64, op string) int64 {
+	if op == "++" {
+		return v + 1
+	}
+	return v - 1
+}
+
+// boolInt — 1 для истины, 0 для лжи
+func boolInt(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
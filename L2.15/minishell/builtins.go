@@ -0,0 +1,468 @@
+package minishell
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"unicode/utf8"
+)
+
+// pathLookup — исполняемые файлы name из PATH; имя с / проверяется как есть. all — все, иначе первый
+func (sh *Interpreter) pathLookup(name string, all bool) []string {
+	if strings.Contains(name, "/") {
+		if sh.isExecutable(name) {
+			return []string{name}
+		}
+		return nil
+	}
+
+	var found []string
+	path, _ := sh.lookupVar("PATH")
+	for _, dir := range filepath.SplitList(path) {
+		if dir == "" {
+			dir = "."
+		}
+		if file := filepath.Join(dir, name); sh.isExecutable(file) {
+			found = append(found, file)
+			if !all {
+				break
+			}
+		}
+	}
+	return found
+}
+
+// commandKinds — чем является name: alias, keyword, function, builtin, file — в порядке поиска.
+// Для file — путь. all — все варианты, иначе первый
+func (sh *Interpreter) commandKinds(name string, all bool) (kinds, paths []string) {
+	add := func(kind, path string) {
+		kinds, paths = append(kinds, kind), append(paths, path)
+	}
+	if sh.hasAlias(name) {
+		add("alias", "")
+	}
+	if slices.Contains(shellKeywords, name) {
+		add("keyword", "")
+	}
+	if sh.functions[name] != nil {
+		add("function", "")
+	}
+	if sh.isBuiltin(name) {
+		add("builtin", "")
+	}
+	if len(kinds) == 0 || all {
+		for _, file := range sh.pathLookup(name, all) {
+			add("file", file)
+		}
+	}
+	if !all && len(kinds) > 1 {
+		kinds, paths = kinds[:1], paths[:1]
+	}
+	return kinds, paths
+}
+
+// describeCommand — строка type о том, чем является name
+func (sh *Interpreter) describeCommand(name, kind, path string) string {
+	switch kind {
+	case "alias":
+		return fmt.Sprintf("%s is aliased to `%s'", name, sh.aliases[name])
+	case "keyword":
+		return name + " is a shell keyword"
+	case "function":
+		return name + " is a function"
+	case "builtin":
+		return name + " is a shell builtin"
+	}
+	return name + " is " + path
+}
+
+// builtinType — type [-a] [-t | -p] name...: алиас, ключевое слово, функция, builtin или файл.
+// -t — только вид, -p — только путь к файлу, -a — все варианты. Код 1, если что-то не найдено
+func (sh *Interpreter) builtinType(args []string, out io.Writer) (int, error) {
+	all, kindOnly, pathOnly := false, false, false
+	for len(args) > 0 && len(args[0]) > 1 && args[0][0] == '-' {
+		if args[0] == "--" {
+			args = args[1:]
+			break
+		}
+		for _, c := range args[0][1:] {
+			switch c {
+			case 'a':
+				all = true
+			case 't':
+				kindOnly = true
+			case 'p':
+				pathOnly = true
+			default:
+				return 2, fmt.Errorf("type: -%c: invalid option", c)
+			}
+		}
+		args = args[1:]
+	}
+
+	code := 0
+	var err error
+	for _, name := range args {
+		kinds, paths := sh.commandKinds(name, all)
+		if len(kinds) == 0 {
+			code = 1
+			if !kindOnly && !pathOnly {
+				err = errors.Join(err, fmt.Errorf("type: %s: not found", name))
+			}
+			continue
+		}
+		for i, kind := range kinds {
+			switch {
+			case kindOnly:
+				fmt.Fprintln(out, kind)
+			case pathOnly:
+				if kind == "file" {
+					fmt.Fprintln(out, paths[i])
+				}
+			default:
+				fmt.Fprintln(out, sh.describeCommand(name, kind, paths[i]))
+			}
+		}
+	}
+	return code, err
+}
+
+// commandPrefix — слова команды после command без -v и -V: она выполняется в обход функций
+func commandPrefix(args []string) ([]string, bool) {
+	if len(args) < 2 || args[0] != "command" {
+		return nil, false
+	}
+	rest := args[1:]
+	for len(rest) > 0 && (rest[0] == "-p" || rest[0] == "--") {
+		rest = rest[1:]
+	}
+	if len(rest) == 0 || rest[0] == "-v" || rest[0] == "-V" {
+		return nil, false
+	}
+	return rest, true
+}
+
+// builtinCommand — command -v name... печатает, как будет выполнено имя: путь к файлу, имя builtin,
+// функции или ключевого слова, определение алиаса. command -V — описание, как у type.
+// command name args выполняется в runSingle
+func (sh *Interpreter) builtinCommand(args []string, out io.Writer) (int, error) {
+	for len(args) > 0 && (args[0] == "-p" || args[0] == "--") {
+		args = args[1:]
+	}
+	if len(args) == 0 {
+		return 0, nil
+	}
+	verbose := args[0] == "-V"
+	if !verbose && args[0] != "-v" {
+		return 1, fmt.Errorf("command: %s: not found", args[0])
+	}
+
+	code := 0
+	var err error
+	for _, name := range args[1:] {
+		kinds, paths := sh.commandKinds(name, false)
+		switch {
+		case len(kinds) == 0:
+			code = 1
+			if verbose {
+				err = errors.Join(err, fmt.Errorf("command: %s: not found", name))
+			}
+		case verbose:
+			fmt.Fprintln(out, sh.describeCommand(name, kinds[0], paths[0]))
+		case kinds[0] == "alias":
+			sh.printAlias(out, name)
+		case kinds[0] == "file":
+			fmt.Fprintln(out, paths[0])
+		default:
+			fmt.Fprintln(out, name)
+		}
+	}
+	return code, err
+}
+
+// builtinWhich — which [-a] name...: пути к программам из PATH. Код 1, если какая-то не найдена
+func (sh *Interpreter) builtinWhich(args []string, out io.Writer) (int, error) {
+	all := len(args) > 0 && args[0] == "-a"
+	if all {
+		args = args[1:]
+	}
+
+	code := 0
+	for _, name := range args {
+		found := sh.pathLookup(name, all)
+		if len(found) == 0 {
+			code = 1
+		}
+		for _, file := range found {
+			fmt.Fprintln(out, file)
+		}
+	}
+	return code, nil
+}
+
+// builtinRead — read [-r] [-p prompt] [-d delim] [-n count] [name...]: читает строку из in и делит её по IFS
+// между переменными, последняя получает остаток. Без имён строка целиком попадает в REPLY.
+// Без -r \ экранирует следующий символ, \ перед переводом строки продолжает строку.
+// Код 1 — ввод закончился раньше разделителя
+func (sh *Interpreter) builtinRead(args []string, in io.Reader) (int, error) {
+	raw := false
+	prompt := ""
+	delim := byte('\n')
+	count := -1 // -n: сколько символов читать
+
+	for len(args) > 0 && len(args[0]) > 1 && args[0][0] == '-' {
+		opt := args[0]
+		args = args[1:]
+		if opt == "--" {
+			break
+		}
+		if opt == "-r" {
+			raw = true
+			continue
+		}
+		if len(opt) != 2 || !strings.ContainsRune("pdn", rune(opt[1])) {
+			return 2, fmt.Errorf("read: %s: invalid option", opt)
+		}
+		if len(args) == 0 {
+			return 2, fmt.Errorf("read: %s: option requires an argument", opt)
+		}
+		val := args[0]
+		args = args[1:]
+		switch opt[1] {
+		case 'p':
+			prompt = val
+		case 'd':
+			delim = 0 // -d '' — до нулевого байта
+			if val != "" {
+				delim = val[0]
+			}
+		case 'n':
+			n, err := strconv.Atoi(val)
+			if err != nil || n < 0 {
+				return 2, fmt.Errorf("read: %s: invalid number", val)
+			}
+			count = n
+		}
+	}
+	for _, name := range args {
+		if !isName(name) {
+			return 1, fmt.Errorf("read: `%s': not a valid identifier", name)
+		}
+	}
+	if in == nil {
+		return 1, fmt.Errorf("read: read error: bad file descriptor")
+	}
+
+	// приглашение — только когда читаем с терминала
+	if f, ok := in.(*os.File); ok && prompt != "" && isTerminal(int(f.Fd())) {
+		fmt.Fprint(sh.errOut(), prompt)
+	}
+
+	// по одному байту, чтобы не забрать ввод следующих команд
+	var text []byte
+	var escaped []bool // символ экранирован \ и не разделяет поля
+	var err error
+	last := 0 // начало последнего символа, для -n
+	for count < 0 || utf8.RuneCount(text) < count || len(text) > last && !utf8.FullRune(text[last:]) {
+		var c byte
+		if c, err = readByte(in); err != nil {
+			break
+		}
+		if c == delim {
+			break
+		}
+
+		esc := false
+		if c == '\\' && !raw {
+			if c, err = readByte(in); err != nil {
+				break
+			}
+			if c == '\n' {
+				continue
+			}
+			esc = true
+		}
+		if esc || utf8.RuneStart(c) {
+			last = len(text)
+		}
+		text, escaped = append(text, c), append(escaped, esc)
+	}
+	if err != nil && !errors.Is(err, io.EOF) {
+		return 1, fmt.Errorf("read: %w", err)
+	}
+
+	if len(args) == 0 {
+		sh.setVar("REPLY", string(text))
+	} else {
+		for i, field := range sh.readFields(text, escaped, len(args)) {
+			sh.setVar(args[i], field)
+		}
+	}
+	if err != nil {
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// readByte — один байт из r
+func readByte(r io.Reader) (byte, error) {
+	var b [1]byte
+	for {
+		n, err := r.Read(b[:])
+		if n == 1 {
+			return b[0], nil
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+}
+
+// readFields — строка read, разделённая по IFS на n полей: пробельные символы IFS по краям отбрасываются,
+// последнее поле — весь остаток. Недостающие поля пустые
+func (sh *Interpreter) readFields(text []byte, escaped []bool, n int) []string {
+	ifs := sh.fieldSeparators()
+	isSep := func(i int) bool {
+		return !escaped[i] && strings.IndexByte(ifs, text[i]) >= 0
+	}
+	isWhite := func(i int) bool {
+		return isSep(i) && strings.IndexByte(" \t\n", text[i]) >= 0
+	}
+
+	// skipSep — разделитель полей: пробельные символы IFS и не больше одного непробельного
+	skipSep := func(i int) int {
+		for i < len(text) && isWhite(i) {
+			i++
+		}
+		if i < len(text) && isSep(i) {
+			i++
+			for i < len(text) && isWhite(i) {
+				i++
+			}
+		}
+		return i
+	}
+
+	fields := make([]string, n)
+	i := 0
+	for i < len(text) && isWhite(i) {
+		i++
+	}
+	for k := range n {
+		if k == n-1 {
+			end := len(text)
+			for end > i && isWhite(end-1) {
+				end--
+			}
+			fields[k] = string(text[i:end])
+			break
+		}
+		start := i
+		for i < len(text) && !isSep(i) {
+			i++
+		}
+		fields[k] = string(text[start:i])
+		i = skipSep(i)
+	}
+	return fields
+}
+
+// builtinUmask — umask [-S] [mode]: без mode печатает маску (-S — в виде u=rwx,g=rx,o=rx),
+// mode — восьмеричный или символьный, как у chmod
+func builtinUmask(args []string, out io.Writer) (int, error) {
+	symbolic := len(args) > 0 && args[0] == "-S"
+	if symbolic {
+		args = args[1:]
+	}
+
+	mask := syscall.Umask(0)
+	syscall.Umask(mask)
+
+	if len(args) == 0 {
+		if !symbolic {
+			fmt.Fprintf(out, "%04o\n", mask)
+			return 0, nil
+		}
+		perms := ^mask & 0o777
+		var parts []string
+		for i, who := range []string{"u", "g", "o"} {
+			bits := perms >> (6 - 3*i) & 7
+			s := who + "="
+			for j, c := range "rwx" {
+				if bits&(4>>j) != 0 {
+					s += string(c)
+				}
+			}
+			parts = append(parts, s)
+		}
+		fmt.Fprintln(out, strings.Join(parts, ","))
+		return 0, nil
+	}
+
+	if v, err := strconv.ParseUint(args[0], 8, 32); err == nil {
+		if v > 0o777 {
+			return 1, fmt.Errorf("umask: %s: octal number out of range", args[0])
+		}
+		syscall.Umask(int(v))
+		return 0, nil
+	}
+	if args[0] != "" && args[0][0] >= '0' && args[0][0] <= '9' {
+		return 1, fmt.Errorf("umask: %s: octal number out of range", args[0])
+	}
+
+	perms, err := symbolicMode(args[0], ^mask&0o777)
+	if err != nil {
+		return 1, err
+	}
+	syscall.Umask(^perms & 0o777)
+	return 0, nil
+}
+
+// symbolicMode — права perms после символьной записи [ugoa]*[=+-][rwx]*, через запятую
+func symbolicMode(mode string, perms int) (int, error) {
+	for _, clause := range strings.Split(mode, ",") {
+		i := 0
+		who := 0
+		for ; i < len(clause) && strings.IndexByte("ugoa", clause[i]) >= 0; i++ {
+			who |= map[byte]int{'u': 0o700, 'g': 0o070, 'o': 0o007, 'a': 0o777}[clause[i]]
+		}
+		if who == 0 {
+			who = 0o777
+		}
+		if i == len(clause) || strings.IndexByte("=+-", clause[i]) < 0 {
+			return 0, fmt.Errorf("umask: %s: invalid symbolic mode", mode)
+		}
+		op := clause[i]
+
+		bits := 0
+		for _, c := range clause[i+1:] {
+			switch c {
+			case 'r':
+				bits |= 0o444
+			case 'w':
+				bits |= 0o222
+			case 'x':
+				bits |= 0o111
+			default:
+				return 0, fmt.Errorf("umask: `%c': invalid symbolic mode character", c)
+			}
+		}
+		bits &= who
+
+		switch op {
+		case '=':
+			perms = perms&^who | bits
+		case '+':
+			perms |= bits
+		case '-':
+			perms &^= bits
+		}
+	}
+	return perms, nil
+}
@@ -0,0 +1,260 @@
+package minishell
+
+import (
+	"io"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// shellKeywords — зарезервированные слова; после них снова идёт имя команды
+var shellKeywords = []string{
+	"!", "{", "}", "case", "do", "done", "elif", "else", "esac", "fi", "for", "function", "if", "in",
+	"then", "time", "until", "while",
+}
+
+// complete — дополнение слова перед курсором по Tab: единственный вариант подставляется целиком,
+// несколько — до общего начала. Если дополнить нечего, повторный Tab показывает варианты
+func (e *lineEditor) complete(again bool) {
+	line := string(e.buf[:e.pos])
+	start, word, command := completionWord(line)
+	cands := e.sh.completions(word, command)
+	start = utf8.RuneCountInString(line[:start])
+
+	switch {
+	case len(cands) == 0:
+		io.WriteString(e.out, "\a")
+
+	case len(cands) == 1:
+		text := escapeCompletion(cands[0])
+		if !strings.HasSuffix(text, "/") {
+			text += " "
+		}
+		e.replaceWord(start, text)
+
+	case len(commonPrefix(cands)) > len(word):
+		e.replaceWord(start, escapeCompletion(commonPrefix(cands)))
+
+	case again:
+		e.listCandidates(cands)
+
+	default:
+		io.WriteString(e.out, "\a")
+	}
+}
+
+// replaceWord — заменяет текст от start до курсора
+func (e *lineEditor) replaceWord(start int, text string) {
+	e.cut(start, e.pos)
+	e.insert([]rune(text))
+}
+
+// listCandidates — печатает варианты колонками под строкой и заново выводит приглашение
+func (e *lineEditor) listCandidates(cands []string) {
+	names := make([]string, len(cands))
+	width := 0
+	for i, c := range cands {
+		names[i] = c
+		if j := strings.LastIndexByte(strings.TrimSuffix(c, "/"), '/'); j >= 0 {
+			names[i] = c[j+1:]
+		}
+		width = max(width, utf8.RuneCountInString(names[i])+2)
+	}
+
+	cols := 80
+	if e.fd >= 0 {
+		cols = terminalWidth(e.fd)
+	}
+	perLine := max(cols/width, 1)
+
+	var b strings.Builder
+	b.WriteString("\r\n")
+	for i, name := range names {
+		b.WriteString(name)
+		if (i+1)%perLine == 0 || i == len(names)-1 {
+			b.WriteString("\r\n")
+		} else {
+			b.WriteString(strings.Repeat(" ", width-utf8.RuneCountInString(name)))
+		}
+	}
+	b.WriteString(e.prompt)
+	io.WriteString(e.out, b.String())
+}
+
+// completionWord — дополняемое слово в конце line: позиция его начала, текст без кавычек и \
+// и стоит ли оно на месте имени команды
+func completionWord(line string) (start int, word string, command bool) {
+	var b strings.Builder
+	inWord := false
+	command = true
+	var quote byte
+
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			} else {
+				b.WriteByte(c)
+			}
+			continue
+
+		case isSpace(c) || c == '\n' || strings.IndexByte(";|&()<>", c) >= 0:
+			if inWord {
+				// после ключевого слова или присваивания — снова имя команды
+				w := b.String()
+				command = command && (slices.Contains(shellKeywords, w) || isAssignment(w))
+				inWord = false
+				b.Reset()
+			}
+			switch {
+			case strings.IndexByte(";|&(", c) >= 0:
+				command = true
+			case c == '<' || c == '>':
+				command = false
+			}
+			continue
+		}
+
+		if !inWord {
+			inWord, start = true, i
+		}
+		switch c {
+		case '\\':
+			if i+1 < len(line) {
+				i++
+				b.WriteByte(line[i])
+			}
+		case '\'', '"':
+			quote = c
+		default:
+			b.WriteByte(c)
+		}
+	}
+
+	if !inWord {
+		return len(line), "", command
+	}
+	return start, b.String(), command
+}
+
+// isAssignment — слово вида NAME=value
+func isAssignment(w string) bool {
+	name, _, ok := strings.Cut(w, "=")
+	return ok && isName(name)
+}
+
+// completions — варианты дополнения word: имена команд на месте команды, иначе пути.
+// Каталоги заканчиваются на /
+func (sh *Interpreter) completions(word string, command bool) []string {
+	if command && !strings.Contains(word, "/") {
+		return sh.commandNames(word)
+	}
+	return sh.pathNames(word, command)
+}
+
+// commandNames — ключевые слова, встроенные команды, функции и программы из PATH, начинающиеся с prefix
+func (sh *Interpreter) commandNames(prefix string) []string {
+	seen := map[string]bool{}
+	add := func(name string) {
+		if strings.HasPrefix(name, prefix) {
+			seen[name] = true
+		}
+	}
+
+	for _, name := range shellKeywords {
+		add(name)
+	}
+	for _, name := range builtinNames {
+		add(name)
+	}
+	for name := range sh.builtins {
+		add(name)
+	}
+	for name := range sh.functions {
+		add(name)
+	}
+	path, _ := sh.lookupVar("PATH")
+	for _, dir := range filepath.SplitList(path) {
+		if dir == "" {
+			dir = "."
+		}
+		for _, name := range sh.readDirNames(dir + "/") {
+			if strings.HasPrefix(name, prefix) && sh.isExecutable(dir+"/"+name) {
+				seen[name] = true
+			}
+		}
+	}
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// pathNames — пути, начинающиеся с word; ~ в начале раскрывается только для поиска.
+// execOnly — только каталоги и исполняемые файлы
+func (sh *Interpreter) pathNames(word string, execOnly bool) []string {
+	dir, base := "", word
+	if i := strings.LastIndexByte(word, '/'); i >= 0 {
+		dir, base = word[:i+1], word[i+1:]
+	}
+	lookDir := dir
+	if home, n := sh.tildePrefix(dir, false); n > 0 {
+		lookDir = home + dir[n:]
+	}
+
+	var out []string
+	for _, name := range sh.readDirNames(lookDir) {
+		if !strings.HasPrefix(name, base) || strings.HasPrefix(name, ".") && !strings.HasPrefix(base, ".") {
+			continue
+		}
+		switch path := lookDir + name; {
+		case sh.isDir(path):
+			out = append(out, dir+name+"/")
+		case !execOnly || sh.isExecutable(path):
+			out = append(out, dir+name)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// isExecutable — обычный файл с правом на выполнение
+func (sh *Interpreter) isExecutable(path string) bool {
+	fi, err := sh.stat(path)
+	return err == nil && fi.Mode().IsRegular() && fi.Mode()&0o111 != 0
+}
+
+// commonPrefix — общее начало строк, не разрезающее символы UTF-8
+func commonPrefix(list []string) string {
+	prefix := list[0]
+	for _, s := range list[1:] {
+		n := 0
+		for n < len(prefix) && n < len(s) && prefix[n] == s[n] {
+			n++
+		}
+		prefix = prefix[:n]
+	}
+	for !utf8.ValidString(prefix) {
+		prefix = prefix[:len(prefix)-1]
+	}
+	return prefix
+}
+
+// escapeCompletion — дополнение в виде слова shell: спецсимволы экранируются \. ~ не экранируется — ~/ в начале раскрывается
+func escapeCompletion(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if strings.IndexByte(" \t\n'\"\\$`!&;|<>()*?[]{}#", s[i]) >= 0 {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
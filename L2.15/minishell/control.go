@@ -0,0 +1,211 @@
+package minishell
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// flowKind — прерывание выполнения: break, continue, return или exit
+type flowKind int
+
+const (
+	flowNone     flowKind = iota
+	flowBreak             // break — выйти из цикла
+	flowContinue          // continue — следующая итерация цикла
+	flowReturn            // return — выйти из функции
+	flowExit              // exit — завершить shell
+)
+
+// runCompound — составная команда в текущем shell; её перенаправления действуют на все команды внутри
+func (sh *Interpreter) runCompound(n compoundNode, fds []*os.File) (int, error) {
+	cfds, opened, err := sh.stageFiles(fds, n.redirects())
+	if err != nil {
+		return 1, err
+	}
+	defer closeFiles(opened)
+
+	switch n := n.(type) {
+	case *Group:
+		return sh.runList(n.Body, cfds), nil
+	case *IfNode:
+		return sh.runIf(n, cfds), nil
+	case *LoopNode:
+		return sh.runLoop(n, cfds), nil
+	case *ForNode:
+		return sh.runFor(n, cfds), nil
+	case *CaseNode:
+		return sh.runCase(n, cfds), nil
+	}
+	return 1, fmt.Errorf("unknown compound command %T", n)
+}
+
+// runIf — if/elif/else; без подходящей ветки код 0
+func (sh *Interpreter) runIf(n *IfNode, fds []*os.File) int {
+	status := sh.ignoreErrexit(func() int { return sh.runList(n.Cond, fds) })
+	if sh.flow != flowNone {
+		return status
+	}
+	if status == 0 {
+		return sh.runList(n.Then, fds)
+	}
+
+	switch e := n.Else.(type) {
+	case *ListNode:
+		return sh.runList(e, fds)
+	case *IfNode:
+		return sh.runIf(e, fds)
+	}
+	return 0
+}
+
+// runLoop — while и until; код — последнего выполнения тела, 0 если тело не выполнялось
+func (sh *Interpreter) runLoop(n *LoopNode, fds []*os.File) int {
+	sh.loopDepth++
+	defer func() { sh.loopDepth-- }()
+
+	status := 0
+	for {
+		cond := sh.ignoreErrexit(func() int { return sh.runList(n.Cond, fds) })
+		if sh.flow != flowNone {
+			if sh.loopControl() {
+				break
+			}
+			continue
+		}
+		if (cond == 0) == n.Until {
+			break
+		}
+
+		status = sh.runList(n.Body, fds)
+		if sh.flow != flowNone && sh.loopControl() {
+			break
+		}
+	}
+	return status
+}
+
+// runFor — тело для каждого слова; без in — для каждого позиционного параметра
+func (sh *Interpreter) runFor(n *ForNode, fds []*os.File) int {
+	words := append([]string(nil), sh.positional...)
+	if n.In {
+		words = sh.expandArgs(n.Words)
+		if code, err := sh.expansionFailed(); err != nil {
+			return reportError(code, err, fds)
+		}
+	}
+
+	sh.loopDepth++
+	defer func() { sh.loopDepth-- }()
+
+	status := 0
+	for _, w := range words {
+		sh.trace(fds, nil, append([]string{"for", n.Var, "in"}, words...))
+		sh.setVar(n.Var, w)
+		status = sh.runList(n.Body, fds)
+		if sh.flow != flowNone && sh.loopControl() {
+			break
+		}
+	}
+	return status
+}
+
+// runCase — первая ветка, один из шаблонов которой совпал со словом; без совпадений код 0
+func (sh *Interpreter) runCase(n *CaseNode, fds []*os.File) int {
+	word := sh.expandWord(n.Word)
+	if code, err := sh.expansionFailed(); err != nil {
+		return reportError(code, err, fds)
+	}
+	sh.trace(fds, nil, []string{"case", word, "in"})
+	for _, item := range n.Items {
+		for _, pat := range item.Patterns {
+			if matchPattern(sh.expandPattern(pat), word) {
+				return sh.runList(item.Body, fds)
+			}
+		}
+	}
+	return 0
+}
+
+// loopControl — break или continue дошли до цикла; true — из цикла надо выйти.
+// return, exit и break/continue для внешних циклов пропускаются дальше
+func (sh *Interpreter) loopControl() bool {
+	switch sh.flow {
+	case flowBreak, flowContinue:
+		sh.flowCount--
+		if sh.flowCount > 0 {
+			return true
+		}
+		stop := sh.flow == flowBreak
+		sh.flow = flowNone
+		return stop
+	case flowReturn, flowExit:
+		return true
+	}
+	return false
+}
+
+// callFunction — вызов функции: аргументы становятся позиционными параметрами,
+// return завершает тело
+func (sh *Interpreter) callFunction(fn *FuncDef, args []string, redirs []Redirect, fds []*os.File) (int, error) {
+	callFds, opened, err := sh.stageFiles(fds, redirs)
+	if err != nil {
+		return 1, err
+	}
+	defer closeFiles(opened)
+
+	savedArgs, savedLoops := sh.positional, sh.loopDepth
+	sh.positional, sh.loopDepth = args, 0
+	sh.funcDepth++
+	defer func() {
+		sh.positional, sh.loopDepth = savedArgs, savedLoops
+		sh.funcDepth--
+	}()
+
+	status := sh.runNode(&PipelineNode{Cmds: []Node{fn.Body}}, callFds)
+	if sh.flow == flowReturn {
+		sh.flow = flowNone
+	}
+	return status, nil
+}
+
+// builtinLoopControl — break [N] и continue [N]
+func (sh *Interpreter) builtinLoopControl(name string, args []string) (int, error) {
+	if sh.loopDepth == 0 {
+		return 1, fmt.Errorf("%s: only meaningful in a `for', `while', or `until' loop", name)
+	}
+
+	count := 1
+	if len(args) > 0 {
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n < 1 {
+			return 1, fmt.Errorf("%s: %s: loop count out of range", name, args[0])
+		}
+		count = min(n, sh.loopDepth)
+	}
+
+	sh.flow, sh.flowCount = flowBreak, count
+	if name == "continue" {
+		sh.flow = flowContinue
+	}
+	return 0, nil
+}
+
+// builtinReturn — return [N] из функции или файла source; без N — код последней команды
+func (sh *Interpreter) builtinReturn(args []string) (int, error) {
+	if sh.funcDepth == 0 && sh.sourceDepth == 0 {
+		return 1, fmt.Errorf("return: can only `return' from a function or sourced script")
+	}
+
+	code := sh.lastStatus
+	if len(args) > 0 {
+		n, err := strconv.Atoi(args[0])
+		if err != nil {
+			return 2, fmt.Errorf("return: %s: numeric argument required", args[0])
+		}
+		code = n & 0xff
+	}
+
+	sh.flow = flowReturn
+	return code, nil
+}
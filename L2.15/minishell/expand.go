@@ -0,0 +1,421 @@
+package minishell
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// expander — собирает результат раскрытия слова: снимает кавычки, подставляет параметры
+// и, если нужно, разбивает результат на поля
+type expander struct {
+	sh      *Interpreter
+	fields  []string
+	buf     strings.Builder
+	started bool // текущее поле есть, даже если пустое: "" или ''
+	noField bool // "$@" без параметров — кавычки вокруг него не дают пустого поля
+	split   bool // разбивать подстановки вне кавычек на поля по IFS
+	pattern bool // результат — шаблон: символы из кавычек экранируются \ и совпадают только сами с собой
+	assign  bool // значение присваивания: ~ раскрывается и после :
+}
+
+// expandArgs — раскрытия в словах команды по порядку: {a,b}, ~, параметры и подстановки, разбиение
+// на поля, шаблоны путей. Слов может стать больше или меньше
+func (sh *Interpreter) expandArgs(words []string) []string {
+	var args []string
+	for _, w := range words {
+		for _, b := range braceExpand(w) {
+			e := &expander{sh: sh, split: true, pattern: true}
+			e.word(b)
+			e.endField()
+			for _, f := range e.fields {
+				args = append(args, sh.globField(f)...)
+			}
+		}
+	}
+	return args
+}
+
+// expandWord — раскрывает слово в одну строку без разбиения на поля: снимает кавычки и \,
+// подставляет параметры вне кавычек и в "...", но не в '...'
+func (sh *Interpreter) expandWord(raw string) string {
+	e := &expander{sh: sh}
+	e.word(raw)
+	return e.buf.String()
+}
+
+// expandAssignment — раскрывает значение присваивания NAME=value: как expandWord, но ~ раскрывается и после :
+func (sh *Interpreter) expandAssignment(raw string) string {
+	e := &expander{sh: sh, assign: true}
+	e.word(raw)
+	return e.buf.String()
+}
+
+// expandPattern — раскрывает слово как шаблон (case, ${VAR%pattern})
+func (sh *Interpreter) expandPattern(raw string) string {
+	e := &expander{sh: sh, pattern: true}
+	e.word(raw)
+	return e.buf.String()
+}
+
+// expandEnv — подставляет параметры и подстановки команд в тексте без кавычек (тело here-doc).
+// Как в here-doc bash, \ снимает особый смысл только с $, ` и \, а \ с переводом строки удаляется
+func (sh *Interpreter) expandEnv(s string) string {
+	var buf strings.Builder
+	i := 0
+	n := len(s)
+
+	for i < n {
+		if s[i] == '\\' && i+1 < n && strings.IndexByte("$`\\\n", s[i+1]) >= 0 {
+			if s[i+1] != '\n' {
+				buf.WriteByte(s[i+1])
+			}
+			i += 2
+			continue
+		}
+		if v, k := sh.expandVar(s[i:]); k > 0 {
+			buf.WriteString(v)
+			i += k
+			continue
+		}
+
+		buf.WriteByte(s[i])
+		i++
+	}
+	return buf.String()
+}
+
+// expandVar — раскрывает параметр или подстановку в начале s ($VAR, ${VAR...}, $1, $?, $(...), `...`, $((...)))
+// в строку. n — длина раскрытой записи, 0 — это не подстановка
+func (sh *Interpreter) expandVar(s string) (value string, n int) {
+	if v, k := sh.substitute(s); k > 0 {
+		return v, k
+	}
+	name, op, word, n := scanParam(s)
+	if n == 0 {
+		return "", 0
+	}
+	return sh.paramValue(s[:n], name, op, word), n
+}
+
+// word — раскрывает исходный текст слова
+func (e *expander) word(raw string) {
+	i := 0
+	n := len(raw)
+	inDouble := false
+
+	for i < n {
+		c := raw[i]
+		switch {
+		case c == '\\' && i+1 < n && raw[i+1] == '\n': // продолжение строки
+			i += 2
+			continue
+
+		// ~ и ~user в начале слова, в присваивании — и после :
+		case c == '~' && !inDouble && (i == 0 || e.assign && raw[i-1] == ':'):
+			if home, k := e.sh.tildePrefix(raw[i:], e.assign); k > 0 {
+				e.literal(home)
+				i += k
+				continue
+			}
+
+		case c == '\\' && i+1 < n && (!inDouble || strings.IndexByte("$`\"\\", raw[i+1]) >= 0):
+			e.literal(raw[i+1 : i+2])
+			i += 2
+			continue
+
+		case c == '"':
+			if inDouble && !e.noField {
+				e.started = true
+			}
+			inDouble = !inDouble
+			e.noField = false
+			i++
+			continue
+
+		case c == '\'' && !inDouble:
+			end := strings.IndexByte(raw[i+1:], '\'')
+			if end < 0 { // токенизатор такого не пропускает
+				end = n - i - 1
+			}
+			e.literal(raw[i+1 : i+1+end])
+			i += end + 2
+			continue
+
+		case c == '$' || c == '`':
+			if k := e.param(raw[i:], inDouble); k > 0 {
+				i += k
+				continue
+			}
+		}
+
+		if inDouble {
+			e.literal(raw[i : i+1])
+		} else {
+			e.plain(raw[i : i+1])
+		}
+		i++
+	}
+}
+
+// param — подстановка параметра или команды в начале s; quoted — внутри "...".
+// Возвращает длину записи, 0 — это не подстановка
+func (e *expander) param(s string, quoted bool) int {
+	name, op, word, n := scanParam(s)
+	if n == 0 {
+		v, k := e.sh.substitute(s)
+		if k > 0 {
+			e.value(v, quoted)
+		}
+		return k
+	}
+
+	// $@ и $* — каждый позиционный параметр отдельным полем, "$*" — одним
+	if (name == "@" || name == "*") && op == "" && e.split && !(quoted && name == "*") {
+		for i, p := range e.sh.positional {
+			if i > 0 {
+				e.endField()
+			}
+			if quoted {
+				e.literal(p)
+			} else {
+				e.unquoted(p)
+			}
+		}
+		e.noField = quoted && len(e.sh.positional) == 0
+		return n
+	}
+
+	e.value(e.sh.paramValue(s[:n], name, op, word), quoted)
+	return n
+}
+
+// value — результат подстановки: в кавычках как есть, вне кавычек — с разбиением на поля
+func (e *expander) value(v string, quoted bool) {
+	if quoted {
+		e.literal(v)
+	} else {
+		e.unquoted(v)
+	}
+}
+
+// plain — текст вне кавычек; в шаблоне его *, ? и [ работают, а \ — обычный символ
+func (e *expander) plain(s string) {
+	if s == "" {
+		return
+	}
+	if e.pattern {
+		s = strings.ReplaceAll(s, `\`, `\\`)
+	}
+	e.buf.WriteString(s)
+	e.started = true
+}
+
+// literal — текст из кавычек; в шаблоне его спецсимволы экранируются
+func (e *expander) literal(s string) {
+	e.started = true
+	for j := 0; j < len(s); j++ {
+		if e.pattern && strings.IndexByte("*?[]\\", s[j]) >= 0 {
+			e.buf.WriteByte('\\')
+		}
+		e.buf.WriteByte(s[j])
+	}
+}
+
+// unquoted — результат подстановки вне кавычек: при разбиении символы IFS разделяют поля
+func (e *expander) unquoted(v string) {
+	if !e.split {
+		e.plain(v)
+		return
+	}
+
+	ifs := e.sh.fieldSeparators()
+	start := 0
+	for i := 0; i < len(v); i++ {
+		if strings.IndexByte(ifs, v[i]) >= 0 {
+			e.plain(v[start:i])
+			e.endField()
+			start = i + 1
+		}
+	}
+	e.plain(v[start:])
+}
+
+// endField — закончить текущее поле
+func (e *expander) endField() {
+	if e.started {
+		e.fields = append(e.fields, e.buf.String())
+	}
+	e.buf.Reset()
+	e.started = false
+}
+
+// fieldSeparators — символы IFS, по умолчанию пробел, табуляция и перевод строки
+func (sh *Interpreter) fieldSeparators() string {
+	if ifs, ok := sh.lookupVar("IFS"); ok {
+		return ifs
+	}
+	return " \t\n"
+}
+
+// scanParam — разбирает подстановку в начале s: $NAME, $1, $?, ${NAME}, ${#NAME}, ${NAME<op>word}.
+// n — длина записи, 0 — это не подстановка
+func scanParam(s string) (name, op, word string, n int) {
+	if len(s) < 2 || s[0] != '$' {
+		return "", "", "", 0
+	}
+
+	c := s[1]
+	switch {
+	case c == '{':
+		j := closing(s, 2, '}')
+		if j < 0 {
+			return "", "", "", 0
+		}
+		inner := s[2:j]
+
+		// ${#NAME} — длина значения; ${#} — число параметров
+		if len(inner) > 1 && inner[0] == '#' {
+			return inner[1:], "#len", "", j + 1
+		}
+
+		name = paramName(inner)
+		rest := inner[len(name):]
+
+		// ${NAME[индекс]} — элемент массива
+		if isName(name) && strings.HasPrefix(rest, "[") {
+			if k := strings.IndexByte(rest, ']'); k > 0 {
+				name, rest = name+rest[:k+1], rest[k+1:]
+			}
+		}
+		for _, o := range []string{":-", ":=", ":+", "%%", "##", "-", "=", "+", "%", "#"} {
+			if strings.HasPrefix(rest, o) {
+				return name, o, rest[len(o):], j + 1
+			}
+		}
+		if rest != "" || name == "" {
+			return inner, "bad", "", j + 1
+		}
+		return name, "", "", j + 1
+
+	case strings.IndexByte("?$!#@*", c) >= 0 || c >= '0' && c <= '9':
+		return s[1:2], "", "", 2
+	}
+
+	name = paramName(s[1:])
+	if name == "" {
+		return "", "", "", 0
+	}
+	return name, "", "", len(name) + 1
+}
+
+// paramName — имя параметра в начале s: имя переменной, номер позиционного параметра или спецсимвол
+func paramName(s string) string {
+	if s == "" {
+		return ""
+	}
+	if strings.IndexByte("?$!#@*-", s[0]) >= 0 {
+		return s[:1]
+	}
+
+	j := 0
+	if s[0] >= '0' && s[0] <= '9' {
+		for j < len(s) && s[j] >= '0' && s[j] <= '9' {
+			j++
+		}
+		return s[:j]
+	}
+	for j < len(s) && (isAlnum(s[j]) || s[j] == '_') {
+		j++
+	}
+	return s[:j]
+}
+
+// paramValue — значение подстановки: параметр name с оператором op и словом word. src — запись целиком, для ошибок
+func (sh *Interpreter) paramValue(src, name, op, word string) string {
+	v, set := sh.lookupVar(name)
+	// set -u: незаданный параметр — ошибка, если у подстановки нет значения по умолчанию. $@ и $* можно и без параметров
+	if !set && sh.setOptions["nounset"] && name != "@" && name != "*" && !strings.ContainsAny(op, "-=+") && sh.expandErr == nil {
+		sh.expandErr = fmt.Errorf("%s: %w", name, errUnbound)
+	}
+
+	switch op {
+	case "":
+		return v
+
+	case "#len":
+		// ${#NAME[@]} — число элементов массива
+		if base, sub, ok := strings.Cut(name, "["); ok && (sub == "@]" || sub == "*]") {
+			return strconv.Itoa(len(sh.arrayValues(base)))
+		}
+		return strconv.Itoa(utf8.RuneCountInString(v))
+
+	case "-", ":-": // значение по умолчанию
+		if !set || op == ":-" && v == "" {
+			return sh.expandWord(word)
+		}
+		return v
+
+	case "=", ":=": // значение по умолчанию с присваиванием
+		if !set || op == ":=" && v == "" {
+			if !isName(name) {
+				fmt.Fprintf(sh.errOut(), "%s: cannot assign in this way\n", src)
+				return ""
+			}
+			v = sh.expandWord(word)
+			sh.setVar(name, v)
+		}
+		return v
+
+	case "+", ":+": // другое значение, если параметр задан
+		if set && !(op == ":+" && v == "") {
+			return sh.expandWord(word)
+		}
+		return ""
+
+	case "%", "%%", "#", "##": // удаление суффикса или префикса по шаблону
+		return trimPattern(v, sh.expandPattern(word), op)
+	}
+
+	fmt.Fprintf(sh.errOut(), "%s: bad substitution\n", src)
+	return ""
+}
+
+// trimPattern — удаляет из v суффикс (%, %% — самый длинный) или префикс (#, ## — самый длинный), совпавший с шаблоном
+func trimPattern(v, pattern, op string) string {
+	// границы символов: от пустого префикса до всей строки
+	bounds := []int{}
+	for i := range v {
+		bounds = append(bounds, i)
+	}
+	bounds = append(bounds, len(v))
+
+	switch op {
+	case "%": // самый короткий суффикс — перебираем с конца
+		for k := len(bounds) - 1; k >= 0; k-- {
+			if matchPattern(pattern, v[bounds[k]:]) {
+				return v[:bounds[k]]
+			}
+		}
+	case "%%":
+		for _, i := range bounds {
+			if matchPattern(pattern, v[i:]) {
+				return v[:i]
+			}
+		}
+	case "#":
+		for _, i := range bounds {
+			if matchPattern(pattern, v[:i]) {
+				return v[i:]
+			}
+		}
+	case "##":
+		for k := len(bounds) - 1; k >= 0; k-- {
+			if matchPattern(pattern, v[:bounds[k]]) {
+				return v[bounds[k]:]
+			}
+		}
+	}
+	return v
+}
@@ -0,0 +1,396 @@
+package minishell
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// defaultShellOpts — настройки раскрытия шаблонов нового интерпретатора, меняются через shopt
+func defaultShellOpts() map[string]bool {
+	return map[string]bool{
+		"globstar": false, // ** совпадает с любым числом каталогов
+		"nullglob": false, // шаблон без совпадений исчезает, а не остаётся как есть
+		"dotglob":  false, // * и ? совпадают со скрытыми файлами
+	}
+}
+
+// globField — поле после подстановок (шаблон): пути, совпавшие с ним, по возрастанию;
+// без совпадений — само поле без экранирования
+func (sh *Interpreter) globField(pattern string) []string {
+	if hasGlobMeta(pattern) {
+		if matches := sh.glob(pattern); len(matches) > 0 {
+			return matches
+		}
+		if sh.shellOpts["nullglob"] {
+			return nil
+		}
+	}
+	return []string{unescapePattern(pattern)}
+}
+
+// hasGlobMeta — в шаблоне есть неэкранированные *, ? или [
+func hasGlobMeta(pattern string) bool {
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '\\':
+			i++
+		case '*', '?', '[':
+			return true
+		}
+	}
+	return false
+}
+
+// unescapePattern — шаблон как обычная строка: снимает экранирование \
+func unescapePattern(pattern string) string {
+	if !strings.Contains(pattern, `\`) {
+		return pattern
+	}
+	var b strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		if pattern[i] == '\\' && i+1 < len(pattern) {
+			i++
+		}
+		b.WriteByte(pattern[i])
+	}
+	return b.String()
+}
+
+// glob — пути, совпавшие с шаблоном, по возрастанию. Шаблон сопоставляется по компонентам пути
+func (sh *Interpreter) glob(pattern string) []string {
+	prefix := ""
+	if strings.HasPrefix(pattern, "/") {
+		prefix = "/"
+		pattern = strings.TrimLeft(pattern, "/")
+	}
+
+	matches := sh.globParts(prefix, strings.Split(pattern, "/"))
+	sort.Strings(matches)
+	return matches
+}
+
+// globParts — совпадения для оставшихся компонентов parts внутри каталога prefix ("" — текущий, иначе с / на конце)
+func (sh *Interpreter) globParts(prefix string, parts []string) []string {
+	part, rest := parts[0], parts[1:]
+
+	switch {
+	// / в конце шаблона — только каталоги; лишние / пропускаются
+	case part == "":
+		if len(rest) == 0 {
+			if sh.exists(prefix) {
+				return []string{prefix}
+			}
+			return nil
+		}
+		return sh.globParts(prefix, rest)
+
+	case !hasGlobMeta(part):
+		path := prefix + unescapePattern(part)
+		if len(rest) == 0 {
+			if sh.exists(path) {
+				return []string{path}
+			}
+			return nil
+		}
+		return sh.globParts(path+"/", rest)
+
+	case part == "**" && sh.shellOpts["globstar"]:
+		dirs := append([]string{prefix}, sh.subdirs(prefix)...)
+		if len(rest) == 0 { // ** в конце — все файлы и каталоги внутри
+			var all []string
+			for _, d := range dirs {
+				all = append(all, sh.globParts(d, []string{"*"})...)
+			}
+			return all
+		}
+
+		var out []string
+		seen := map[string]bool{}
+		for _, d := range dirs {
+			for _, m := range sh.globParts(d, rest) {
+				if !seen[m] {
+					seen[m] = true
+					out = append(out, m)
+				}
+			}
+		}
+		return out
+	}
+
+	var out []string
+	for _, name := range sh.readDirNames(prefix) {
+		if strings.HasPrefix(name, ".") && !strings.HasPrefix(part, ".") && !sh.shellOpts["dotglob"] {
+			continue
+		}
+		if !matchPattern(part, name) {
+			continue
+		}
+
+		path := prefix + name
+		if len(rest) == 0 {
+			out = append(out, path)
+		} else if sh.isDir(path) {
+			out = append(out, sh.globParts(path+"/", rest)...)
+		}
+	}
+	return out
+}
+
+// subdirs — все вложенные каталоги dir с / на конце, без скрытых и без переходов по символическим ссылкам
+func (sh *Interpreter) subdirs(dir string) []string {
+	var out []string
+	for _, name := range sh.readDirNames(dir) {
+		if strings.HasPrefix(name, ".") && !sh.shellOpts["dotglob"] {
+			continue
+		}
+		path := dir + name
+		if fi, err := sh.lstat(path); err == nil && fi.IsDir() {
+			out = append(out, path+"/")
+			out = append(out, sh.subdirs(path+"/")...)
+		}
+	}
+	return out
+}
+
+// readDirNames — имена в каталоге dir ("" — текущий); при ошибке пусто
+func (sh *Interpreter) readDirNames(dir string) []string {
+	f, err := os.Open(sh.abs(dir))
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	names, _ := f.Readdirnames(-1)
+	return names
+}
+
+// exists — путь существует (символическая ссылка — даже битая)
+func (sh *Interpreter) exists(path string) bool {
+	_, err := sh.lstat(path)
+	return err == nil
+}
+
+// isDir — путь — каталог или ссылка на каталог
+func (sh *Interpreter) isDir(path string) bool {
+	fi, err := sh.stat(path)
+	return err == nil && fi.IsDir()
+}
+
+// tildePrefix — ~ или ~user в начале s до / (или до : в присваивании). Возвращает домашний каталог
+// и длину префикса; 0 — раскрывать нечего: в имени есть кавычки или пользователя нет
+func (sh *Interpreter) tildePrefix(s string, assign bool) (home string, n int) {
+	if s == "" || s[0] != '~' {
+		return "", 0
+	}
+
+	n = 1
+	for n < len(s) && s[n] != '/' && !(assign && s[n] == ':') {
+		if !isAlnum(s[n]) && strings.IndexByte("._-", s[n]) < 0 {
+			return "", 0
+		}
+		n++
+	}
+
+	if n == 1 {
+		home, _ = sh.lookupVar("HOME")
+		return home, 1
+	}
+	u, err := user.Lookup(s[1:n])
+	if err != nil {
+		return "", 0
+	}
+	return u.HomeDir, n
+}
+
+// braceExpand — раскрытие {a,b,c} и {1..5}, {a..e}, {1..10..2} в исходном слове; слов может стать больше.
+// Скобки в кавычках и в подстановках ${...}, $(...) не раскрываются
+func braceExpand(raw string) []string {
+	for i := 0; i < len(raw); i++ {
+		switch c := raw[i]; {
+		case c == '\\':
+			i++
+		case c == '\'':
+			if end := strings.IndexByte(raw[i+1:], '\''); end >= 0 {
+				i += end + 1
+			}
+		case c == '"':
+			if j := closeDouble(raw, i+1); j > 0 {
+				i = j
+			}
+		case c == '$' || c == '`':
+			if j := substEnd(raw, i); j > 0 {
+				i = j - 1
+			}
+		case c == '{':
+			alts, end := braceAlternatives(raw, i)
+			if alts == nil {
+				continue
+			}
+
+			var out []string
+			for _, alt := range alts {
+				out = append(out, braceExpand(raw[:i]+alt+raw[end:])...)
+			}
+			return out
+		}
+	}
+	return []string{raw}
+}
+
+// braceAlternatives — варианты для {...} с позиции i и позиция после }; nil — это не раскрытие
+func braceAlternatives(raw string, i int) (alts []string, end int) {
+	depth := 0
+	start := i + 1
+	for j := i; j < len(raw); j++ {
+		switch c := raw[j]; {
+		case c == '\\':
+			j++
+		case c == '\'':
+			end := strings.IndexByte(raw[j+1:], '\'')
+			if end < 0 {
+				return nil, 0
+			}
+			j += end + 1
+		case c == '"':
+			if j = closeDouble(raw, j+1); j < 0 {
+				return nil, 0
+			}
+		case c == '$' || c == '`':
+			if k := substEnd(raw, j); k > 0 {
+				j = k - 1
+			}
+		case c == '{':
+			depth++
+		case c == ',' && depth == 1:
+			alts = append(alts, raw[start:j])
+			start = j + 1
+		case c == '}':
+			depth--
+			if depth > 0 {
+				continue
+			}
+			if alts != nil {
+				return append(alts, raw[start:j]), j + 1
+			}
+			if seq := braceSequence(raw[i+1 : j]); seq != nil {
+				return seq, j + 1
+			}
+			return nil, 0
+		}
+	}
+	return nil, 0
+}
+
+// braceSequence — последовательность {x..y[..step]} из чисел или одиночных букв; nil — это не последовательность
+func braceSequence(s string) []string {
+	parts := strings.Split(s, "..")
+	if len(parts) != 2 && len(parts) != 3 {
+		return nil
+	}
+
+	step := 1
+	if len(parts) == 3 {
+		n, err := strconv.Atoi(parts[2])
+		if err != nil {
+			return nil
+		}
+		step = max(n, -n, 1)
+	}
+
+	// буквы: {a..e}
+	if len(parts[0]) == 1 && len(parts[1]) == 1 && !isNumber(parts[0]) && !isNumber(parts[1]) {
+		from, to := int(parts[0][0]), int(parts[1][0])
+		if !isAlnum(byte(from)) || !isAlnum(byte(to)) {
+			return nil
+		}
+		var out []string
+		for _, v := range rangeInts(from, to, step) {
+			out = append(out, string(rune(v)))
+		}
+		return out
+	}
+
+	from, err1 := strconv.Atoi(parts[0])
+	to, err2 := strconv.Atoi(parts[1])
+	if err1 != nil || err2 != nil {
+		return nil
+	}
+
+	// {01..10} — ширина с ведущими нулями
+	width := 0
+	for _, p := range parts[:2] {
+		if len(strings.TrimPrefix(p, "-")) > 1 && strings.HasPrefix(strings.TrimPrefix(p, "-"), "0") {
+			width = max(width, len(p))
+		}
+	}
+
+	var out []string
+	for _, v := range rangeInts(from, to, step) {
+		out = append(out, fmt.Sprintf("%0*d", width, v))
+	}
+	return out
+}
+
+// rangeInts — числа от from до to включительно с шагом step в нужную сторону
+func rangeInts(from, to, step int) []int {
+	var out []int
+	if from <= to {
+		for v := from; v <= to; v += step {
+			out = append(out, v)
+		}
+	} else {
+		for v := from; v >= to; v -= step {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// builtinShopt — shopt [-s|-u|-q] [name...]: включить, выключить или показать настройки раскрытия
+func (sh *Interpreter) builtinShopt(args []string, out io.Writer) (int, error) {
+	mode := ""
+	if len(args) > 0 && strings.HasPrefix(args[0], "-") {
+		mode, args = args[0], args[1:]
+		if mode != "-s" && mode != "-u" && mode != "-q" {
+			return 2, fmt.Errorf("shopt: %s: invalid option", mode)
+		}
+	}
+
+	names := args
+	if len(names) == 0 {
+		for name := range sh.shellOpts {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+
+	code := 0
+	for _, name := range names {
+		on, ok := sh.shellOpts[name]
+		if !ok {
+			return 1, fmt.Errorf("shopt: %s: invalid shell option name", name)
+		}
+
+		switch {
+		case mode == "-s" && len(args) > 0:
+			sh.shellOpts[name] = true
+		case mode == "-u" && len(args) > 0:
+			sh.shellOpts[name] = false
+		case mode == "-q":
+			if !on {
+				code = 1
+			}
+		case mode == "" || mode == "-s" && on || mode == "-u" && !on:
+			state := "off"
+			if on {
+				state = "on"
+			}
+			fmt.Fprintf(out, "%-15s\t%s\n", name, state)
+		}
+	}
+	return code, nil
+}
@@ -0,0 +1,227 @@
+package minishell
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// historySize — сколько команд истории хранится
+const historySize = 1000
+
+// history — команды, введённые в интерактивном режиме. Номер команды lines[i] — base+i+1
+type history struct {
+	lines []string
+	base  int    // сколько старых строк уже вытеснено
+	path  string // файл, куда дописывается каждая строка; "" — история только в памяти
+}
+
+// errNoEvent — подстановка из истории ссылается на строку, которой нет
+var errNoEvent = errors.New("event not found")
+
+// historyFile — файл истории: $HISTFILE или ~/.minishell_history
+func (sh *Interpreter) historyFile() string {
+	if path, ok := sh.lookupVar("HISTFILE"); ok {
+		return path
+	}
+	home, _ := sh.lookupVar("HOME")
+	if home == "" {
+		return ""
+	}
+	return strings.TrimSuffix(home, "/") + "/.minishell_history"
+}
+
+// loadHistory — история из файла path; если строк в нём больше historySize, файл укорачивается
+func loadHistory(path string) *history {
+	h := &history{path: path}
+	if path == "" {
+		return h
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || len(data) == 0 {
+		return h
+	}
+
+	for _, record := range strings.Split(strings.TrimSuffix(string(data), "\n"), "\n") {
+		h.lines = append(h.lines, historyUnescaper.Replace(record))
+	}
+	if len(h.lines) > historySize {
+		h.lines = h.lines[len(h.lines)-historySize:]
+		var b strings.Builder
+		for _, line := range h.lines {
+			b.WriteString(historyEscaper.Replace(line) + "\n")
+		}
+		_ = os.WriteFile(path, []byte(b.String()), 0o600)
+	}
+	return h
+}
+
+// historyEscaper и historyUnescaper — запись команды в файле истории занимает одну строку:
+// переводы строк многострочной команды записываются как \n, сама \ — как \\
+var (
+	historyEscaper   = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	historyUnescaper = strings.NewReplacer(`\\`, `\`, `\n`, "\n")
+)
+
+// add — добавляет команду в историю и дописывает её в файл; многострочная команда — одна запись.
+// Пустые команды и повтор предыдущей пропускаются
+func (h *history) add(line string) {
+	line = strings.TrimSuffix(line, "\n")
+	if strings.TrimSpace(line) == "" || len(h.lines) > 0 && h.lines[len(h.lines)-1] == line {
+		return
+	}
+
+	h.lines = append(h.lines, line)
+	if len(h.lines) > historySize {
+		h.lines = h.lines[1:]
+		h.base++
+	}
+
+	if h.path == "" {
+		return
+	}
+	f, err := os.OpenFile(h.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return
+	}
+	fmt.Fprintln(f, historyEscaper.Replace(line))
+	f.Close()
+}
+
+// snapshot — копия истории для builtin в конвейере: изменения копии не доходят ни до shell, ни до файла
+func (h *history) snapshot() *history {
+	if h == nil {
+		return nil
+	}
+	return &history{lines: slices.Clone(h.lines), base: h.base}
+}
+
+// clear — забывает всю историю, в том числе в файле
+func (h *history) clear() {
+	h.lines, h.base = nil, 0
+	if h.path != "" {
+		_ = os.WriteFile(h.path, nil, 0o600)
+	}
+}
+
+// expand — подстановки из истории в строке: !! — предыдущая строка, !N — строка с номером N, !-N — N-я с конца,
+// !текст — последняя строка, начинающаяся с текста. ! в '...', после \ и перед пробелом, = или ( остаётся как есть
+func (h *history) expand(line string) (string, error) {
+	if !strings.Contains(line, "!") {
+		return line, nil
+	}
+
+	var b strings.Builder
+	inSingle, inDouble := false, false
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case c == '\\' && !inSingle && i+1 < len(line):
+			b.WriteString(line[i : i+2])
+			i++
+			continue
+		case c == '\'' && !inDouble:
+			inSingle = !inSingle
+		case c == '"' && !inSingle:
+			inDouble = !inDouble
+		case c == '!' && !inSingle:
+			ev, n, err := h.event(line[i+1:])
+			if err != nil {
+				return "", err
+			}
+			if n > 0 {
+				b.WriteString(ev)
+				i += n
+				continue
+			}
+		}
+		b.WriteByte(c)
+	}
+	return b.String(), nil
+}
+
+// event — строка истории по ссылке после ! в начале s и длина ссылки; 0 — это не ссылка
+func (h *history) event(s string) (string, int, error) {
+	if s == "" || strings.IndexByte(" \t\n=(\"", s[0]) >= 0 {
+		return "", 0, nil
+	}
+
+	var spec string
+	idx := -1
+	switch {
+	case s[0] == '!':
+		spec = "!"
+		idx = len(h.lines) - 1
+
+	case s[0] == '-' || s[0] >= '0' && s[0] <= '9':
+		j := 1
+		for j < len(s) && s[j] >= '0' && s[j] <= '9' {
+			j++
+		}
+		spec = s[:j]
+		n, err := strconv.Atoi(spec)
+		switch {
+		case err != nil:
+			idx = -1
+		case n < 0:
+			idx = len(h.lines) + n
+		default:
+			idx = n - h.base - 1
+		}
+
+	default:
+		j := 0
+		for j < len(s) && strings.IndexByte(" \t\n;&|<>()'\"", s[j]) < 0 {
+			j++
+		}
+		spec = s[:j]
+		for i := len(h.lines) - 1; i >= 0; i-- {
+			if strings.HasPrefix(h.lines[i], spec) {
+				idx = i
+				break
+			}
+		}
+	}
+
+	if idx < 0 || idx >= len(h.lines) {
+		return "", 0, fmt.Errorf("!%s: %w", spec, errNoEvent)
+	}
+	return h.lines[idx], len(spec), nil
+}
+
+// builtinHistory — history [N] | history -c: строки истории с номерами (последние N) или очистка
+func (sh *Interpreter) builtinHistory(args []string, out io.Writer) (int, error) {
+	h := sh.history
+	if h == nil {
+		return 0, nil
+	}
+
+	from := 0
+	switch {
+	case len(args) > 1:
+		return 2, fmt.Errorf("history: too many arguments")
+
+	case len(args) == 1 && args[0] == "-c":
+		h.clear()
+		return 0, nil
+
+	case len(args) == 1 && strings.HasPrefix(args[0], "-"):
+		return 2, fmt.Errorf("history: %s: invalid option", args[0])
+
+	case len(args) == 1:
+		n, err := strconv.Atoi(args[0])
+		if err != nil {
+			return 2, fmt.Errorf("history: %s: numeric argument required", args[0])
+		}
+		from = max(len(h.lines)-n, 0)
+	}
+
+	for i := from; i < len(h.lines); i++ {
+		fmt.Fprintf(out, "%5d  %s\n", h.base+i+1, h.lines[i])
+	}
+	return 0, nil
+}
@@ -0,0 +1,210 @@
+package minishell
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// commandReader — читает команды построчно: незаконченная конструкция (if без fi, открытая кавычка,
+// \ в конце строки) дочитывается следующими строками, тела here-doc — сразу после строки с <<
+type commandReader struct {
+	sh     *Interpreter
+	r      lineReader
+	prompt bool     // печатать приглашения: PS1 перед командой, PS2 перед продолжением
+	hist   *history // куда записывать команды и откуда брать подстановки !!, !N; nil — без истории
+}
+
+// syntaxError — ошибка разбора команды; stage — tokenize или parse
+type syntaxError struct {
+	stage string
+	err   error
+}
+
+func (e *syntaxError) Error() string {
+	return e.stage + ": " + e.err.Error()
+}
+
+func (e *syntaxError) Unwrap() error {
+	return e.err
+}
+
+// next — следующая команда целиком. Пустые строки и комментарии дают пустой список,
+// io.EOF — ввод закончился
+func (c *commandReader) next() (*ListNode, error) {
+	var text strings.Builder
+	var docs []*Redirect // here-doc, тела которых уже прочитаны
+	var lastErr error    // почему команда ещё не закончена
+
+	for {
+		prompt := ""
+		if c.prompt {
+			if text.Len() == 0 {
+				prompt = c.sh.promptString("PS1")
+			} else {
+				prompt = c.sh.promptString("PS2")
+			}
+		}
+
+		line, err := c.r.readLine(prompt)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+		if line == "" { // Ctrl+D
+			if text.Len() == 0 {
+				return nil, io.EOF
+			}
+			return nil, lastErr
+		}
+		if !strings.HasSuffix(line, "\n") {
+			line += "\n"
+		}
+
+		// !! и !N раскрываются до разбора; раскрытая строка показывается и попадает в историю
+		// вместе с остальными строками команды
+		if c.hist != nil {
+			expanded, err := c.hist.expand(line)
+			if err != nil {
+				return nil, err
+			}
+			if expanded != line {
+				fmt.Print(expanded)
+			}
+			line = expanded
+		}
+		text.WriteString(line)
+		src := text.String()
+
+		toks, err := tokenize(src)
+		if errors.Is(err, errIncomplete) || errors.Is(err, errUnterminated) {
+			lastErr = &syntaxError{"tokenize", err}
+			continue
+		}
+		if err != nil {
+			c.addHistory(src)
+			return nil, &syntaxError{"tokenize", err}
+		}
+
+		// тела новых here-doc идут следующими строками
+		ops := heredocOps(toks)
+		if len(ops) > len(docs) {
+			ops = ops[len(docs):]
+			prompt := ""
+			if c.prompt {
+				prompt = c.sh.promptString("PS2")
+			}
+			err := c.sh.readHeredocs(c.r, ops, prompt)
+			if err != nil {
+				return nil, err
+			}
+			docs = append(docs, ops...)
+		}
+
+		list, err := c.sh.parse(toks, src)
+		if errors.Is(err, errIncomplete) {
+			lastErr = &syntaxError{"parse", err}
+			continue
+		}
+		c.addHistory(src)
+		if err != nil {
+			return nil, &syntaxError{"parse", err}
+		}
+
+		// разбор заново создаёт перенаправления — переносим в них прочитанные тела
+		for i, doc := range pendingHeredocs(list) {
+			doc.Body = docs[i].Body
+		}
+		return list, nil
+	}
+}
+
+// addHistory — записывает прочитанную команду src в историю одной записью
+func (c *commandReader) addHistory(src string) {
+	if c.hist != nil {
+		c.hist.add(src)
+	}
+}
+
+// heredocOps — here-doc в токенах в порядке записи
+func heredocOps(toks []Token) []*Redirect {
+	var docs []*Redirect
+	for i := 0; i+1 < len(toks); i++ {
+		op := strings.TrimLeft(toks[i].Val, "0123456789")
+		if toks[i].Kind != TK_REDIR || (op != "<<" && op != "<<-") || toks[i+1].Kind != TK_WORD {
+			continue
+		}
+		if r, err := parseRedirect(toks[i].Val, toks[i+1]); err == nil {
+			docs = append(docs, &r[0])
+		}
+	}
+	return docs
+}
+
+// builtinSource — source файл [аргументы] и . файл [аргументы]: выполняет команды файла в текущем shell.
+// Имя без / ищется в PATH, затем в текущем каталоге; аргументы на время становятся позиционными параметрами
+func (sh *Interpreter) builtinSource(name string, args []string) (int, error) {
+	if len(args) == 0 {
+		return 2, fmt.Errorf("%s: filename argument required", name)
+	}
+
+	path := sh.findSourceFile(args[0])
+	f, err := sh.openFile(path, os.O_RDONLY, 0)
+	if err != nil {
+		return 1, fmt.Errorf("%s: %w", name, err)
+	}
+	defer f.Close()
+
+	if len(args) > 1 {
+		saved := sh.positional
+		sh.positional = args[1:]
+		defer func() { sh.positional = saved }()
+	}
+
+	sh.sourceDepth++
+	status, err := sh.runLines(&fileLines{bufio.NewReader(f)}, false)
+	sh.sourceDepth--
+	if err != nil {
+		fmt.Fprintln(sh.errOut(), err)
+	}
+	if sh.flow == flowReturn {
+		sh.flow = flowNone
+	}
+	return status, nil
+}
+
+// findSourceFile — файл для source: имя без / ищется среди обычных файлов в PATH, иначе берётся как есть
+func (sh *Interpreter) findSourceFile(name string) string {
+	if strings.Contains(name, "/") {
+		return name
+	}
+	path, _ := sh.lookupVar("PATH")
+	for _, dir := range filepath.SplitList(path) {
+		if dir == "" {
+			continue
+		}
+		candidate := filepath.Join(dir, name)
+		if fi, err := sh.stat(candidate); err == nil && fi.Mode().IsRegular() {
+			return candidate
+		}
+	}
+	return name
+}
+
+// sourceRcFile — выполняет ~/.minishellrc при запуске интерактивного shell, если файл есть
+func (sh *Interpreter) sourceRcFile() {
+	home, _ := sh.lookupVar("HOME")
+	if home == "" {
+		return
+	}
+	path := filepath.Join(home, ".minishellrc")
+	if _, err := sh.stat(path); err != nil {
+		return
+	}
+	if _, err := sh.builtinSource("source", []string{path}); err != nil {
+		fmt.Fprintln(sh.errOut(), err)
+	}
+}
@@ -0,0 +1,326 @@
+// Package minishell — интерпретатор командного языка shell: Interpreter выполняет скрипты в самом процессе,
+// Main — командная строка minishell. Подоболочки, которым нужен отдельный процесс, — это та же программа,
+// запущенная заново; такой запуск пакет перехватывает при инициализации, до main программы
+package minishell
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// Interpreter — экземпляр shell со своим состоянием: переменными и окружением, функциями, алиасами,
+// настройками, текущим каталогом, стандартными потоками, заданиями и встроенными командами.
+// Интерпретаторы не делят состояние и могут работать параллельно; общие у них только umask, сигналы и терминал
+// процесса. Один интерпретатор одновременно выполняет один Run
+type Interpreter struct {
+	// Стандартные потоки команд Run; nil — /dev/null. Не *os.File подключаются через пайп,
+	// из которого копирует горутина, как в exec.Cmd
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+
+	dir         string                 // текущий каталог, абсолютный путь
+	vars        map[string]string      // переменные shell
+	exported    map[string]bool        // экспортированные переменные — окружение внешних команд
+	positional  []string               // позиционные параметры $1, $2 ...
+	scriptName  string                 // $0 — имя скрипта или shell
+	shellPid    int                    // $$ — pid shell; в подоболочках остаётся pid родителя
+	lastStatus  int                    // $? — код завершения последней команды
+	lastBgPid   int                    // $! — pid последнего фонового задания
+	pipeStatus  []int                  // PIPESTATUS — коды стадий последнего конвейера
+	setOptions  map[string]bool        // настройки set -o
+	shellOpts   map[string]bool        // настройки раскрытия шаблонов, меняются через shopt
+	functions   map[string]*FuncDef    // определённые функции
+	aliases     map[string]string      // алиасы: имя — текст, который подставляется вместо первого слова команды
+	builtins    map[string]BuiltinFunc // встроенные команды, добавленные через Register
+	history     *history               // история интерактивного shell; nil — история не ведётся
+	jobs        *JobTable              // фоновые и остановленные задания
+	interactive bool                   // управление заданиями: задания получают терминал через tcsetpgrp
+	traps       map[string]string      // ловушки trap: EXIT, ERR и имя сигнала без SIG — действие
+	signals     chan os.Signal         // перехваченные сигналы, обрабатываются между командами
+	ownSignals  []syscall.Signal       // сигналы, которые shell перехватывает сам, без ловушек
+	fatalSignal syscall.Signal         // сигнал, от которого shell завершается после ловушки EXIT
+
+	flow        flowKind // прерывание, которое сейчас раскручивается
+	flowCount   int      // сколько ещё циклов прервать: break N, continue N
+	loopDepth   int      // вложенность циклов в текущей функции
+	funcDepth   int      // вложенность вызовов функций
+	sourceDepth int      // вложенность файлов, выполняемых через source: return в них завершает файл
+	substStatus int      // код последней подстановки команды — код команды из одних присваиваний
+	expandErr   error    // ошибка раскрытия (деление на ноль в $((...))); команда тогда не выполняется
+	noErrexit   int      // вложенность условий и левых частей && и ||, где set -e и ловушка ERR не действуют
+	traceLevel  int      // вложенность подстановок команд — сколько раз повторить первый символ PS4
+	trapDepth   int      // вложенность выполняемых ловушек
+	inPipeline  bool     // копия для builtin, который выполняется горутиной в конвейере или в фоне
+	childUsage  usage    // суммарные ресурсы процессов, завершившихся на переднем плане, — для time
+
+	ctx   context.Context // отмена Run
+	stdio []*os.File      // стандартные потоки на время Run; nil — потоки процесса
+}
+
+// BuiltinFunc — встроенная команда, добавленная через Register: args — аргументы без имени команды,
+// in и out — её stdin и stdout. Ошибка печатается в stderr команды
+type BuiltinFunc func(sh *Interpreter, args []string, in io.Reader, out io.Writer) (int, error)
+
+// NewInterpreter — интерпретатор с текущим каталогом dir ("" — каталог процесса) и окружением env
+// из строк NAME=value; все переменные env экспортированы. Потоки — потоки процесса
+func NewInterpreter(dir string, env []string) (*Interpreter, error) {
+	if dir == "" {
+		wd, err := os.Getwd()
+		if err != nil {
+			return nil, err
+		}
+		dir = wd
+	}
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	if fi, err := os.Stat(dir); err != nil {
+		return nil, err
+	} else if !fi.IsDir() {
+		return nil, &fs.PathError{Op: "chdir", Path: dir, Err: syscall.ENOTDIR}
+	}
+
+	sh := &Interpreter{
+		Stdin:      os.Stdin,
+		Stdout:     os.Stdout,
+		Stderr:     os.Stderr,
+		dir:        dir,
+		vars:       map[string]string{},
+		exported:   map[string]bool{},
+		scriptName: os.Args[0],
+		shellPid:   os.Getpid(),
+		setOptions: defaultSetOptions(),
+		shellOpts:  defaultShellOpts(),
+		functions:  map[string]*FuncDef{},
+		aliases:    map[string]string{},
+		builtins:   map[string]BuiltinFunc{},
+		traps:      map[string]string{},
+		jobs:       &JobTable{},
+		ctx:        context.Background(),
+	}
+	for _, kv := range env {
+		if name, value, ok := strings.Cut(kv, "="); ok && name != "" {
+			sh.vars[name], sh.exported[name] = value, true
+		}
+	}
+	if _, ok := sh.vars["PS4"]; !ok {
+		sh.vars["PS4"] = defaultPS4
+	}
+	// PWD из окружения верен, только если указывает на тот же каталог
+	if pwd, ok := sh.vars["PWD"]; !ok || !sameFile(pwd, dir) {
+		sh.vars["PWD"], sh.exported["PWD"] = dir, true
+	}
+	return sh, nil
+}
+
+// sameFile — пути указывают на один и тот же файл
+func sameFile(a, b string) bool {
+	fa, errA := os.Stat(a)
+	fb, errB := os.Stat(b)
+	return errA == nil && errB == nil && os.SameFile(fa, fb)
+}
+
+// Register — добавляет встроенную команду name или заменяет одноимённую; функции shell её перекрывают.
+// В конвейере команда выполняется в горутине с копией интерпретатора, в ( ) и $( ) — в подоболочке на копии
+// интерпретатора в самом процессе. Подоболочки-процессы (составные команды и функции в конвейере, фоновые
+// составные команды) добавленных команд не видят: вызов там завершается ошибкой с кодом 127
+func (sh *Interpreter) Register(name string, fn BuiltinFunc) {
+	sh.builtins[name] = fn
+}
+
+// Dir — текущий каталог интерпретатора
+func (sh *Interpreter) Dir() string {
+	return sh.dir
+}
+
+// LookupVar — значение переменной или параметра shell; ok — задан
+func (sh *Interpreter) LookupVar(name string) (value string, ok bool) {
+	return sh.lookupVar(name)
+}
+
+// SetVar — присваивает переменной значение, как NAME=value в shell
+func (sh *Interpreter) SetVar(name, value string) {
+	sh.setVar(name, value)
+}
+
+// Environ — окружение, которое получают внешние команды: экспортированные переменные NAME=value
+func (sh *Interpreter) Environ() []string {
+	return sh.environ(nil)
+}
+
+// Run — выполняет текст src, как minishell -c: синтаксическая ошибка прекращает выполнение и возвращается
+// ошибкой с кодом 2, exit завершает Run. Состояние (переменные, функции, каталог) сохраняется до следующего
+// Run. Отмена ctx убивает задание переднего плана и прекращает выполнение, ошибка тогда — ctx.Err().
+// Ловушка EXIT выполняется в конце Run; ловушки, заданные в Run, снимаются, и сигналы снова действуют как до него.
+// Пока Run выполняется, ловушки сигналов перехватывают сигналы всего процесса.
+// Как exec.Cmd, Run ждёт, пока копируется вывод, — в том числе фоновых заданий, которые держат пайп, —
+// и пока Stdin не вернёт из Read: чтение, которое не завершается, блокирует Run.
+// ( ) и $( ) выполняются в самом процессе, остальные подоболочки — как os.Executable() с аргументами
+// --subshell N; такой процесс выполняет команду при инициализации пакета и до main программы не доходит
+func (sh *Interpreter) Run(ctx context.Context, src string) (int, error) {
+	stdio, wait, err := sh.openStdio()
+	if err != nil {
+		return 1, err
+	}
+	savedStdio, savedCtx, savedTraps := sh.stdio, sh.ctx, maps.Clone(sh.traps)
+	sh.stdio, sh.ctx = stdio, ctx
+
+	code, err := sh.runLines(&fileLines{bufio.NewReader(strings.NewReader(src))}, false)
+	code = sh.runExitTrap(code)
+	sh.restoreTraps(savedTraps)
+	if sh.fatalSignal != 0 {
+		code, sh.fatalSignal = 128+int(sh.fatalSignal), 0
+	}
+	if sh.flow == flowExit {
+		sh.flow = flowNone
+	}
+
+	sh.stdio, sh.ctx = savedStdio, savedCtx
+	wait()
+	if err == nil {
+		err = ctx.Err()
+	}
+	return code, err
+}
+
+// openStdio — Stdin, Stdout и Stderr как файлы для дескрипторов 0, 1, 2. wait закрывает пайпы
+// и дожидается горутин копирования
+func (sh *Interpreter) openStdio() (files []*os.File, wait func(), err error) {
+	var wg sync.WaitGroup
+	var opened []*os.File // закрываются после Run
+	wait = func() {
+		closeFiles(opened)
+		wg.Wait()
+	}
+
+	files = make([]*os.File, 3)
+	for fd, stream := range []any{sh.Stdin, sh.Stdout, sh.Stderr} {
+		if f, ok := stream.(*os.File); ok {
+			files[fd] = f
+			continue
+		}
+		// один и тот же Writer для вывода и ошибок — общий пайп, иначе две горутины писали бы в него одновременно
+		if fd == 2 && stream != nil && sameWriter(sh.Stderr, sh.Stdout) {
+			files[2] = files[1]
+			continue
+		}
+
+		var f *os.File
+		switch {
+		case stream == nil:
+			f, err = os.OpenFile(os.DevNull, os.O_RDWR, 0)
+		case fd == 0:
+			var w *os.File
+			if f, w, err = os.Pipe(); err == nil {
+				wg.Add(1)
+				go func(in io.Reader) {
+					defer wg.Done()
+					_, _ = io.Copy(w, in) // после Run пайп закрыт, и копирование прерывается
+					w.Close()
+				}(stream.(io.Reader))
+			}
+		default:
+			var r *os.File
+			if r, f, err = os.Pipe(); err == nil {
+				wg.Add(1)
+				go func(out io.Writer) {
+					defer wg.Done()
+					_, _ = io.Copy(out, r)
+					r.Close()
+				}(stream.(io.Writer))
+			}
+		}
+		if err != nil {
+			wait()
+			return nil, nil, err
+		}
+		files[fd] = f
+		opened = append(opened, f)
+	}
+	return files, wait, nil
+}
+
+// sameWriter — a и b — один и тот же Writer; несравнимые значения разные
+func sameWriter(a, b io.Writer) (same bool) {
+	defer func() { _ = recover() }()
+	return a == b
+}
+
+// stdFiles — стандартные потоки shell как таблица дескрипторов
+func (sh *Interpreter) stdFiles() []*os.File {
+	if sh.stdio != nil {
+		return append([]*os.File(nil), sh.stdio...)
+	}
+	return []*os.File{os.Stdin, os.Stdout, os.Stderr}
+}
+
+// errOut — stderr shell для сообщений самого shell
+func (sh *Interpreter) errOut() *os.File {
+	return sh.stdFiles()[2]
+}
+
+// subshell — копия интерпретатора для команды, которая выполняется параллельно с ним (builtin из Register
+// в конвейере): её изменения до интерпретатора не доходят. Задания у копии свои
+func (sh *Interpreter) subshell() *Interpreter {
+	sub := *sh
+	sub.vars = maps.Clone(sh.vars)
+	sub.exported = maps.Clone(sh.exported)
+	sub.positional = append([]string(nil), sh.positional...)
+	sub.pipeStatus = append([]int(nil), sh.pipeStatus...)
+	sub.setOptions = maps.Clone(sh.setOptions)
+	sub.shellOpts = maps.Clone(sh.shellOpts)
+	sub.functions = maps.Clone(sh.functions)
+	sub.aliases = maps.Clone(sh.aliases)
+	sub.builtins = maps.Clone(sh.builtins)
+	sub.traps = maps.Clone(sh.traps)
+	sub.signals, sub.ownSignals = nil, nil // сигналы получает сам интерпретатор
+	sub.history = nil
+	sub.jobs = &JobTable{}
+	sub.interactive = false
+	sub.loopDepth, sub.funcDepth, sub.sourceDepth = 0, 0, 0
+	return &sub
+}
+
+// abs — путь name относительно текущего каталога интерпретатора
+func (sh *Interpreter) abs(name string) string {
+	switch {
+	case name == "":
+		return sh.dir
+	case strings.HasPrefix(name, "/"):
+		return name
+	case sh.dir == "/":
+		return "/" + name
+	}
+	return sh.dir + "/" + name
+}
+
+// openFile — os.OpenFile для пути относительно текущего каталога; в ошибке путь остаётся как записан
+func (sh *Interpreter) openFile(name string, flag int, perm os.FileMode) (*os.File, error) {
+	f, err := os.OpenFile(sh.abs(name), flag, perm)
+	var perr *fs.PathError
+	if errors.As(err, &perr) {
+		perr.Path = name
+	}
+	return f, err
+}
+
+// stat — os.Stat для пути относительно текущего каталога
+func (sh *Interpreter) stat(name string) (os.FileInfo, error) {
+	return os.Stat(sh.abs(name))
+}
+
+// lstat — os.Lstat для пути относительно текущего каталога
+func (sh *Interpreter) lstat(name string) (os.FileInfo, error) {
+	return os.Lstat(sh.abs(name))
+}
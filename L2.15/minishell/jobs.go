@@ -0,0 +1,729 @@
+package minishell
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// Состояние задания
+type JobState int
+
+const (
+	JobRunning JobState = iota // хотя бы один процесс выполняется
+	JobStopped                 // все незавершённые процессы остановлены
+	JobDone                    // все процессы завершились
+)
+
+// Process — один внешний процесс задания
+type Process struct {
+	Pid     int
+	Stage   int                // номер стадии конвейера
+	Status  syscall.WaitStatus // статус завершения, если Done
+	Usage   syscall.Rusage     // ресурсы, которые процесс потратил, если Done
+	Done    bool
+	Stopped bool
+}
+
+// Job — конвейер, внешние команды которого работают в одной группе процессов
+type Job struct {
+	ID         int    // номер задания для %N, 0 — ещё не в таблице
+	Pgid       int    // группа процессов, pid первой внешней стадии; 0 — своей группы нет (shell без управления заданиями)
+	Cmd        string // текст команды для jobs
+	Procs      []*Process
+	Codes      []int // коды стадий конвейера; у стадий-процессов заполняются по их статусу
+	Background bool
+	notified   bool // об остановке уже сообщили
+	seq        int  // порядок последнего обращения, для %+ и %-
+}
+
+// State — общее состояние задания по состояниям его процессов
+func (j *Job) State() JobState {
+	stopped := false
+	for _, p := range j.Procs {
+		if !p.Done && !p.Stopped {
+			return JobRunning
+		}
+		if !p.Done {
+			stopped = true
+		}
+	}
+	if stopped {
+		return JobStopped
+	}
+	return JobDone
+}
+
+// update — применяет статус и ресурсы от wait4 к процессу pid
+func (j *Job) update(pid int, ws syscall.WaitStatus, ru *syscall.Rusage) {
+	for _, p := range j.Procs {
+		if p.Pid != pid {
+			continue
+		}
+		switch {
+		case ws.Stopped():
+			p.Stopped = true
+		case ws.Continued():
+			p.Stopped = false
+		default:
+			p.Done, p.Stopped, p.Status, p.Usage = true, false, ws, *ru
+		}
+		return
+	}
+}
+
+// exitCode — код завершения задания: код последней стадии, с pipefail — последней неуспешной.
+// Остановленное задание — 128+SIGTSTP
+func (j *Job) exitCode(pipefail bool) int {
+	if j.State() == JobStopped {
+		return 128 + int(syscall.SIGTSTP)
+	}
+
+	codes := j.stageCodes()
+	if pipefail {
+		code := 0
+		for _, c := range codes {
+			if c != 0 {
+				code = c
+			}
+		}
+		return code
+	}
+	if len(codes) == 0 {
+		return 0
+	}
+	return codes[len(codes)-1]
+}
+
+// stageCodes — коды всех стадий: у завершившихся процессов — по статусу wait
+func (j *Job) stageCodes() []int {
+	codes := append([]int(nil), j.Codes...)
+	for _, p := range j.Procs {
+		if p.Done && p.Stage < len(codes) {
+			codes[p.Stage] = waitCode(p.Status)
+		}
+	}
+	return codes
+}
+
+// waitCode — код завершения процесса: код exit или 128+номер сигнала, которым он убит или остановлен
+func waitCode(ws syscall.WaitStatus) int {
+	switch {
+	case ws.Signaled():
+		return 128 + int(ws.Signal())
+	case ws.Stopped():
+		return 128 + int(ws.StopSignal())
+	}
+	return ws.ExitStatus()
+}
+
+// startErrorCode — код стадии, которая не запустилась: 127 — команда не найдена, 126 — не исполняется
+func startErrorCode(err error) int {
+	switch {
+	case errors.Is(err, exec.ErrNotFound), errors.Is(err, fs.ErrNotExist):
+		return 127
+	case errors.Is(err, fs.ErrPermission), errors.Is(err, syscall.ENOEXEC), errors.Is(err, syscall.EISDIR):
+		return 126
+	}
+	return 1
+}
+
+// stateText — состояние для вывода jobs: Running, Stopped, Done, Exit N или имя сигнала
+func (j *Job) stateText() string {
+	switch j.State() {
+	case JobRunning:
+		return "Running"
+	case JobStopped:
+		return "Stopped"
+	}
+
+	ws := j.Procs[len(j.Procs)-1].Status
+	switch {
+	case ws.Signaled():
+		name := ws.Signal().String()
+		return strings.ToUpper(name[:1]) + name[1:]
+	case ws.ExitStatus() != 0:
+		return fmt.Sprintf("Exit %d", ws.ExitStatus())
+	}
+	return "Done"
+}
+
+// continueJob — посылает SIGCONT заданию и помечает процессы работающими
+func (j *Job) continueJob() error {
+	for _, p := range j.Procs {
+		p.Stopped = false
+	}
+	j.notified = false
+	return j.signal(syscall.SIGCONT)
+}
+
+// signal — посылает сигнал всей группе задания, а без своей группы — каждому незавершённому процессу
+func (j *Job) signal(sig syscall.Signal) error {
+	if j.Pgid != 0 {
+		return syscall.Kill(-j.Pgid, sig)
+	}
+	for _, p := range j.Procs {
+		if !p.Done {
+			if err := syscall.Kill(p.Pid, sig); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// leader — pid первого процесса задания
+func (j *Job) leader() int {
+	if j.Pgid != 0 {
+		return j.Pgid
+	}
+	return j.Procs[0].Pid
+}
+
+// JobTable — таблица заданий shell
+type JobTable struct {
+	mu       sync.Mutex
+	list     []*Job // по возрастанию ID
+	seq      int
+	finished map[int]int // коды процессов завершившихся заданий, которые убраны из таблицы, — для wait pid
+}
+
+// add — заносит задание в таблицу и делает его текущим (%+)
+func (t *JobTable) add(j *Job) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if j.ID == 0 {
+		j.ID = 1
+		if n := len(t.list); n > 0 {
+			j.ID = t.list[n-1].ID + 1
+		}
+		t.list = append(t.list, j)
+	}
+	t.seq++
+	j.seq = t.seq
+}
+
+// remove — убирает задание из таблицы; коды его завершившихся процессов запоминаются
+func (t *JobTable) remove(j *Job) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for i, x := range t.list {
+		if x != j {
+			continue
+		}
+		t.list = append(t.list[:i], t.list[i+1:]...)
+		if t.finished == nil {
+			t.finished = map[int]int{}
+		}
+		for _, p := range j.Procs {
+			if p.Done {
+				t.finished[p.Pid] = waitCode(p.Status)
+			}
+		}
+		return
+	}
+}
+
+// finishedCode — код завершившегося процесса pid, задание которого уже убрано из таблицы
+func (t *JobTable) finishedCode(pid int) (int, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	code, ok := t.finished[pid]
+	return code, ok
+}
+
+// findPid — задание, в котором есть процесс pid
+func (t *JobTable) findPid(pid int) (*Job, *Process) {
+	for _, j := range t.snapshot() {
+		for _, p := range j.Procs {
+			if p.Pid == pid {
+				return j, p
+			}
+		}
+	}
+	return nil, nil
+}
+
+// snapshot — копия списка заданий
+func (t *JobTable) snapshot() []*Job {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]*Job(nil), t.list...)
+}
+
+// marks — текущее (%+) и предыдущее (%-) задания
+func (t *JobTable) marks() (cur, prev *Job) {
+	for _, j := range t.snapshot() {
+		switch {
+		case cur == nil || j.seq > cur.seq:
+			cur, prev = j, cur
+		case prev == nil || j.seq > prev.seq:
+			prev = j
+		}
+	}
+	return cur, prev
+}
+
+// mark — символ задания в выводе jobs: '+', '-' или ' '
+func (t *JobTable) mark(j *Job) byte {
+	cur, prev := t.marks()
+	switch j {
+	case cur:
+		return '+'
+	case prev:
+		return '-'
+	}
+	return ' '
+}
+
+// find — ищет задание по спецификации: %N, N, %+, %%, %-, %строка (начало команды), %?строка
+func (t *JobTable) find(spec string) (*Job, error) {
+	cur, prev := t.marks()
+
+	switch spec {
+	case "", "%", "%%", "%+":
+		if cur == nil {
+			return nil, errors.New("no current job")
+		}
+		return cur, nil
+	case "%-":
+		if prev == nil {
+			return nil, errors.New("no previous job")
+		}
+		return prev, nil
+	}
+
+	s := strings.TrimPrefix(spec, "%")
+	if id, err := strconv.Atoi(s); err == nil {
+		for _, j := range t.snapshot() {
+			if j.ID == id {
+				return j, nil
+			}
+		}
+		return nil, fmt.Errorf("%s: no such job", spec)
+	}
+
+	if !strings.HasPrefix(spec, "%") {
+		return nil, fmt.Errorf("%s: no such job", spec)
+	}
+	for _, j := range t.snapshot() {
+		if sub, ok := strings.CutPrefix(s, "?"); ok && strings.Contains(j.Cmd, sub) ||
+			!ok && strings.HasPrefix(j.Cmd, s) {
+			return j, nil
+		}
+	}
+	return nil, fmt.Errorf("%s: no such job", spec)
+}
+
+// jobLine — строка задания в формате bash: "[1]+  Running                 sleep 10 &"
+func jobLine(j *Job, mark byte) string {
+	line := fmt.Sprintf("[%d]%c  %-24s%s", j.ID, mark, j.stateText(), j.Cmd)
+	if j.State() == JobRunning {
+		line += " &"
+	}
+	return line
+}
+
+// startProcess — запускает внешнюю команду. С управлением заданиями (интерактивный shell) процесс
+// попадает в группу pgid (0 — новая группа во главе с ним), а на переднем плане группа сразу получает терминал;
+// иначе процесс остаётся в группе shell. fds[N] становится дескриптором N потомка, nil — дескриптор закрыт
+func (sh *Interpreter) startProcess(args []string, fds []*os.File, env []string, pgid int, fg bool) (int, error) {
+	path, err := sh.lookPath(args[0], env)
+	if err != nil {
+		return 0, err
+	}
+
+	files := make([]uintptr, len(fds))
+	for i, f := range fds {
+		files[i] = ^uintptr(0) // -1: закрыть в потомке
+		if f != nil {
+			files[i] = f.Fd()
+		}
+	}
+
+	attr := &syscall.ProcAttr{
+		Dir:   sh.dir,
+		Env:   env,
+		Files: files,
+		Sys: &syscall.SysProcAttr{
+			Setpgid:    sh.interactive,
+			Pgid:       pgid,
+			Foreground: fg && sh.interactive,
+			Ctty:       ttyFd,
+		},
+	}
+
+	pid, err := syscall.ForkExec(path, args, attr)
+	runtime.KeepAlive(fds)
+	return pid, err
+}
+
+// lookPath — файл для запуска команды name: имя с / берётся как есть, остальные ищутся в PATH
+// из окружения команды env, а без него — в PATH shell. Ошибки — как у exec.LookPath
+func (sh *Interpreter) lookPath(name string, env []string) (string, error) {
+	if strings.Contains(name, "/") {
+		if err := sh.checkExecutable(name); err != nil {
+			return "", &exec.Error{Name: name, Err: err}
+		}
+		return name, nil
+	}
+
+	path, ok := sh.lookupVar("PATH")
+	for _, kv := range env {
+		if v, found := strings.CutPrefix(kv, "PATH="); found {
+			path, ok = v, true
+		}
+	}
+	if ok {
+		for _, dir := range filepath.SplitList(path) {
+			if dir == "" {
+				dir = "."
+			}
+			if file := filepath.Join(dir, name); sh.checkExecutable(file) == nil {
+				return file, nil
+			}
+		}
+	}
+	return "", &exec.Error{Name: name, Err: exec.ErrNotFound}
+}
+
+// checkExecutable — файл можно запустить: не каталог и с правом на выполнение
+func (sh *Interpreter) checkExecutable(file string) error {
+	fi, err := sh.stat(file)
+	switch {
+	case err != nil:
+		return err
+	case fi.IsDir():
+		return syscall.EISDIR
+	case fi.Mode()&0o111 == 0:
+		return fs.ErrPermission
+	}
+	return nil
+}
+
+// waitJob — ждёт, пока задание не завершится или не остановится (Ctrl+Z)
+func waitJob(j *Job) {
+	for j.State() == JobRunning {
+		target := -j.Pgid
+		if j.Pgid == 0 { // своей группы нет — ждём процессы по одному
+			target = j.running().Pid
+		}
+
+		var ws syscall.WaitStatus
+		var ru syscall.Rusage
+		pid, err := syscall.Wait4(target, &ws, syscall.WUNTRACED, &ru)
+		if err == syscall.EINTR {
+			continue
+		}
+		if err != nil { // ECHILD: ждать больше некого
+			markDone(j, target)
+			continue
+		}
+		j.update(pid, ws, &ru)
+	}
+}
+
+// reapJob — забирает статусы процессов задания без блокировки
+func reapJob(j *Job) {
+	targets := []int{-j.Pgid}
+	if j.Pgid == 0 {
+		targets = targets[:0]
+		for _, p := range j.Procs {
+			if !p.Done {
+				targets = append(targets, p.Pid)
+			}
+		}
+	}
+
+	for _, target := range targets {
+		for j.State() != JobDone {
+			var ws syscall.WaitStatus
+			var ru syscall.Rusage
+			pid, err := syscall.Wait4(target, &ws, syscall.WNOHANG|syscall.WUNTRACED|syscall.WCONTINUED, &ru)
+			if err == syscall.EINTR {
+				continue
+			}
+			if err != nil {
+				markDone(j, target)
+				break
+			}
+			if pid == 0 { // изменений нет
+				break
+			}
+			j.update(pid, ws, &ru)
+		}
+	}
+}
+
+// running — первый работающий процесс задания
+func (j *Job) running() *Process {
+	for _, p := range j.Procs {
+		if !p.Done && !p.Stopped {
+			return p
+		}
+	}
+	return nil
+}
+
+// markDone — помечает завершёнными процессы target (pid или -pgid), статус которых уже не получить
+func markDone(j *Job, target int) {
+	for _, p := range j.Procs {
+		if !p.Done && (target < 0 || p.Pid == target) {
+			p.Done, p.Stopped = true, false
+		}
+	}
+}
+
+// foreground — отдаёт заданию терминал и ждёт его; остановленное задание попадает в таблицу
+func (sh *Interpreter) foreground(j *Job) int {
+	j.Background = false
+	if sh.interactive {
+		_ = tcsetpgrp(ttyFd, j.Pgid)
+	}
+
+	// отмена Run убивает задание. pid собираются заранее: процессы задания меняет ожидание
+	pgid, pids := j.Pgid, make([]int, 0, len(j.Procs))
+	var running []*Process // ресурсы тех, кто завершится сейчас, попадут в учёт для time
+	for _, p := range j.Procs {
+		pids = append(pids, p.Pid)
+		if !p.Done {
+			running = append(running, p)
+		}
+	}
+	stop := context.AfterFunc(sh.ctx, func() {
+		if pgid != 0 {
+			_ = syscall.Kill(-pgid, syscall.SIGKILL)
+			return
+		}
+		for _, pid := range pids {
+			_ = syscall.Kill(pid, syscall.SIGKILL)
+		}
+	})
+	waitJob(j)
+	stop()
+	for _, p := range running {
+		if p.Done {
+			sh.childUsage.add(&p.Usage)
+		}
+	}
+
+	if sh.interactive {
+		_ = tcsetpgrp(ttyFd, shellPgid)
+	}
+
+	switch j.State() {
+	case JobStopped:
+		j.Background = true
+		j.notified = true
+		sh.jobs.add(j)
+		fmt.Fprintf(sh.errOut(), "\n%s\n", jobLine(j, sh.jobs.mark(j)))
+	case JobDone:
+		sh.jobs.remove(j)
+	}
+	return j.exitCode(sh.setOptions["pipefail"])
+}
+
+// notifyJobs — опрашивает задания и сообщает в w о завершённых и остановленных вне переднего плана
+func (sh *Interpreter) notifyJobs(w io.Writer) {
+	for _, j := range sh.jobs.snapshot() {
+		reapJob(j)
+
+		switch j.State() {
+		case JobDone:
+			fmt.Fprintln(w, jobLine(j, sh.jobs.mark(j)))
+			sh.jobs.remove(j)
+		case JobStopped:
+			if !j.notified {
+				fmt.Fprintln(w, jobLine(j, sh.jobs.mark(j)))
+				j.notified = true
+			}
+		}
+	}
+}
+
+// builtinJobs — список заданий; -l добавляет pid процессов, -p выводит только группы процессов
+func (sh *Interpreter) builtinJobs(args []string, out io.Writer) (int, error) {
+	long, pids := false, false
+	for _, a := range args {
+		switch a {
+		case "-l":
+			long = true
+		case "-p":
+			pids = true
+		default:
+			return 1, fmt.Errorf("jobs: invalid option %s", a)
+		}
+	}
+
+	for _, j := range sh.jobs.snapshot() {
+		reapJob(j)
+		switch {
+		case pids:
+			fmt.Fprintln(out, j.leader())
+		case long:
+			fmt.Fprintf(out, "[%d]%c  %d %-24s%s\n", j.ID, sh.jobs.mark(j), j.leader(), j.stateText(), j.Cmd)
+		default:
+			fmt.Fprintln(out, jobLine(j, sh.jobs.mark(j)))
+		}
+		if j.State() == JobDone {
+			sh.jobs.remove(j)
+		}
+	}
+	return 0, nil
+}
+
+// builtinFg — продолжает задание на переднем плане
+func (sh *Interpreter) builtinFg(args []string, out io.Writer) (int, error) {
+	spec := ""
+	if len(args) > 0 {
+		spec = args[0]
+	}
+	j, err := sh.jobs.find(spec)
+	if err != nil {
+		return 1, fmt.Errorf("fg: %w", err)
+	}
+
+	fmt.Fprintln(out, j.Cmd)
+	sh.jobs.add(j) // становится текущим
+	if sh.interactive {
+		_ = tcsetpgrp(ttyFd, j.Pgid)
+	}
+	if err := j.continueJob(); err != nil {
+		return 1, fmt.Errorf("fg: %w", err)
+	}
+	return sh.foreground(j), nil
+}
+
+// builtinBg — продолжает остановленные задания в фоне
+func (sh *Interpreter) builtinBg(args []string, out io.Writer) (int, error) {
+	if len(args) == 0 {
+		args = []string{""}
+	}
+
+	for _, spec := range args {
+		j, err := sh.jobs.find(spec)
+		if err != nil {
+			return 1, fmt.Errorf("bg: %w", err)
+		}
+		if j.State() == JobRunning {
+			fmt.Fprintf(out, "bg: job %d already in background\n", j.ID)
+			continue
+		}
+
+		j.Background = true
+		sh.jobs.add(j)
+		if err := j.continueJob(); err != nil {
+			return 1, fmt.Errorf("bg: %w", err)
+		}
+		fmt.Fprintf(out, "[%d]%c %s &\n", j.ID, sh.jobs.mark(j), j.Cmd)
+	}
+	return 0, nil
+}
+
+// hangupJobs — SIGHUP заданиям при выходе интерактивного shell; остановленные продолжаются, чтобы его получить
+func (sh *Interpreter) hangupJobs() {
+	for _, j := range sh.jobs.snapshot() {
+		reapJob(j)
+		switch j.State() {
+		case JobRunning:
+			_ = j.signal(syscall.SIGHUP)
+		case JobStopped:
+			_ = j.signal(syscall.SIGHUP)
+			_ = j.continueJob()
+		}
+	}
+}
+
+// builtinWait — wait [pid | %задание ...]: ждёт завершения фоновых заданий, без аргументов — всех, с кодом 0.
+// Код — код последнего из ожидаемых: задания или процесса pid; о неизвестном pid или задании — ошибка с кодом 127.
+// Перехваченный сигнал прерывает ожидание с кодом 128+номер сигнала, ловушка выполняется сразу
+func (sh *Interpreter) builtinWait(args []string) (int, error) {
+	// SIGCHLD будит ожидание; подписка до опроса заданий, иначе сигнал от уже завершившегося можно пропустить
+	chld := make(chan os.Signal, 1)
+	signal.Notify(chld, syscall.SIGCHLD)
+	defer signal.Stop(chld)
+
+	if len(args) == 0 {
+		for _, j := range sh.jobs.snapshot() {
+			if code, ok := sh.waitBackground(j, chld); !ok {
+				return code, nil
+			}
+		}
+		return 0, nil
+	}
+
+	code := 0
+	var errs error
+	for _, spec := range args {
+		var j *Job
+		var proc *Process
+		if strings.HasPrefix(spec, "%") {
+			var err error
+			if j, err = sh.jobs.find(spec); err != nil {
+				errs, code = errors.Join(errs, fmt.Errorf("wait: %w", err)), 127
+				continue
+			}
+		} else {
+			pid, err := strconv.Atoi(spec)
+			if err != nil {
+				errs, code = errors.Join(errs, fmt.Errorf("wait: `%s': not a pid or valid job spec", spec)), 1
+				continue
+			}
+			if j, proc = sh.jobs.findPid(pid); j == nil {
+				var ok bool
+				if code, ok = sh.jobs.finishedCode(pid); !ok {
+					errs, code = errors.Join(errs, fmt.Errorf("wait: pid %d is not a child of this shell", pid)), 127
+				}
+				continue
+			}
+		}
+
+		c, ok := sh.waitBackground(j, chld)
+		if !ok {
+			return c, errs
+		}
+		code = c
+		if proc != nil && proc.Done {
+			code = waitCode(proc.Status)
+		}
+	}
+	return code, errs
+}
+
+// waitBackground — ждёт, пока фоновое задание не завершится или не остановится; код — как у Job.exitCode.
+// false — ожидание прервал сигнал или отмена Run, код тогда 128+номер сигнала или 1
+func (sh *Interpreter) waitBackground(j *Job, chld <-chan os.Signal) (int, bool) {
+	for {
+		reapJob(j)
+		if j.State() != JobRunning {
+			break
+		}
+		select {
+		case <-chld:
+		case s := <-sh.signals:
+			sig := s.(syscall.Signal)
+			sh.handleSignal(sig)
+			return 128 + int(sig), false
+		case <-sh.ctx.Done():
+			return 1, false
+		}
+	}
+
+	if j.State() == JobDone {
+		sh.jobs.remove(j)
+	}
+	return j.exitCode(sh.setOptions["pipefail"]), true
+}
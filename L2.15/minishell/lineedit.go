@@ -0,0 +1,410 @@
+package minishell
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// lineReader — источник строк команд: печатает приглашение и возвращает строку с \n на конце
+type lineReader interface {
+	readLine(prompt string) (string, error)
+}
+
+// fileLines — строки из файла, строки -c или stdin, который не терминал
+type fileLines struct {
+	r *bufio.Reader
+}
+
+func (f *fileLines) readLine(prompt string) (string, error) {
+	fmt.Print(prompt)
+	return f.r.ReadString('\n')
+}
+
+// errInterrupted — ввод строки прерван Ctrl+C
+var errInterrupted = errors.New("interrupted")
+
+// Клавиши, которые приходят escape-последовательностями; обычные символы и Ctrl+буква — сами руны
+const (
+	keyLeft rune = -1 - iota
+	keyRight
+	keyUp
+	keyDown
+	keyHome
+	keyEnd
+	keyDelete
+	keyWordLeft
+	keyWordRight
+	keyUnknown
+)
+
+// escapeKeys — окончания последовательностей ESC [ и ESC O
+var escapeKeys = map[string]rune{
+	"A": keyUp, "B": keyDown, "C": keyRight, "D": keyLeft, "H": keyHome, "F": keyEnd,
+	"1~": keyHome, "7~": keyHome, "4~": keyEnd, "8~": keyEnd, "3~": keyDelete,
+	"1;5C": keyWordRight, "1;3C": keyWordRight, "1;5D": keyWordLeft, "1;3D": keyWordLeft,
+}
+
+// ctrl — код клавиши Ctrl+c
+func ctrl(c byte) rune {
+	return rune(c & 0x1f)
+}
+
+// lineEditor — редактор строки на терминале: курсор, правка в стиле emacs, история с поиском
+// и дополнение по Tab
+type lineEditor struct {
+	in   io.Reader // клавиши; читается по байту, чтобы не забрать ввод следующей команды
+	out  io.Writer
+	fd   int          // терминал для посимвольного режима и ширины строки; -1 — не переключать
+	hist *history     // nil — без истории
+	sh   *Interpreter // откуда берутся варианты дополнения
+
+	prompt  string // приглашение; при перерисовке выводится его последняя строка
+	buf     []rune
+	pos     int    // курсор в buf
+	histPos int    // строка истории в buf; len(hist.lines) — новая строка
+	saved   []rune // новая строка, пока листается история
+}
+
+// readLine — читает строку с правкой. Ctrl+D на пустой строке — io.EOF, Ctrl+C — errInterrupted
+func (e *lineEditor) readLine(prompt string) (string, error) {
+	if e.fd >= 0 {
+		old, err := makeRaw(e.fd)
+		if err != nil {
+			return "", err
+		}
+		defer restoreTerminal(e.fd, old)
+	}
+
+	e.prompt = prompt
+	e.buf, e.pos, e.saved = nil, 0, nil
+	e.histPos = len(e.historyLines())
+	io.WriteString(e.out, prompt)
+
+	lastTab := false
+	for {
+		k, err := e.readKey()
+		if err == nil && k == ctrl('R') {
+			k, err = e.search()
+		}
+		if errors.Is(err, io.EOF) && len(e.buf) > 0 {
+			k, err = '\r', nil
+		}
+		if err != nil {
+			return "", err
+		}
+
+		switch k {
+		case '\r', '\n':
+			e.pos = len(e.buf)
+			e.refresh()
+			io.WriteString(e.out, "\r\n")
+			return string(e.buf) + "\n", nil
+
+		case ctrl('C'):
+			io.WriteString(e.out, "^C\r\n")
+			return "", errInterrupted
+
+		case ctrl('D'):
+			if len(e.buf) == 0 {
+				return "", io.EOF
+			}
+			e.cut(e.pos, e.pos+1)
+
+		case ctrl('A'), keyHome:
+			e.pos = 0
+		case ctrl('E'), keyEnd:
+			e.pos = len(e.buf)
+		case ctrl('B'), keyLeft:
+			e.pos = max(e.pos-1, 0)
+		case ctrl('F'), keyRight:
+			e.pos = min(e.pos+1, len(e.buf))
+		case keyWordLeft:
+			e.pos = e.wordBefore(e.pos, isWordRune)
+		case keyWordRight:
+			e.pos = e.wordAfter(e.pos, isWordRune)
+
+		case 127, ctrl('H'):
+			if e.pos > 0 {
+				e.cut(e.pos-1, e.pos)
+			}
+		case keyDelete:
+			e.cut(e.pos, e.pos+1)
+		case ctrl('W'):
+			e.cut(e.wordBefore(e.pos, notSpace), e.pos)
+		case ctrl('U'):
+			e.cut(0, e.pos)
+		case ctrl('K'):
+			e.cut(e.pos, len(e.buf))
+
+		case ctrl('L'):
+			io.WriteString(e.out, "\x1b[H\x1b[2J"+e.prompt)
+
+		case ctrl('P'), keyUp:
+			e.showHistory(e.histPos - 1)
+		case ctrl('N'), keyDown:
+			e.showHistory(e.histPos + 1)
+
+		case '\t':
+			e.complete(lastTab)
+
+		default:
+			if k >= ' ' {
+				e.insert([]rune{k})
+			}
+		}
+
+		lastTab = k == '\t'
+		e.refresh()
+	}
+}
+
+// historyLines — строки истории; без истории пусто
+func (e *lineEditor) historyLines() []string {
+	if e.hist == nil {
+		return nil
+	}
+	return e.hist.lines
+}
+
+// showHistory — переход к строке истории i; новая строка при этом запоминается
+func (e *lineEditor) showHistory(i int) {
+	lines := e.historyLines()
+	if i < 0 || i > len(lines) {
+		return
+	}
+	if e.histPos == len(lines) {
+		e.saved = e.buf
+	}
+
+	e.histPos = i
+	if i == len(lines) {
+		e.buf = e.saved
+	} else {
+		e.buf = []rune(lines[i])
+	}
+	e.pos = len(e.buf)
+}
+
+// search — поиск назад по истории (Ctrl+R): набранный текст ищется в строках от новых к старым,
+// повторный Ctrl+R ищет дальше. Ctrl+G возвращает прежнюю строку; другая клавиша оставляет найденную
+// строку и возвращается для обычной обработки
+func (e *lineEditor) search() (rune, error) {
+	lines := e.historyLines()
+	origBuf, origPos, origHist := e.buf, e.pos, e.histPos
+	var query []rune
+	match := len(lines)
+	failed := false
+
+	// find — ищет запрос в строках от from к старым
+	find := func(from int) {
+		q := string(query)
+		for i := min(from, len(lines)-1); i >= 0; i-- {
+			if j := strings.Index(lines[i], q); j >= 0 {
+				match, failed = i, false
+				e.buf = []rune(lines[i])
+				e.pos = utf8.RuneCountInString(lines[i][:j])
+				return
+			}
+		}
+		failed = true
+	}
+
+	for {
+		state := "reverse-i-search"
+		if failed {
+			state = "failed " + state
+		}
+		fmt.Fprintf(e.out, "\r(%s)`%s': %s\x1b[K", state, string(query), string(e.buf))
+
+		k, err := e.readKey()
+		if err != nil {
+			return 0, err
+		}
+
+		switch {
+		case k == ctrl('R'):
+			if len(query) > 0 {
+				find(match - 1)
+			}
+		case k == 127 || k == ctrl('H'):
+			if len(query) > 0 {
+				query = query[:len(query)-1]
+				find(len(lines) - 1)
+			}
+		case k == ctrl('G'):
+			e.buf, e.pos, e.histPos = origBuf, origPos, origHist
+			return 0, nil
+		case k >= ' ':
+			query = append(query, k)
+			find(match)
+		default:
+			if match < len(lines) {
+				e.histPos = match
+			}
+			return k, nil
+		}
+	}
+}
+
+// refresh — перерисовывает строку. Не помещающаяся в ширину терминала строка сдвигается так, чтобы курсор был виден
+func (e *lineEditor) refresh() {
+	width := 80
+	if e.fd >= 0 {
+		width = terminalWidth(e.fd)
+	}
+	prompt := e.prompt[strings.LastIndexByte(e.prompt, '\n')+1:]
+	avail := max(width-visibleWidth(prompt)-1, 1)
+	start := max(e.pos-avail, 0)
+	end := min(len(e.buf), start+avail)
+
+	var b strings.Builder
+	// многострочная команда из истории показывается в одной строке: перевод строки — символом ↵
+	b.WriteString("\r" + prompt + strings.ReplaceAll(string(e.buf[start:end]), "\n", "↵") + "\x1b[K")
+	if back := end - e.pos; back > 0 {
+		fmt.Fprintf(&b, "\x1b[%dD", back)
+	}
+	io.WriteString(e.out, b.String())
+}
+
+// visibleWidth — ширина текста на экране: без escape-последовательностей ESC [ ... буква
+func visibleWidth(s string) int {
+	n := 0
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\x1b' && i+1 < len(s) && s[i+1] == '[' {
+			for i += 2; i < len(s) && (s[i] < 0x40 || s[i] > 0x7e); i++ {
+			}
+			continue
+		}
+		if utf8.RuneStart(s[i]) {
+			n++
+		}
+	}
+	return n
+}
+
+// insert — вставляет текст в позицию курсора
+func (e *lineEditor) insert(r []rune) {
+	e.buf = append(e.buf[:e.pos:e.pos], append(r, e.buf[e.pos:]...)...)
+	e.pos += len(r)
+}
+
+// cut — удаляет buf[from:to], курсор встаёт на from
+func (e *lineEditor) cut(from, to int) {
+	to = min(to, len(e.buf))
+	if from >= to {
+		return
+	}
+	e.buf = append(e.buf[:from:from], e.buf[to:]...)
+	e.pos = from
+}
+
+// wordBefore — начало слова перед pos: пропускаются разделители, затем символы слова
+func (e *lineEditor) wordBefore(pos int, inWord func(rune) bool) int {
+	for pos > 0 && !inWord(e.buf[pos-1]) {
+		pos--
+	}
+	for pos > 0 && inWord(e.buf[pos-1]) {
+		pos--
+	}
+	return pos
+}
+
+// wordAfter — конец слова после pos
+func (e *lineEditor) wordAfter(pos int, inWord func(rune) bool) int {
+	for pos < len(e.buf) && !inWord(e.buf[pos]) {
+		pos++
+	}
+	for pos < len(e.buf) && inWord(e.buf[pos]) {
+		pos++
+	}
+	return pos
+}
+
+// isWordRune — буква или цифра: слова для Alt+B, Alt+F
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// notSpace — не пробел: слова для Ctrl+W
+func notSpace(r rune) bool {
+	return r != ' ' && r != '\t'
+}
+
+// readKey — следующая клавиша: символ, Ctrl+буква или одна из key*
+func (e *lineEditor) readKey() (rune, error) {
+	c, err := e.readByte()
+	if err != nil {
+		return 0, err
+	}
+	if c == 0x1b {
+		return e.readEscape()
+	}
+	if c < utf8.RuneSelf {
+		return rune(c), nil
+	}
+
+	// многобайтовый символ UTF-8
+	b := []byte{c}
+	for !utf8.FullRune(b) {
+		c, err := e.readByte()
+		if err != nil {
+			return 0, err
+		}
+		b = append(b, c)
+	}
+	r, _ := utf8.DecodeRune(b)
+	return r, nil
+}
+
+// readEscape — клавиша после ESC: стрелки, Home, End, Delete, Alt+B и Alt+F
+func (e *lineEditor) readEscape() (rune, error) {
+	c, err := e.readByte()
+	if err != nil {
+		return 0, err
+	}
+	switch c {
+	case 'b', 'B':
+		return keyWordLeft, nil
+	case 'f', 'F':
+		return keyWordRight, nil
+	case '[', 'O':
+	default:
+		return keyUnknown, nil
+	}
+
+	// параметры до завершающего символа
+	var seq []byte
+	for {
+		c, err := e.readByte()
+		if err != nil {
+			return 0, err
+		}
+		seq = append(seq, c)
+		if c >= 0x40 && c <= 0x7e {
+			break
+		}
+	}
+	if k, ok := escapeKeys[string(seq)]; ok {
+		return k, nil
+	}
+	return keyUnknown, nil
+}
+
+// readByte — один байт ввода
+func (e *lineEditor) readByte() (byte, error) {
+	var b [1]byte
+	for {
+		n, err := e.in.Read(b[:])
+		if n == 1 {
+			return b[0], nil
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+}
@@ -0,0 +1,1953 @@
+package minishell
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func captureOutput(fn func()) string {
+	r, w, _ := os.Pipe()
+	old := os.Stdout
+	os.Stdout = w
+
+	fn()
+
+	_ = w.Close()
+	os.Stdout = old
+
+	var buf bytes.Buffer
+	_, _ = io.Copy(&buf, r)
+	return buf.String()
+}
+
+// newShell — интерпретатор с текущим каталогом dir ("" — каталог теста) и окружением процесса
+func newShell(t *testing.T, dir string) *Interpreter {
+	t.Helper()
+	sh, err := NewInterpreter(dir, os.Environ())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sh
+}
+
+// Подоболочки-процессы перезапускают os.Executable() с --subshell N — в тестах это тестовый бинарник,
+// подоболочкой он становится ещё при инициализации пакета. С MINISHELL_TEST_SHELL он работает как minishell
+// ("$0" в тестах)
+func TestMain(m *testing.M) {
+	if os.Getenv("MINISHELL_TEST_SHELL") != "" {
+		os.Exit(Main(os.Args[1:]))
+	}
+	os.Setenv("MINISHELL_TEST_SHELL", "1")
+	os.Exit(m.Run())
+}
+
+// parseLine — разбирает строку в дерево
+func parseLine(t *testing.T, line string) *ListNode {
+	t.Helper()
+	toks, err := tokenize(line)
+	if err != nil {
+		t.Fatalf("%q: %v", line, err)
+	}
+	list, err := parse(toks, line)
+	if err != nil {
+		t.Fatalf("%q: %v", line, err)
+	}
+	return list
+}
+
+// firstPipeline — конвейер первого элемента списка
+func firstPipeline(t *testing.T, list *ListNode) *PipelineNode {
+	t.Helper()
+	pipe, ok := list.Items[0].Node.(*PipelineNode)
+	if !ok {
+		t.Fatalf("expected pipeline, got %T", list.Items[0].Node)
+	}
+	return pipe
+}
+
+func TestTokenizerSimple(t *testing.T) {
+	line := "echo hello world"
+	toks, err := tokenize(line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(toks) != 3 {
+		t.Fatalf("expected 3 tokens, got %d", len(toks))
+	}
+	if toks[0].Val != "echo" || toks[1].Val != "hello" || toks[2].Val != "world" {
+		t.Fatalf("unexpected tokens: %+v", toks)
+	}
+}
+
+func TestTokenizerPipeAndAndOr(t *testing.T) {
+	line := "ps | grep ssh && echo ok || echo fail"
+	toks, err := tokenize(line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"ps", "|", "grep", "ssh", "&&", "echo", "ok", "||", "echo", "fail"}
+	if len(toks) != len(want) {
+		t.Fatalf("unexpected number of tokens: %d", len(toks))
+	}
+}
+
+func TestTokenizerQuotes(t *testing.T) {
+	line := `echo "hello world"`
+	toks, err := tokenize(line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if toks[1].Val != "hello world" {
+		t.Fatalf("expected quoted string preserved, got %q", toks[1].Val)
+	}
+}
+
+func TestExpandEnv(t *testing.T) {
+	os.Setenv("TESTVAR", "VALUE123")
+	sh := newShell(t, "")
+	out := sh.expandEnv("X=$TESTVAR Y=${TESTVAR}")
+	if out != "X=VALUE123 Y=VALUE123" {
+		t.Fatalf("unexpected env expansion: %q", out)
+	}
+}
+
+func TestParseSimpleCommand(t *testing.T) {
+	list := parseLine(t, "echo hello")
+	if len(list.Items) != 1 {
+		t.Fatalf("expected 1 list item")
+	}
+	pipe := firstPipeline(t, list)
+	if len(pipe.Cmds) != 1 {
+		t.Fatalf("expected 1 pipeline element")
+	}
+	unit := pipe.Cmds[0].(*CmdUnit)
+	if unit.Args[0] != "echo" ||
+		unit.Args[1] != "hello" {
+		t.Fatalf("unexpected args: %+v", unit.Args)
+	}
+}
+
+func TestParsePipeline(t *testing.T) {
+	pipe := firstPipeline(t, parseLine(t, "ps | grep ssh | wc -l"))
+	if len(pipe.Cmds) != 3 {
+		t.Fatalf("expected 3 pipeline cmds, got %d", len(pipe.Cmds))
+	}
+}
+
+func TestParseRedirects(t *testing.T) {
+	unit := firstPipeline(t, parseLine(t, "cat < input.txt > output.txt")).Cmds[0].(*CmdUnit)
+	want := []Redirect{{Fd: 0, Op: REDIR_IN, Target: "input.txt"}, {Fd: 1, Op: REDIR_OUT, Target: "output.txt"}}
+	if !reflect.DeepEqual(unit.Redirs, want) {
+		t.Fatalf("redirects parsed incorrectly: %+v", unit)
+	}
+}
+
+//
+// ---------------- BUILTIN TESTS ----------------
+//
+
+func TestBuiltinEcho(t *testing.T) {
+	var out bytes.Buffer
+	sh := newShell(t, "")
+	code, err := sh.runBuiltin("echo", []string{"hello", "world"}, nil, &out)
+	if err != nil || code != 0 {
+		t.Fatal("builtin echo failed")
+	}
+	if strings.TrimSpace(out.String()) != "hello world" {
+		t.Fatalf("unexpected echo output: %q", out.String())
+	}
+}
+
+func TestBuiltinPwd(t *testing.T) {
+	var out bytes.Buffer
+	sh := newShell(t, "")
+	code, err := sh.runBuiltin("pwd", nil, nil, &out)
+	if err != nil || code != 0 {
+		t.Fatal("pwd failed:", err)
+	}
+	if strings.TrimSpace(out.String()) == "" {
+		t.Fatal("pwd returned empty")
+	}
+}
+
+func TestBuiltinCd(t *testing.T) {
+	dir := t.TempDir()
+	sh := newShell(t, "")
+	code, err := sh.runBuiltin("cd", []string{dir}, nil, nil)
+	if err != nil || code != 0 {
+		t.Fatalf("cd failed: %v", err)
+	}
+	cwdEval, _ := filepath.EvalSymlinks(sh.Dir())
+	dirEval, _ := filepath.EvalSymlinks(dir)
+	if cwdEval != dirEval {
+		t.Fatalf("cd did not change directory: %s != %s", cwdEval, dirEval)
+	}
+	// каталог интерпретатора — не каталог процесса
+	if wd, _ := os.Getwd(); wd == dir {
+		t.Fatal("cd changed the process directory")
+	}
+}
+
+func TestRunSingleExternal(t *testing.T) {
+	unit := &CmdUnit{Args: []string{"echo", "OK"}}
+
+	sh := newShell(t, "")
+	out := captureOutput(func() {
+		_, err := sh.runSingle(unit, sh.stdFiles())
+		if err != nil {
+			t.Fatalf("runSingle error: %v", err)
+		}
+	})
+
+	if !strings.Contains(out, "OK") {
+		t.Fatalf("unexpected output: %q", out)
+	}
+}
+
+func TestPipelineBuiltins(t *testing.T) {
+	pipe := &PipelineNode{Cmds: []Node{
+		&CmdUnit{Args: []string{"echo", "HELLO"}},
+		&CmdUnit{Args: []string{"cat"}},
+	}}
+
+	sh := newShell(t, "")
+	out := captureOutput(func() {
+		_, err := sh.runPipeline(pipe, sh.stdFiles())
+		if err != nil {
+			t.Fatalf("runPipeline error: %v", err)
+		}
+	})
+
+	if !strings.Contains(out, "HELLO") {
+		t.Fatalf("pipeline output invalid: %q", out)
+	}
+}
+
+func TestConditionalAnd(t *testing.T) {
+	list := parseLine(t, "echo ok && echo success")
+
+	sh := newShell(t, "")
+	out := captureOutput(func() {
+		sh.runList(list, sh.stdFiles())
+	})
+
+	if !strings.Contains(out, "ok") || !strings.Contains(out, "success") {
+		t.Fatalf("AND operator failed: %q", out)
+	}
+}
+
+func TestConditionalOr(t *testing.T) {
+	list := parseLine(t, "badcmd || echo fallback")
+
+	sh := newShell(t, "")
+	out := captureOutput(func() {
+		sh.runList(list, sh.stdFiles())
+	})
+
+	if !strings.Contains(out, "fallback") {
+		t.Fatalf("OR operator failed: %q", out)
+	}
+}
+
+func TestRedirectOutput(t *testing.T) {
+	tmp := t.TempDir()
+	file := tmp + "/out.txt"
+
+	unit := &CmdUnit{
+		Args:   []string{"echo", "HELLO"},
+		Redirs: []Redirect{{Fd: 1, Op: REDIR_OUT, Target: file}},
+	}
+
+	sh := newShell(t, "")
+	_, err := sh.runSingle(unit, sh.stdFiles())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, _ := os.ReadFile(file)
+	if !strings.Contains(string(data), "HELLO") {
+		t.Fatalf("redirect failed: %q", string(data))
+	}
+}
+
+func TestRedirectInput(t *testing.T) {
+	tmp := t.TempDir()
+	file := tmp + "/in.txt"
+
+	os.WriteFile(file, []byte("WORLD"), 0644)
+
+	unit := &CmdUnit{
+		Args:   []string{"cat"},
+		Redirs: []Redirect{{Fd: 0, Op: REDIR_IN, Target: file}},
+	}
+
+	sh := newShell(t, "")
+	out := captureOutput(func() {
+		_, err := sh.runSingle(unit, sh.stdFiles())
+		if err != nil {
+			t.Fatalf("runSingle error: %v", err)
+		}
+	})
+
+	if strings.TrimSpace(out) != "WORLD" {
+		t.Fatalf("stdin redirect failed: %q", out)
+	}
+}
+
+func TestTokenizerBackground(t *testing.T) {
+	toks, err := tokenize("sleep 1& echo a&&echo b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []TokenKind{TK_WORD, TK_WORD, TK_AMP, TK_WORD, TK_WORD, TK_AND, TK_WORD, TK_WORD}
+	if len(toks) != len(want) {
+		t.Fatalf("unexpected tokens: %+v", toks)
+	}
+	for i, k := range want {
+		if toks[i].Kind != k {
+			t.Fatalf("token %d: expected kind %d, got %+v", i, k, toks[i])
+		}
+	}
+}
+
+func TestParseBackground(t *testing.T) {
+	list := parseLine(t, "sleep 1 | cat & echo done")
+	if len(list.Items) != 2 || !list.Items[0].Background || list.Items[1].Background ||
+		len(firstPipeline(t, list).Cmds) != 2 || list.Items[0].Src != "sleep 1 | cat" {
+		t.Fatalf("unexpected list: %+v", list.Items)
+	}
+
+	list = parseLine(t, "true && sleep 1 &")
+	if _, ok := list.Items[0].Node.(*AndOrNode); !ok || !list.Items[0].Background {
+		t.Fatalf("expected backgrounded && list, got %+v", list.Items[0])
+	}
+}
+
+func TestBackgroundJob(t *testing.T) {
+	sh := newShell(t, "")
+	if code := sh.runList(parseLine(t, "sleep 0.2 &"), sh.stdFiles()); code != 0 {
+		t.Fatalf("background launch failed: code %d", code)
+	}
+
+	var out bytes.Buffer
+	_, _ = sh.builtinJobs(nil, &out)
+	if !strings.Contains(out.String(), "Running") || !strings.Contains(out.String(), "sleep 0.2 &") {
+		t.Fatalf("job not listed: %q", out.String())
+	}
+
+	j, err := sh.jobs.find("%sleep")
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitJob(j)
+
+	out.Reset()
+	sh.notifyJobs(&out)
+	if !strings.Contains(out.String(), "Done") {
+		t.Fatalf("expected Done notification, got %q", out.String())
+	}
+	if len(sh.jobs.snapshot()) != 0 {
+		t.Fatal("finished job was not removed")
+	}
+}
+
+func TestJobFind(t *testing.T) {
+	first := &Job{Cmd: "sleep 10"}
+	second := &Job{Cmd: "vim notes.txt"}
+	sh := newShell(t, "")
+	sh.jobs.add(first)
+	sh.jobs.add(second)
+	defer sh.jobs.remove(first)
+	defer sh.jobs.remove(second)
+
+	tests := []struct {
+		spec string
+		want *Job
+	}{
+		{"", second},
+		{"%%", second},
+		{"%+", second},
+		{"%-", first},
+		{fmt.Sprintf("%%%d", first.ID), first},
+		{strconv.Itoa(second.ID), second},
+		{"%sle", first},
+		{"%?notes", second},
+	}
+	for _, tt := range tests {
+		j, err := sh.jobs.find(tt.spec)
+		if err != nil || j != tt.want {
+			t.Errorf("find(%q): got %+v, %v", tt.spec, j, err)
+		}
+	}
+
+	if _, err := sh.jobs.find("%nope"); err == nil {
+		t.Error("expected error for unknown job")
+	}
+}
+
+func TestParseRedirectForms(t *testing.T) {
+	tests := []struct {
+		line string
+		want []Redirect
+	}{
+		{"cmd >>log", []Redirect{{Fd: 1, Op: REDIR_APPEND, Target: "log"}}},
+		{"cmd 2>err 3<in", []Redirect{{Fd: 2, Op: REDIR_OUT, Target: "err"}, {Fd: 3, Op: REDIR_IN, Target: "in"}}},
+		{"cmd 2>>err", []Redirect{{Fd: 2, Op: REDIR_APPEND, Target: "err"}}},
+		{"cmd>out 2>&1", []Redirect{{Fd: 1, Op: REDIR_OUT, Target: "out"}, {Fd: 2, Op: REDIR_DUP, Target: "1"}}},
+		{"cmd >&2", []Redirect{{Fd: 1, Op: REDIR_DUP, Target: "2"}}},
+		{"cmd 0<&-", []Redirect{{Fd: 0, Op: REDIR_DUP, Target: "-"}}},
+		{"cmd &>all", []Redirect{{Fd: 1, Op: REDIR_OUT, Target: "all"}, {Fd: 2, Op: REDIR_DUP, Target: "1"}}},
+		{"cmd &>>all", []Redirect{{Fd: 1, Op: REDIR_APPEND, Target: "all"}, {Fd: 2, Op: REDIR_DUP, Target: "1"}}},
+		{"cmd <<-'EOF'", []Redirect{{Fd: 0, Op: REDIR_HEREDOC, Target: "EOF", StripTabs: true, Quoted: true}}},
+		{"cmd <<< word", []Redirect{{Fd: 0, Op: REDIR_HERESTRING, Target: "word"}}},
+		{`cmd >"$HOME/x"`, []Redirect{{Fd: 1, Op: REDIR_OUT, Target: `"$HOME/x"`}}},
+	}
+
+	for _, tt := range tests {
+		unit := firstPipeline(t, parseLine(t, tt.line)).Cmds[0].(*CmdUnit)
+		if len(unit.Args) != 1 || !reflect.DeepEqual(unit.Redirs, tt.want) {
+			t.Errorf("%q: got args %q, redirects %+v", tt.line, unit.Args, unit.Redirs)
+		}
+	}
+
+	for _, line := range []string{"cmd >", "cmd 2>&1x", "cmd > | cat"} {
+		toks, _ := tokenize(line)
+		if _, err := parse(toks, line); err == nil {
+			t.Errorf("%q: expected parse error", line)
+		}
+	}
+}
+
+func TestRedirectAppendAndStderr(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "log")
+
+	sh := newShell(t, "")
+	for _, line := range []string{"echo one >" + file, "echo two >>" + file, "ls /nonexistent-dir >>" + file + " 2>&1"} {
+		sh.runList(parseLine(t, line), sh.stdFiles())
+	}
+
+	data, _ := os.ReadFile(file)
+	lines := strings.Split(string(data), "\n")
+	if len(lines) < 3 || lines[0] != "one" || lines[1] != "two" || !strings.Contains(lines[2], "nonexistent-dir") {
+		t.Fatalf("unexpected file contents: %q", data)
+	}
+}
+
+func TestRedirectEveryPipelineStage(t *testing.T) {
+	dir := t.TempDir()
+	in := filepath.Join(dir, "in")
+	mid := filepath.Join(dir, "mid")
+	os.WriteFile(in, []byte("b\na\n"), 0644)
+
+	// stderr средней стадии уходит в файл, stdin последней — из here-string
+	list := parseLine(t, "sort <"+in+" | ls /nonexistent-dir 2>"+mid+" | cat <<<tail")
+
+	sh := newShell(t, "")
+	out := captureOutput(func() {
+		sh.runList(list, sh.stdFiles())
+	})
+
+	if out != "tail\n" {
+		t.Fatalf("unexpected output: %q", out)
+	}
+	if data, _ := os.ReadFile(mid); !strings.Contains(string(data), "nonexistent-dir") {
+		t.Fatalf("stderr of middle stage not redirected: %q", data)
+	}
+}
+
+func TestHeredoc(t *testing.T) {
+	os.Setenv("HEREVAR", "value")
+	list := parseLine(t, "cat <<EOF | cat - <<-'END'")
+
+	input := "x=$HEREVAR\nEOF\n\ty=$HEREVAR\n\tEND\nrest\n"
+	r := bufio.NewReader(strings.NewReader(input))
+	sh := newShell(t, "")
+	if err := sh.readHeredocs(&fileLines{r}, pendingHeredocs(list), ""); err != nil {
+		t.Fatal(err)
+	}
+	if rest, _ := r.ReadString('\n'); rest != "rest\n" {
+		t.Fatalf("heredoc consumed too much, next line %q", rest)
+	}
+
+	out := captureOutput(func() {
+		sh.runList(list, sh.stdFiles())
+	})
+	if out != "y=$HEREVAR\n" {
+		t.Fatalf("unexpected output: %q", out)
+	}
+
+	list = parseLine(t, "cat <<EOF")
+	_ = sh.readHeredocs(&fileLines{bufio.NewReader(strings.NewReader("x=$HEREVAR\nEOF\n"))}, pendingHeredocs(list), "")
+	out = captureOutput(func() {
+		sh.runList(list, sh.stdFiles())
+	})
+	if out != "x=value\n" {
+		t.Fatalf("unexpected output: %q", out)
+	}
+}
+
+func TestTokenizerWordRules(t *testing.T) {
+	tests := []struct {
+		line string
+		want []string
+	}{
+		{`a"b c"d`, []string{"ab cd"}},
+		{`'it'"'"'s' x`, []string{"it's", "x"}},
+		{`a\ b \"q\" \|`, []string{"a b", `"q"`, "|"}},
+		{`"a\"b\\c\d"`, []string{`a"b\c\d`}},
+		{`echo a|b>c&&d`, []string{"echo", "a", "|", "b", ">", "c", "&&", "d"}},
+		{`"" ''`, []string{"", ""}},
+	}
+
+	for _, tt := range tests {
+		toks, err := tokenize(tt.line)
+		if err != nil {
+			t.Fatalf("%q: %v", tt.line, err)
+		}
+		var got []string
+		for _, tok := range toks {
+			got = append(got, tok.Val)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q: expected %q, got %q", tt.line, tt.want, got)
+		}
+	}
+
+	for _, line := range []string{`echo "abc`, `echo 'abc`, `echo a"b'c`} {
+		if _, err := tokenize(line); err == nil {
+			t.Errorf("%q: expected unterminated quote error", line)
+		}
+	}
+}
+
+func TestExpandWordQuotes(t *testing.T) {
+	os.Setenv("TESTVAR", "VALUE123")
+	unit := firstPipeline(t, parseLine(t, `echo $TESTVAR '$TESTVAR' "$TESTVAR" \$TESTVAR "\$TESTVAR" x"${TESTVAR}"y`)).Cmds[0].(*CmdUnit)
+
+	want := []string{"echo", "VALUE123", "$TESTVAR", "VALUE123", "$TESTVAR", "$TESTVAR", "xVALUE123y"}
+	sh := newShell(t, "")
+	if got := sh.expandArgs(unit.Args); !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+}
+
+func TestParsePrecedence(t *testing.T) {
+	list := parseLine(t, "a && b || c | d; e")
+	if len(list.Items) != 2 {
+		t.Fatalf("expected 2 list items, got %d", len(list.Items))
+	}
+
+	// (a && b) || (c | d)
+	or, ok := list.Items[0].Node.(*AndOrNode)
+	if !ok || or.Op != TK_OR {
+		t.Fatalf("expected || at the top, got %+v", list.Items[0].Node)
+	}
+	and, ok := or.Left.(*AndOrNode)
+	if !ok || and.Op != TK_AND {
+		t.Fatalf("expected && on the left, got %+v", or.Left)
+	}
+	if pipe, ok := or.Right.(*PipelineNode); !ok || len(pipe.Cmds) != 2 || pipe.Src != "c | d" {
+		t.Fatalf("expected pipeline c | d on the right, got %+v", or.Right)
+	}
+	if list.Items[1].Src != "e" {
+		t.Fatalf("unexpected second item: %+v", list.Items[1])
+	}
+}
+
+func TestParseGroupsAndSubshells(t *testing.T) {
+	list := parseLine(t, "(cd /tmp; ls) >out | { echo a; echo }; }")
+	pipe := firstPipeline(t, list)
+
+	sub, ok := pipe.Cmds[0].(*Subshell)
+	if !ok || len(sub.Body.Items) != 2 || len(sub.Redirs) != 1 {
+		t.Fatalf("unexpected subshell: %+v", pipe.Cmds[0])
+	}
+	group, ok := pipe.Cmds[1].(*Group)
+	if !ok || len(group.Body.Items) != 2 {
+		t.Fatalf("unexpected group: %+v", pipe.Cmds[1])
+	}
+	// } не в начале команды — обычное слово
+	if args := group.Body.Items[1].Node.(*PipelineNode).Cmds[0].(*CmdUnit).Args; len(args) != 2 || args[1] != "}" {
+		t.Fatalf("unexpected args: %q", args)
+	}
+
+	for _, line := range []string{"(echo a", "echo a)", "{ echo a }", "echo a;;", "; echo", "()", "a && ", "a | | b", "echo (a)"} {
+		toks, _ := tokenize(line)
+		if _, err := parse(toks, line); err == nil {
+			t.Errorf("%q: expected parse error", line)
+		}
+	}
+}
+
+func TestSequentialList(t *testing.T) {
+	sh := newShell(t, "")
+	out := captureOutput(func() {
+		sh.runList(parseLine(t, "echo one; false; echo two;"), sh.stdFiles())
+	})
+	if out != "one\ntwo\n" {
+		t.Fatalf("unexpected output: %q", out)
+	}
+}
+
+func TestSubshell(t *testing.T) {
+	dir := t.TempDir()
+	sh := newShell(t, "")
+	cwd := sh.Dir()
+
+	var code int
+	out := captureOutput(func() {
+		code = sh.runList(parseLine(t, "(cd "+dir+"; pwd; false) || echo failed"), sh.stdFiles())
+	})
+
+	if now := sh.Dir(); now != cwd {
+		t.Fatalf("subshell changed parent directory to %s", now)
+	}
+	if code != 0 || out != dir+"\nfailed\n" {
+		t.Fatalf("unexpected output %q (code %d)", out, code)
+	}
+}
+
+func TestGroupRedirect(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "out")
+
+	sh := newShell(t, "")
+	out := captureOutput(func() {
+		sh.runList(parseLine(t, "{ echo a; ls /nonexistent-dir; echo b; } >"+file+" 2>&1; echo c"), sh.stdFiles())
+	})
+
+	data, _ := os.ReadFile(file)
+	lines := strings.Split(string(data), "\n")
+	if out != "c\n" || len(lines) != 4 || lines[0] != "a" || lines[2] != "b" {
+		t.Fatalf("unexpected output %q, file %q", out, data)
+	}
+}
+
+func TestCompoundInPipelineAndBackground(t *testing.T) {
+	sh := newShell(t, "")
+	out := captureOutput(func() {
+		sh.runList(parseLine(t, "{ echo b; echo a; } | sort; (echo x; echo y) | tr a-z A-Z"), sh.stdFiles())
+	})
+	if out != "a\nb\nX\nY\n" {
+		t.Fatalf("unexpected output: %q", out)
+	}
+
+	file := filepath.Join(t.TempDir(), "bg")
+	sh.runList(parseLine(t, "false || echo bg >"+file+" &"), sh.stdFiles())
+
+	j, err := sh.jobs.find("%false")
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitJob(j)
+	sh.jobs.remove(j)
+
+	if data, _ := os.ReadFile(file); string(data) != "bg\n" {
+		t.Fatalf("background list did not run: %q", data)
+	}
+}
+
+// runScript — выполняет текст как скрипт через Run и возвращает вывод и код
+func runScript(t *testing.T, sh *Interpreter, src string) (string, int) {
+	t.Helper()
+	var out bytes.Buffer
+	sh.Stdout = &out
+	defer func() { sh.Stdout = os.Stdout }()
+	code, _ := sh.Run(context.Background(), src)
+	return out.String(), code
+}
+
+func TestParseControlFlow(t *testing.T) {
+	list := parseLine(t, "if a; then b; elif c; then d; else e; fi >out")
+	n, ok := firstPipeline(t, list).Cmds[0].(*IfNode)
+	if !ok || len(n.Redirs) != 1 {
+		t.Fatalf("unexpected if: %+v", firstPipeline(t, list).Cmds[0])
+	}
+	if elif, ok := n.Else.(*IfNode); !ok || elif.Else == nil {
+		t.Fatalf("unexpected elif: %+v", n.Else)
+	}
+
+	list = parseLine(t, "for x in a 'b c'\ndo echo $x; done; while a; do b; done | c; case $x in (a|b) x;; *) y; esac")
+	f, ok := firstPipeline(t, list).Cmds[0].(*ForNode)
+	if !ok || f.Var != "x" || !f.In || !reflect.DeepEqual(f.Words, []string{"a", "'b c'"}) {
+		t.Fatalf("unexpected for: %+v", firstPipeline(t, list).Cmds[0])
+	}
+	if loop, ok := list.Items[1].Node.(*PipelineNode).Cmds[0].(*LoopNode); !ok || loop.Until {
+		t.Fatalf("unexpected loop: %+v", list.Items[1].Node)
+	}
+	c, ok := list.Items[2].Node.(*PipelineNode).Cmds[0].(*CaseNode)
+	if !ok || c.Word != "$x" || len(c.Items) != 2 || !reflect.DeepEqual(c.Items[0].Patterns, []string{"a", "b"}) {
+		t.Fatalf("unexpected case: %+v", list.Items[2].Node)
+	}
+
+	list = parseLine(t, "f() { echo; }; function g { echo; }")
+	if fn, ok := firstPipeline(t, list).Cmds[0].(*FuncDef); !ok || fn.Name != "f" {
+		t.Fatalf("unexpected function: %+v", firstPipeline(t, list).Cmds[0])
+	}
+
+	// незаконченные конструкции дочитываются, остальное — ошибки
+	for _, line := range []string{"if a; then b", "while a; do", "for x in a", "case a in", "f() {", "echo a &&", "echo 'a"} {
+		toks, err := tokenize(line)
+		if err == nil {
+			_, err = parse(toks, line)
+		}
+		if !errors.Is(err, errIncomplete) && !errors.Is(err, errUnterminated) {
+			t.Errorf("%q: expected incomplete input, got %v", line, err)
+		}
+	}
+	for _, line := range []string{"if a; fi", "then", "for 1 in a; do b; done", "while a; done", "case a in a) b;; esac esac", "f() echo"} {
+		toks, _ := tokenize(line)
+		if _, err := parse(toks, line); err == nil || errors.Is(err, errIncomplete) {
+			t.Errorf("%q: expected syntax error, got %v", line, err)
+		}
+	}
+}
+
+func TestControlFlow(t *testing.T) {
+	sh := newShell(t, "")
+	out, code := runScript(t, sh, `
+# комментарий
+for x in a b c d; do
+	if [ $x = b ]; then continue
+	elif [ $x = d ]; then break
+	fi
+	echo $x
+done
+until true; do echo never; done
+while true; do
+	for y in 1 2; do
+		while true; do break 2; done
+		echo unreachable
+	done
+	echo "after inner"
+	break
+done
+case "file.txt" in
+	*.go|*.c) echo code ;;
+	"*.txt") echo literal ;;
+	*.t[a-z]t) echo text ;;
+	*) echo other
+esac
+echo one \
+	two
+false
+`)
+	if out != "a\nc\nafter inner\ntext\none two\n" || code != 1 {
+		t.Fatalf("unexpected output %q (code %d)", out, code)
+	}
+}
+
+func TestFunctions(t *testing.T) {
+	sh := newShell(t, "")
+	out, code := runScript(t, sh, `
+greet() {
+	echo "hello $1 ${2}"
+	return 3
+	echo unreachable
+}
+greet world again || echo failed
+first() { for x; do echo $x; return; done; }
+first p q
+greet pipe | tr a-z A-Z
+greet out >/dev/null
+`)
+	if out != "hello world again\nfailed\np\nHELLO PIPE \n" || code != 3 {
+		t.Fatalf("unexpected output %q (code %d)", out, code)
+	}
+
+	if _, code := runScript(t, sh, "break; echo ok"); code != 0 {
+		t.Fatalf("break outside loop must not stop the script, code %d", code)
+	}
+	if out, code := runScript(t, sh, "echo a\nfi\necho b"); out != "a\n" || code != 2 {
+		t.Fatalf("syntax error must stop the script: %q (code %d)", out, code)
+	}
+}
+
+func TestMultilineHeredoc(t *testing.T) {
+	sh := newShell(t, "")
+	out, _ := runScript(t, sh, "if true; then\n\tcat <<-EOF | tr a-z A-Z\n\tbody $HOME\n\tEOF\n\tcat <<'X'\n$HOME\nX\nfi\n")
+	if out != "BODY "+strings.ToUpper(os.Getenv("HOME"))+"\n$HOME\n" {
+		t.Fatalf("unexpected output: %q", out)
+	}
+
+	// \ в here-doc без кавычек экранирует только $, ` и \
+	out, _ = runScript(t, sh, "cat <<EOF\n\\$HOME \\\\ \\`x\\` a\\b\nEOF\n")
+	if out != "$HOME \\ `x` a\\b\n" {
+		t.Fatalf("unexpected escaped here-doc: %q", out)
+	}
+}
+
+func TestMatchPattern(t *testing.T) {
+	tests := []struct {
+		pattern, s string
+		want       bool
+	}{
+		{"*", "", true},
+		{"a*c", "abbbc", true},
+		{"a*c", "abcd", false},
+		{"?b", "ab", true},
+		{"[a-c]x", "bx", true},
+		{"[!a-c]x", "bx", false},
+		{"[]]", "]", true},
+		{`\*`, "*", true},
+		{`\*`, "a", false},
+		{"*/*", "dir/file", true},
+		{"[abc", "[abc", true},
+	}
+	for _, tt := range tests {
+		if got := matchPattern(tt.pattern, tt.s); got != tt.want {
+			t.Errorf("matchPattern(%q, %q) = %v", tt.pattern, tt.s, got)
+		}
+	}
+
+	sh := newShell(t, "")
+	if p := sh.expandPattern(`"*".t?t`); !matchPattern(p, "*.txt") || matchPattern(p, "a.txt") {
+		t.Fatalf("quoted pattern chars must match literally: %q", p)
+	}
+}
+
+func TestVariables(t *testing.T) {
+	sh := newShell(t, "")
+
+	out, _ := runScript(t, sh, `
+MSH_LOCAL="a  b"
+echo [$MSH_LOCAL] ["$MSH_LOCAL"]
+sh -c 'echo child=[$MSH_LOCAL]'
+MSH_LOCAL=once sh -c 'echo prefix=[$MSH_LOCAL]'
+echo after=[$MSH_LOCAL]
+export MSH_EXP=1
+sh -c 'echo exported=$MSH_EXP'
+unset MSH_EXP
+echo unset=[${MSH_EXP-none}]
+`)
+	want := "[a b] [a  b]\nchild=[]\nprefix=[once]\nafter=[a b]\nexported=1\nunset=[none]\n"
+	if out != want {
+		t.Fatalf("expected %q, got %q", want, out)
+	}
+	if _, ok := os.LookupEnv("MSH_LOCAL"); ok {
+		t.Fatal("unexported variable leaked into environment")
+	}
+}
+
+func TestSpecialParams(t *testing.T) {
+	sh := newShell(t, "")
+
+	out, _ := runScript(t, sh, `
+set -- one "two three"
+echo $# "$1" $2
+for a in "$@"; do echo "<$a>"; done
+for a in $@; do echo "[$a]"; done
+echo "$*"
+false; echo $?
+f() { echo "$# $1"; }; f x y; echo $?
+(echo $$) | grep -qx $$ && echo same-pid
+sleep 0 & test -n "$!" && echo bg
+`)
+	want := "2 one two three\n<one>\n<two three>\n[one]\n[two]\n[three]\none two three\n1\n2 x\n0\nsame-pid\nbg\n"
+	if out != want {
+		t.Fatalf("expected %q, got %q", want, out)
+	}
+
+	// "$@" без параметров не даёт слов, "" — пустое слово
+	sh.positional = nil
+	if got := sh.expandArgs([]string{`"$@"`, `""`, `$EMPTY_MSH_VAR`}); !reflect.DeepEqual(got, []string{""}) {
+		t.Fatalf("unexpected fields: %q", got)
+	}
+}
+
+func TestParamOperators(t *testing.T) {
+	sh := newShell(t, "")
+	sh.setVar("P", "/usr/lib/file.tar.gz")
+
+	tests := []struct{ raw, want string }{
+		{"${P%.*}", "/usr/lib/file.tar"},
+		{"${P%%.*}", "/usr/lib/file"},
+		{"${P#*/}", "usr/lib/file.tar.gz"},
+		{"${P##*/}", "file.tar.gz"},
+		{"${P%\".gz\"}", "/usr/lib/file.tar"},
+		{"${#P}", "20"},
+		{"${NOPE:-a b}", "a b"},
+		{"${NOPE:-$P}", "/usr/lib/file.tar.gz"},
+		{"${P:+set}", "set"},
+		{"${NOPE+set}", ""},
+		{"${D:=default}", "default"},
+		{"$D", "default"},
+		{"x${P##*.}y", "xgzy"},
+		{"'${P}'", "${P}"},
+		{"\"${NOPE-}\"x", "x"},
+		{"${NOPE:-'q r'}", "q r"},
+	}
+	for _, tt := range tests {
+		if got := sh.expandWord(tt.raw); got != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.raw, tt.want, got)
+		}
+	}
+
+	// ${...} с пробелами внутри — одно слово
+	args := firstPipeline(t, parseLine(t, "echo ${NOPE:-a b} c")).Cmds[0].(*CmdUnit).Args
+	if len(args) != 3 || args[1] != "${NOPE:-a b}" {
+		t.Fatalf("unexpected words: %q", args)
+	}
+}
+
+func TestCommandSubstitution(t *testing.T) {
+	sh := newShell(t, "")
+
+	out, _ := runScript(t, sh, `
+echo "[$(echo hello   world)]" [$(echo a b)] [$(printf 'x\n\n')]
+X=$(echo "$(echo "nested  quotes")"); echo "$X"
+echo `+"`echo back \\`echo tick\\``"+`
+for f in $(printf '1 2\n3'); do echo -$f; done
+echo "$(cd /; pwd)" | grep -qx / && pwd | grep -vqx /
+echo $( (echo sub) )
+X=$(false); echo $?
+cat <<EOF
+doc $(echo cmd)
+EOF
+`)
+	want := "[hello world] [a b] [x]\nnested  quotes\nback tick\n-1\n-2\n-3\nsub\n1\ndoc cmd\n"
+	if out != want {
+		t.Fatalf("expected %q, got %q", want, out)
+	}
+
+	// $( в слове читается до парной скобки вместе с пробелами и кавычками
+	args := firstPipeline(t, parseLine(t, `echo $(echo ")" 'a b') x`)).Cmds[0].(*CmdUnit).Args
+	if len(args) != 3 || args[1] != `$(echo ")" 'a b')` {
+		t.Fatalf("unexpected words: %q", args)
+	}
+	if _, err := tokenize("echo $(echo a"); !errors.Is(err, errIncomplete) {
+		t.Fatalf("expected incomplete input, got %v", err)
+	}
+}
+
+func TestArithmetic(t *testing.T) {
+	sh := newShell(t, "")
+	sh.setVar("i", "5")
+	sh.setVar("v", "3+4")
+
+	tests := []struct {
+		expr string
+		want int64
+	}{
+		{"1 + 2 * 3", 7},
+		{"(1 + 2) * 3", 9},
+		{"10 / 3 + 10 % 3", 4},
+		{"2 ** 3 ** 2", 512},
+		{"-2 ** 2", 4},
+		{"1 < 2 && 3 >= 3", 1},
+		{"0 || 0", 0},
+		{"!5 + ~0", -1},
+		{"0 ? 1 : 2", 2},
+		{"1 << 4 | 1", 17},
+		{"0x10 + 010", 24},
+		{"i * 2", 10},
+		{"v * 2", 14},
+		{"unset_var_msh + 1", 1},
+		{"i++ + i", 11},
+		{"i += 10, i", 16},
+		{"0 && (i = 100)", 0},
+	}
+	for _, tt := range tests {
+		got, err := sh.evalArith(tt.expr)
+		if err != nil || got != tt.want {
+			t.Errorf("%s: expected %d, got %d (%v)", tt.expr, tt.want, got, err)
+		}
+	}
+	if v, _ := sh.lookupVar("i"); v != "16" {
+		t.Fatalf("short-circuit branch assigned i = %s", v)
+	}
+
+	for _, expr := range []string{"1 / 0", "1 +", "(1", "2 $ 3", "1 2"} {
+		if _, err := sh.evalArith(expr); err == nil {
+			t.Errorf("%s: expected error", expr)
+		}
+	}
+
+	if out, _ := runScript(t, sh, "echo $((i - 6)) \"$((2*3))\"\necho $((1/0)) || echo failed"); out != "10 6\nfailed\n" {
+		t.Fatalf("unexpected output: %q", out)
+	}
+}
+
+func TestGlob(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"b.go", "a.go", "c.txt", ".hidden.go", "sub/x.go", "sub/deep/y.go"} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sh := newShell(t, dir)
+
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"echo *.go", "a.go b.go"},
+		{"echo ?.*", "a.go b.go c.txt"},
+		{"echo [ab].go [!ab].*", "a.go b.go c.txt"},
+		{"echo '*.go' \\*.go \"*\".go", "*.go *.go *.go"},
+		{"echo */", "sub/"},
+		{"echo sub/*/*.go", "sub/deep/y.go"},
+		{"echo *.none", "*.none"},
+		{"v='*.txt'; echo $v \"$v\"", "c.txt *.txt"},
+		{"echo .*.go", ".hidden.go"},
+		{"echo **/*.go", "sub/x.go"},
+		{"shopt -s globstar; echo **/*.go", "a.go b.go sub/deep/y.go sub/x.go"},
+		{"shopt -s nullglob; echo x *.none y", "x y"},
+		{"shopt -s dotglob; echo *.go", ".hidden.go a.go b.go"},
+	}
+	for _, tt := range tests {
+		sh.shellOpts["globstar"], sh.shellOpts["nullglob"], sh.shellOpts["dotglob"] = false, false, false
+		if out, _ := runScript(t, sh, tt.src); out != tt.want+"\n" {
+			t.Errorf("%s: expected %q, got %q", tt.src, tt.want, out)
+		}
+	}
+
+	if out, code := runScript(t, sh, "shopt -q nullglob || echo off; shopt -s bogus"); out != "off\n" || code != 1 {
+		t.Fatalf("unexpected shopt result: %q, %d", out, code)
+	}
+}
+
+func TestBraceExpand(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want []string
+	}{
+		{"a{b,c}d", []string{"abd", "acd"}},
+		{"{a,b}{1,2}", []string{"a1", "a2", "b1", "b2"}},
+		{"a{b,{c,d}}e", []string{"abe", "ace", "ade"}},
+		{"{1..4}", []string{"1", "2", "3", "4"}},
+		{"{3..1}", []string{"3", "2", "1"}},
+		{"{01..10..3}", []string{"01", "04", "07", "10"}},
+		{"{a..e..2}", []string{"a", "c", "e"}},
+		{"x{,y}", []string{"x", "xy"}},
+		{"{x}", []string{"{x}"}},
+		{"{a..}", []string{"{a..}"}},
+		{"'{a,b}'", []string{"'{a,b}'"}},
+		{`\{a,b}`, []string{`\{a,b}`}},
+		{"${x:-{a,b}}", []string{"${x:-{a,b}}"}},
+	}
+	for _, tt := range tests {
+		if got := braceExpand(tt.raw); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: expected %q, got %q", tt.raw, tt.want, got)
+		}
+	}
+}
+
+func TestTildeExpansion(t *testing.T) {
+	sh := newShell(t, "")
+	sh.setVar("HOME", "/home/test")
+
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"echo ~ ~/bin", "/home/test /home/test/bin"},
+		{`echo "~" '~' \~ a~`, "~ ~ ~ a~"},
+		{"echo ~no_such_user_msh", "~no_such_user_msh"},
+		{"P=~/a:~/b; echo $P", "/home/test/a:/home/test/b"},
+		{"echo x=~/a:~/b", "x=~/a:~/b"},
+	}
+	for _, tt := range tests {
+		if out, _ := runScript(t, sh, tt.src); out != tt.want+"\n" {
+			t.Errorf("%s: expected %q, got %q", tt.src, tt.want, out)
+		}
+	}
+
+	if u, err := user.Lookup("root"); err == nil {
+		if out, _ := runScript(t, sh, "echo ~root/x"); out != u.HomeDir+"/x\n" {
+			t.Fatalf("unexpected ~root: %q", out)
+		}
+	}
+}
+
+func TestHistoryExpansion(t *testing.T) {
+	h := &history{}
+	for _, line := range []string{"echo one", "ls -l", "ls -l", "", "echo two"} {
+		h.add(line)
+	}
+	if !reflect.DeepEqual(h.lines, []string{"echo one", "ls -l", "echo two"}) {
+		t.Fatalf("unexpected history: %q", h.lines)
+	}
+
+	tests := []struct {
+		line string
+		want string
+	}{
+		{"!!", "echo two"},
+		{"!! | wc", "echo two | wc"},
+		{"!1 x", "echo one x"},
+		{"!-2", "ls -l"},
+		{"!ec;", "echo two;"},
+		{"echo '!!' \\!! a!= \"b!\"", "echo '!!' \\!! a!= \"b!\""},
+		{"echo \"!!\"", "echo \"echo two\""},
+	}
+	for _, tt := range tests {
+		if got, err := h.expand(tt.line); err != nil || got != tt.want {
+			t.Errorf("%s: expected %q, got %q (%v)", tt.line, tt.want, got, err)
+		}
+	}
+	for _, line := range []string{"!9", "!-5", "!nope"} {
+		if _, err := h.expand(line); !errors.Is(err, errNoEvent) {
+			t.Errorf("%s: expected event not found, got %v", line, err)
+		}
+	}
+
+	sh := newShell(t, "")
+	sh.history = h
+	var out bytes.Buffer
+	if _, err := sh.builtinHistory([]string{"2"}, &out); err != nil || out.String() != "    2  ls -l\n    3  echo two\n" {
+		t.Fatalf("unexpected history output: %q (%v)", out.String(), err)
+	}
+
+	// многострочная команда — одна запись, !! в её продолжении — предыдущая команда
+	in := "echo \"unterminated\nend\" !!\n"
+	c := &commandReader{sh: sh, r: &fileLines{bufio.NewReader(strings.NewReader(in))}, hist: h}
+	if _, err := c.next(); err != nil {
+		t.Fatal(err)
+	}
+	if got := h.lines[len(h.lines)-1]; got != "echo \"unterminated\nend\" echo two" {
+		t.Fatalf("unexpected multi-line history entry: %q", got)
+	}
+}
+
+func TestHistoryFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hist")
+	h := loadHistory(path)
+	h.add("echo a\n")
+	h.add("echo b\n")
+	h.add("echo \"unterminated\nend\"\n")
+	h.add(`printf 'a\nb\\'` + "\n")
+
+	want := []string{"echo a", "echo b", "echo \"unterminated\nend\"", `printf 'a\nb\\'`}
+	if h := loadHistory(path); !reflect.DeepEqual(h.lines, want) {
+		t.Fatalf("unexpected loaded history: %q", h.lines)
+	}
+	h.clear()
+	if h := loadHistory(path); len(h.lines) != 0 {
+		t.Fatalf("history not cleared: %q", h.lines)
+	}
+}
+
+// editLine — строка из редактора после нажатия клавиш keys; sh дополняет по Tab
+func editLine(t *testing.T, sh *Interpreter, keys string, hist *history) (string, error) {
+	t.Helper()
+	e := &lineEditor{in: strings.NewReader(keys), out: io.Discard, fd: -1, hist: hist, sh: sh}
+	return e.readLine("$ ")
+}
+
+func TestLineEditor(t *testing.T) {
+	hist := &history{lines: []string{"echo first", "ls -la", "echo last"}}
+
+	tests := []struct {
+		keys string
+		want string
+	}{
+		{"helo\x1b[D\x1b[Dl\r", "hello"},
+		{"world\x01hello \x05!\r", "hello world!"},
+		{"abc def\x17xyz\r", "abc xyz"},
+		{"abc def\x1b[D\x1b[D\x0b\r", "abc d"},
+		{"abc def\x02\x02\x15\r", "ef"},
+		{"abc\x7f\x7fx\x01\x04\r", "x"},
+		{"abc\x1b[H\x1b[3~\x1b[F!\r", "bc!"},
+		{"one two\x1bb\x1bb_\x1bf\x1bf.\r", "_one two."},
+		{"\x1b[A\r", "echo last"},
+		{"\x10\x10\x10\x10\x0e\r", "ls -la"},
+		{"typed\x1b[A\x1b[B\r", "typed"},
+		{"\x12echo\r", "echo last"},
+		{"\x12echo\x12\x05!\r", "echo first!"},
+		{"x\x12ls\x07\r", "x"},
+		{"привет\x1b[D\x7f\r", "привет"[:8] + "т"},
+	}
+	for _, tt := range tests {
+		got, err := editLine(t, nil, tt.keys, hist)
+		if err != nil || got != tt.want+"\n" {
+			t.Errorf("%q: expected %q, got %q (%v)", tt.keys, tt.want, got, err)
+		}
+	}
+
+	if _, err := editLine(t, nil, "abc\x03", hist); !errors.Is(err, errInterrupted) {
+		t.Fatalf("expected interrupt, got %v", err)
+	}
+	if _, err := editLine(t, nil, "\x04", hist); !errors.Is(err, io.EOF) {
+		t.Fatalf("expected EOF, got %v", err)
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	tests := []struct {
+		line    string
+		start   int
+		word    string
+		command bool
+	}{
+		{"", 0, "", true},
+		{"ec", 0, "ec", true},
+		{"echo fi", 5, "fi", false},
+		{"ls | gr", 5, "gr", true},
+		{"if tr", 3, "tr", true},
+		{"X=1 ca", 4, "ca", true},
+		{`cat my\ fi`, 4, "my fi", false},
+		{`cat "a b`, 4, "a b", false},
+		{"echo x >ou", 8, "ou", false},
+		{"echo x ", 7, "", false},
+	}
+	for _, tt := range tests {
+		start, word, command := completionWord(tt.line)
+		if start != tt.start || word != tt.word || command != tt.command {
+			t.Errorf("%q: expected %d %q %v, got %d %q %v", tt.line, tt.start, tt.word, tt.command, start, word, command)
+		}
+	}
+
+	dir := t.TempDir()
+	for _, name := range []string{"alpha.txt", "alpine.txt", "my file", ".hidden"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "run.sh"), nil, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "alps"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	sh := newShell(t, dir)
+	if got := sh.completions("al", false); !reflect.DeepEqual(got, []string{"alpha.txt", "alpine.txt", "alps/"}) {
+		t.Fatalf("unexpected file completions: %q", got)
+	}
+	if got := sh.completions("./", true); !reflect.DeepEqual(got, []string{"./alps/", "./run.sh"}) {
+		t.Fatalf("unexpected command path completions: %q", got)
+	}
+	if got := sh.completions("his", true); !slices.Contains(got, "history") {
+		t.Fatalf("builtin missing from completions: %q", got)
+	}
+
+	for _, tt := range []struct{ keys, want string }{
+		{"cat alph\t\r", "cat alpha.txt "},
+		{"cat alp\t\r", "cat alp"},
+		{"cat my\t\r", `cat my\ file `},
+		{"cd al\ts\t\r", "cd alps/"},
+	} {
+		if got, err := editLine(t, sh, tt.keys, nil); err != nil || got != tt.want+"\n" {
+			t.Errorf("%q: expected %q, got %q (%v)", tt.keys, tt.want, got, err)
+		}
+	}
+}
+
+func TestPromptEscapes(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "repo", ".git"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "repo", "src"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "repo", ".git", "HEAD"), []byte("ref: refs/heads/main\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	sh := newShell(t, filepath.Join(dir, "repo", "src"))
+	sh.setVar("HOME", dir)
+	sh.lastStatus = 3
+
+	sign := "$"
+	if os.Geteuid() == 0 {
+		sign = "#"
+	}
+	tests := []struct {
+		ps   string
+		want string
+	}{
+		{`\w\$ `, "~/repo/src" + sign + " "},
+		{`\W (\g) \?> `, "src (main) 3> "},
+		{`\[\e[1m\]x\\y\n`, "\x1b[1mx\\y\n"},
+		{`\q`, `\q`},
+	}
+	for _, tt := range tests {
+		if got := sh.expandPromptEscapes(tt.ps); got != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.ps, tt.want, got)
+		}
+	}
+
+	sh.dir = dir
+	if got := sh.expandPromptEscapes(`\w|\W|\g`); got != "~|~|" {
+		t.Fatalf("unexpected prompt at home: %q", got)
+	}
+
+	sh.setVar("PS1", `$PROMPT_VAR$(echo sub)\$`)
+	sh.setVar("PROMPT_VAR", "v:")
+	if got := sh.promptString("PS1"); got != "v:sub"+sign {
+		t.Fatalf("unexpected expanded PS1: %q", got)
+	}
+
+	// имя каталога показывается как есть, подстановка в нём не выполняется
+	marker := filepath.Join(dir, "pwned")
+	evil := filepath.Join(dir, "$(touch "+marker+")`touch "+marker+"`\\")
+	if err := os.MkdirAll(evil, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	sh.dir = evil
+	sh.setVar("PS1", `\W|\w`)
+	want := filepath.Base(evil) + "|~" + strings.TrimPrefix(evil, dir)
+	if got := sh.promptString("PS1"); got != want {
+		t.Errorf("unexpected prompt in %q: %q", evil, got)
+	}
+	if _, err := os.Stat(marker); err == nil {
+		t.Fatal("directory name was executed by the prompt")
+	}
+}
+
+func TestSource(t *testing.T) {
+	dir := t.TempDir()
+	lib := filepath.Join(dir, "lib.sh")
+	src := "libvar=set\nlibfn() { echo fn \"$@\"; }\necho args $# $1\nreturn 4\necho unreachable\n"
+	if err := os.WriteFile(lib, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	sh := newShell(t, "")
+
+	out, code := runScript(t, sh, "set -- outer; . "+lib+" a b; echo $? $libvar $1; libfn x; source "+lib+"; echo $?")
+	want := "args 2 a\n4 set outer\nfn x\nargs 1 outer\n4\n"
+	if out != want || code != 0 {
+		t.Fatalf("expected %q, got %q (code %d)", want, out, code)
+	}
+
+	sh.setVar("PATH", dir)
+	if out, _ := runScript(t, sh, "source lib.sh p; echo $?"); out != "args 1 p\n4\n" {
+		t.Fatalf("PATH lookup failed: %q", out)
+	}
+	if _, code := runScript(t, sh, "source "+filepath.Join(dir, "missing")); code != 1 {
+		t.Fatalf("expected 1 for missing file, got %d", code)
+	}
+}
+
+// builtin в фоне работает с копией shell: главный цикл тем временем меняет переменные, алиасы и функции
+// (гонку ловит go test -race)
+func TestBackgroundBuiltins(t *testing.T) {
+	sh := newShell(t, "")
+	src := "for i in 1 2 3 4; do type -a ls | cat >/dev/null & [ -n \"$X\" ] | true & done; " +
+		"for i in $(seq 300); do X=$i; alias a$i=ls; f() { :; }; done; wait; echo $X; type a300 | cat"
+	if out, code := runScript(t, sh, src); out != "300\na300 is aliased to `ls'\n" || code != 0 {
+		t.Fatalf("unexpected output %q (code %d)", out, code)
+	}
+}
+
+func TestPipelineStatus(t *testing.T) {
+	sh := newShell(t, "")
+
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"true | false; echo $? ${PIPESTATUS[@]}", "1 0 1"},
+		{"false | true; echo $? $PIPESTATUS ${#PIPESTATUS[@]}", "0 1 0 2"},
+		{"set -o pipefail; false | true | true; echo $? ${PIPESTATUS[0]} ${PIPESTATUS[-1]}", "1 1 0"},
+		{"set -o pipefail; sh -c 'exit 3' | sh -c 'exit 4' | true; echo $?", "4"},
+		{"echo x | false; echo $?", "1"},
+		{"echo >/dev/null | false | echo ok; echo ${PIPESTATUS[*]}", "ok\n0 1 0"},
+		{"echo x | no_such_cmd_msh 2>/dev/null | cat; echo $? ${PIPESTATUS[@]}", "0 0 127 0"},
+		{"no_such_cmd_msh 2>/dev/null; echo $?", "127"},
+		{"/ 2>/dev/null; echo $?", "126"},
+		{"sh -c 'kill -TERM $$'; echo $?", "143"},
+		{"! true; echo $?; ! false | false; echo $?", "1\n0"},
+		{"! true || echo negated; ! false && echo ok", "negated\nok"},
+		{"false; echo ${PIPESTATUS[0]} ${PIPESTATUS[5]:-none}", "1 none"},
+	}
+	for _, tt := range tests {
+		sh.setOptions["pipefail"] = false
+		if out, _ := runScript(t, sh, tt.src); out != tt.want+"\n" {
+			t.Errorf("%s: expected %q, got %q", tt.src, tt.want, out)
+		}
+	}
+
+	sh.setOptions["pipefail"] = false
+	out, _ := runScript(t, sh, "set a b; set -o pipefail; echo $#; set -o; set +o pipefail; set +o; set -o bogus")
+	want := "2\nerrexit        \toff\nnoexec         \toff\nnounset        \toff\npipefail       \ton\nxtrace         \toff\n" +
+		"set +o errexit\nset +o noexec\nset +o nounset\nset +o pipefail\nset +o xtrace\n"
+	if out != want {
+		t.Fatalf("expected %q, got %q", want, out)
+	}
+}
+
+func TestPipelineBuiltinIsolation(t *testing.T) {
+	sh := newShell(t, t.TempDir())
+
+	src := "cd / | cat; pwd | grep -c '^/$'\n" +
+		"exit | cat; echo alive\n" +
+		"export PIPE_EXPORTED=1 | cat; echo ${PIPE_EXPORTED-unset}\n" +
+		"set --; set -- a b | cat; echo $#\n" +
+		"shopt -s dotglob | cat; shopt -q dotglob || echo off\n" +
+		"cd / & sleep 0.1; pwd | grep -c '^/$'\n" +
+		"echo piped | cat\n"
+	out, code := runScript(t, sh, src)
+	want := "0\nalive\nunset\n0\noff\n0\npiped\n"
+	if out != want || code != 0 {
+		t.Fatalf("expected %q, got %q (code %d)", want, out, code)
+	}
+}
+
+func TestAlias(t *testing.T) {
+	sh := newShell(t, "")
+
+	src := "alias ll='echo LL ' hi='echo hi' loop='loop2' loop2='loop'\n" +
+		"ll hi there\n" +
+		"X=1 hi x; alias hi\n" +
+		"f() { echo func; }; alias f=\"echo alias\"\n" +
+		"f; \\f; 'f'\n" +
+		"loop 2>/dev/null; echo $?\n" +
+		"unalias hi\nhi 2>/dev/null; echo $?; unalias hi; echo $?\n" +
+		"(ll sub)\n"
+	out, _ := runScript(t, sh, src)
+	want := "LL echo hi there\nhi x\nalias hi='echo hi'\nalias\nfunc\nfunc\n127\n127\n1\nLL sub\n"
+	if out != want {
+		t.Fatalf("expected %q, got %q", want, out)
+	}
+
+	out, _ = runScript(t, sh, "unalias -a; alias q=\"it's\"; alias")
+	if out != "alias q='it'\\''s'\n" {
+		t.Fatalf("unexpected alias listing: %q", out)
+	}
+}
+
+func TestTestBuiltin(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file")
+	if err := os.WriteFile(file, []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		args []string
+		want int
+	}{
+		{nil, 1},
+		{[]string{""}, 1},
+		{[]string{"x"}, 0},
+		{[]string{"-f", file}, 0},
+		{[]string{"-d", file}, 1},
+		{[]string{"-d", dir}, 0},
+		{[]string{"-s", file}, 0},
+		{[]string{"-x", file}, 1},
+		{[]string{"-e", filepath.Join(dir, "none")}, 1},
+		{[]string{"-z", ""}, 0},
+		{[]string{"-n", ""}, 1},
+		{[]string{"a", "=", "a"}, 0},
+		{[]string{"a", "!=", "a"}, 1},
+		{[]string{"abc", "<", "abd"}, 0},
+		{[]string{" 10", "-gt", "9"}, 0},
+		{[]string{"2", "-le", "1"}, 1},
+		{[]string{"!", "-f", file}, 1},
+		{[]string{"-f", "=", "-f"}, 0},
+		{[]string{"!", "=", "!"}, 0},
+		{[]string{"a", "-a", "", "-o", "b"}, 0},
+		{[]string{"(", "a", "=", "b", ")", "-o", "-d", dir}, 0},
+		{[]string{"x", "-eq", "1"}, 2},
+		{[]string{"a", "b"}, 2},
+		{[]string{"(", "a"}, 2},
+	}
+	sh := newShell(t, "")
+	for _, tt := range tests {
+		if code, _ := sh.builtinTest("test", tt.args); code != tt.want {
+			t.Errorf("test %q: expected %d, got %d", tt.args, tt.want, code)
+		}
+	}
+
+	if code, err := sh.builtinTest("[", []string{"a"}); code != 2 || err == nil {
+		t.Fatalf("[ without ]: expected 2, got %d, %v", code, err)
+	}
+	out, _ := runScript(t, sh, "[ -d "+dir+" ] && echo dir; [ 1 -ne 1 ] || echo ne")
+	if out != "dir\nne\n" {
+		t.Fatalf("unexpected output: %q", out)
+	}
+}
+
+func TestPrintfAndEcho(t *testing.T) {
+	sh := newShell(t, "")
+
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{`printf '%s-%d|%5.2f|%x|%-4s|%c\n' a 42 3.14159 255 ab xyz`, "a-42| 3.14|ff|ab  |x\n"},
+		{`printf '%s\n' a b c`, "a\nb\nc\n"},
+		{`printf '%s=%s;' a 1 b`, "a=1;b=;"},
+		{`printf '%d %o %X %u\n' "'A" 8 0x1f -1`, "65 10 1F 18446744073709551615\n"},
+		{`printf '%*d|%.*s\n' 4 7 2 abcdef`, "   7|ab\n"},
+		{`printf 'a\tb\101\\%%\n'`, "a\tbA\\%\n"},
+		{`printf '%b|%q\n' 'x\ty' "it's"`, "x\ty|'it'\\''s'\n"},
+		{`printf '%b' 'stop\cnot'; printf 'x\cy'; echo`, "stopx\n"},
+		{`printf -v PV '%03d' 7; echo $PV`, "007\n"},
+		{`printf '%d\n' abc 2>/dev/null; echo $?`, "0\n1\n"},
+		{`echo -n a; echo -e 'b\tc\0101'; echo -E 'd\te'; echo -x -n`, "ab\tcA\nd\\te\n-x -n\n"},
+		{`echo -e 'one\ctwo'; echo`, "one\n"},
+	}
+	for _, tt := range tests {
+		if out, _ := runScript(t, sh, tt.src); out != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.src, tt.want, out)
+		}
+	}
+}
+
+func TestBuiltinRead(t *testing.T) {
+	sh := newShell(t, "")
+
+	tests := []struct {
+		args  []string
+		input string
+		code  int
+		want  map[string]string
+	}{
+		{[]string{"a", "b"}, "  one  two three  \nnext", 0, map[string]string{"a": "one", "b": "two three"}},
+		{[]string{"a", "b", "c"}, "x\n", 0, map[string]string{"a": "x", "b": "", "c": ""}},
+		{nil, "  keep  \n", 0, map[string]string{"REPLY": "  keep  "}},
+		{[]string{"a"}, `a\ b\` + "\nc\n", 0, map[string]string{"a": "a bc"}},
+		{[]string{"-r", "a"}, `a\ b` + "\n", 0, map[string]string{"a": `a\ b`}},
+		{[]string{"-d", ",", "a"}, "x y,z", 0, map[string]string{"a": "x y"}},
+		{[]string{"-n", "3", "a"}, "привет\n", 0, map[string]string{"a": "при"}},
+		{[]string{"a"}, "partial", 1, map[string]string{"a": "partial"}},
+	}
+	for _, tt := range tests {
+		in := strings.NewReader(tt.input)
+		code, err := sh.builtinRead(tt.args, in)
+		if err != nil || code != tt.code {
+			t.Errorf("read %q: code %d, %v", tt.args, code, err)
+		}
+		for name, want := range tt.want {
+			if v, _ := sh.lookupVar(name); v != want {
+				t.Errorf("read %q: %s = %q, want %q", tt.args, name, v, want)
+			}
+		}
+	}
+
+	// остаток ввода не съеден
+	in := strings.NewReader("first\nsecond\n")
+	sh.builtinRead([]string{"a"}, in)
+	sh.builtinRead([]string{"b"}, in)
+	if a, _ := sh.lookupVar("a"); a != "first" {
+		t.Fatalf("a = %q", a)
+	}
+	if b, _ := sh.lookupVar("b"); b != "second" {
+		t.Fatalf("b = %q", b)
+	}
+
+	out, _ := runScript(t, sh, "IFS=: read -r a b c <<EOF\nx:y::z\nEOF\necho \"$a|$b|$c\"; echo q | read a; echo $a")
+	if out != "x|y|:z\nx\n" {
+		t.Fatalf("unexpected output: %q", out)
+	}
+}
+
+func TestTypeCommandWhich(t *testing.T) {
+	sh := newShell(t, "")
+	path := sh.pathLookup("sh", false)
+	if len(path) == 0 {
+		t.Skip("sh not in PATH")
+	}
+
+	src := "alias ta='echo a'; tf() { echo fn; }\n" +
+		"type ta if tf cd sh; type -t ta tf cd sh; type -p sh cd\n" +
+		"type no_such_cmd_msh 2>/dev/null; echo $?\n" +
+		"command -v cd tf sh ta no_such_cmd_msh; echo $?\n" +
+		"command -V cd\n" +
+		"tf() { echo fn; }; echo() { printf 'wrapped\\n'; }; command echo plain; unset -f echo\n" +
+		"which sh; which cd no_such_cmd_msh; echo $?\n"
+	out, _ := runScript(t, sh, src)
+	want := "ta is aliased to `echo a'\nif is a shell keyword\ntf is a function\ncd is a shell builtin\nsh is " + path[0] + "\n" +
+		"alias\nfunction\nbuiltin\nfile\n" + path[0] + "\n" +
+		"1\n" +
+		"cd\ntf\n" + path[0] + "\nalias ta='echo a'\n1\n" +
+		"cd is a shell builtin\n" +
+		"plain\n" +
+		path[0] + "\n1\n"
+	if out != want {
+		t.Fatalf("expected %q, got %q", want, out)
+	}
+}
+
+func TestCdExitUmask(t *testing.T) {
+	dir := t.TempDir()
+	sh := newShell(t, dir)
+	old := syscall.Umask(0o022)
+	defer syscall.Umask(old)
+
+	out, _ := runScript(t, sh, "unset OLDPWD; cd - 2>/dev/null; echo $?; cd /; cd -; echo $OLDPWD; cd - >/dev/null; echo $PWD")
+	want := "1\n" + dir + "\n/\n/\n"
+	if out != want {
+		t.Fatalf("expected %q, got %q", want, out)
+	}
+
+	out, _ = runScript(t, sh, "(exit 3); echo $?; (exit 257); echo $?; (exit x) 2>/dev/null; echo $?; false; (exit); echo $?; true; false | exit; echo $?")
+	if out != "3\n1\n2\n1\n0\n" {
+		t.Fatalf("unexpected exit codes: %q", out)
+	}
+
+	out, _ = runScript(t, sh, "umask; umask -S; umask 027; umask; umask g+w,o=r; umask; umask 999; echo $?; umask u=rwx,g-x; umask -S")
+	want = "0022\nu=rwx,g=rx,o=rx\n0027\n0003\n1\nu=rwx,g=rw,o=r\n"
+	if out != want {
+		t.Fatalf("expected %q, got %q", want, out)
+	}
+
+	out, _ = runScript(t, sh, "true; echo $?; false; echo $?; :; echo $?")
+	if out != "0\n1\n0\n" {
+		t.Fatalf("unexpected output: %q", out)
+	}
+}
+
+func TestBuiltinPs(t *testing.T) {
+	pid := strconv.Itoa(os.Getpid())
+
+	var out bytes.Buffer
+	if code, err := builtinPs([]string{"-p", pid, "-o", "pid="}, &out); code != 0 || err != nil {
+		t.Fatalf("ps -p: code %d, %v", code, err)
+	}
+	if f := strings.Fields(out.String()); len(f) != 1 || f[0] != pid {
+		t.Fatalf("expected only pid, got %q", out.String())
+	}
+
+	out.Reset()
+	builtinPs([]string{"-f", "-p" + pid}, &out)
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "UID          PID    PPID  C STIME TTY          TIME CMD") {
+		t.Fatalf("unexpected ps -f output: %q", out.String())
+	}
+	if f := strings.Fields(lines[1]); f[1] != pid || f[2] != strconv.Itoa(os.Getppid()) || f[7] != os.Args[0] {
+		t.Fatalf("unexpected ps -f row: %q", lines[1])
+	}
+
+	// дочерний процесс в дереве под shell
+	out.Reset()
+	src := "sleep 1 & ps -e --forest -o pid,ppid,args | grep -F 'sleep 1' | grep -v grep; kill %sleep"
+	sh := newShell(t, "")
+	outStr, _ := runScript(t, sh, src)
+	if !strings.Contains(outStr, `\_ sleep 1`) || !strings.Contains(outStr, " "+pid+" ") {
+		t.Fatalf("sleep not shown as a child: %q", outStr)
+	}
+	for _, j := range sh.jobs.snapshot() {
+		waitJob(j)
+	}
+	sh.notifyJobs(io.Discard)
+
+	for _, args := range [][]string{{"-o", "bogus"}, {"-x"}, {"-p"}, {"-p", "x"}} {
+		if code, err := builtinPs(args, &out); code != 1 || err == nil {
+			t.Errorf("ps %q: expected error, got %d", args, code)
+		}
+	}
+	if code, _ := builtinPs([]string{"-p", "999999999"}, &out); code != 1 {
+		t.Error("ps -p for a missing pid should fail")
+	}
+
+	if got := splitPsFormat("pid,ppid comm=Command name, x"); !reflect.DeepEqual(got, []string{"pid", "ppid", "comm=Command name, x"}) {
+		t.Errorf("unexpected format split: %q", got)
+	}
+	for dev, want := range map[int]string{0: "?", 136<<8 | 3: "pts/3", 4<<8 | 1: "tty1", 4<<8 | 65: "ttyS1"} {
+		if got := ttyName(dev); got != want {
+			t.Errorf("ttyName(%#x) = %q, want %q", dev, got, want)
+		}
+	}
+	if got := cpuTime(90061); got != "1-01:01:01" {
+		t.Errorf("cpuTime: %q", got)
+	}
+	if got := elapsedTime(3725); got != "01:02:05" {
+		t.Errorf("elapsedTime: %q", got)
+	}
+}
+
+func TestBuiltinKill(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"kill -l 143 TERM sigkill 9", "TERM\n15\n9\nKILL\n"},
+		{"kill -l | head -1", " 1) SIGHUP    \t 2) SIGINT    \t 3) SIGQUIT   \t 4) SIGILL    \t 5) SIGTRAP   \n"},
+		{"sleep 5 & kill -s KILL $!; echo $?", "0\n"},
+		{"sleep 5 & kill -TERM %%; echo $?", "0\n"},
+		{"kill -9 2>/dev/null; echo $?; kill %99 2>/dev/null; echo $?", "2\n1\n"},
+		{"kill -FOO 1 2>/dev/null; echo $?; kill abc 2>/dev/null; echo $?", "1\n1\n"},
+	}
+	sh := newShell(t, "")
+	for _, tt := range tests {
+		if out, _ := runScript(t, sh, tt.src); out != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.src, tt.want, out)
+		}
+	}
+	for _, j := range sh.jobs.snapshot() {
+		waitJob(j)
+		if code := j.exitCode(false); code != 128+int(syscall.SIGKILL) && code != 128+int(syscall.SIGTERM) {
+			t.Errorf("%s: unexpected status %d", j.Cmd, code)
+		}
+	}
+	sh.notifyJobs(io.Discard)
+
+	for _, spec := range []string{"9", "KILL", "SIGKILL", "kill"} {
+		if sig, err := parseSignal(spec); err != nil || sig != syscall.SIGKILL {
+			t.Errorf("parseSignal(%q) = %v, %v", spec, sig, err)
+		}
+	}
+}
+
+func TestInterpreterRun(t *testing.T) {
+	t.Parallel()
+	sh := newShell(t, t.TempDir())
+	var out, errOut bytes.Buffer
+	sh.Stdin, sh.Stdout, sh.Stderr = strings.NewReader("input\n"), &out, &errOut
+
+	ctx := context.Background()
+	if code, err := sh.Run(ctx, "X=1; f() { echo f$X; }; cat; no_such_cmd_msh; exit 3; echo unreachable"); code != 3 || err != nil {
+		t.Fatalf("exit: code %d, %v", code, err)
+	}
+	// состояние сохраняется до следующего Run
+	if code, err := sh.Run(ctx, "f; cd /; ! exit 5"); code != 5 || err != nil {
+		t.Fatalf("second run: code %d, %v", code, err)
+	}
+	if out.String() != "input\nf1\n" || !strings.Contains(errOut.String(), "no_such_cmd_msh") || sh.Dir() != "/" {
+		t.Fatalf("unexpected output %q, errors %q, dir %s", out.String(), errOut.String(), sh.Dir())
+	}
+	if v, ok := sh.LookupVar("X"); !ok || v != "1" {
+		t.Fatalf("X = %q, %v", v, ok)
+	}
+
+	if code, err := sh.Run(ctx, "echo a; fi"); code != 2 || err == nil {
+		t.Fatalf("syntax error: code %d, %v", code, err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := sh.Run(ctx, "sleep 5; echo after"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline error, got %v", err)
+	}
+	if time.Since(start) > 2*time.Second || strings.Contains(out.String(), "after") {
+		t.Fatalf("cancelled Run was not stopped: %q", out.String())
+	}
+}
+
+func TestInterpreterIsolation(t *testing.T) {
+	t.Parallel()
+	cwd, _ := os.Getwd()
+	dirs := []string{t.TempDir(), t.TempDir()}
+
+	var wg sync.WaitGroup
+	outs := make([]bytes.Buffer, len(dirs))
+	for i, dir := range dirs {
+		sh, err := NewInterpreter(dir, []string{"PATH=" + os.Getenv("PATH"), "V=" + strconv.Itoa(i)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		sh.Stdout = &outs[i]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _ = sh.Run(context.Background(), "touch f$V; echo $V $PWD; sh -c 'echo $V; pwd'; L=local; sh -c 'echo ${L-unset}'; ls")
+		}()
+	}
+	wg.Wait()
+
+	for i, dir := range dirs {
+		v := strconv.Itoa(i)
+		if want := v + " " + dir + "\n" + v + "\n" + dir + "\nunset\nf" + v + "\n"; outs[i].String() != want {
+			t.Errorf("interpreter %d: expected %q, got %q", i, want, outs[i].String())
+		}
+	}
+	if now, _ := os.Getwd(); now != cwd {
+		t.Fatalf("process directory changed to %s", now)
+	}
+	if _, err := NewInterpreter(filepath.Join(dirs[0], "f0"), nil); err == nil {
+		t.Fatal("expected error for a file as directory")
+	}
+}
+
+func TestInterpreterRegister(t *testing.T) {
+	t.Parallel()
+	sh := newShell(t, "")
+	var out, errOut bytes.Buffer
+	sh.Stdout, sh.Stderr = &out, &errOut
+
+	sh.Register("upper", func(sh *Interpreter, args []string, in io.Reader, out io.Writer) (int, error) {
+		if len(args) > 0 {
+			return 1, fmt.Errorf("upper: %s: unexpected argument", args[0])
+		}
+		data, err := io.ReadAll(in)
+		if err != nil {
+			return 1, err
+		}
+		sh.SetVar("UPPER_SEEN", "1")
+		io.WriteString(out, strings.ToUpper(string(data)))
+		return 0, nil
+	})
+
+	src := "echo abc | upper | cat; echo ${UPPER_SEEN-unset}\n" +
+		"x=$(upper <<<low); echo $x ${UPPER_SEEN-unset}; (upper <<<sub); echo ${UPPER_SEEN-unset}\n" +
+		"echo q | (upper); echo $?\n" +
+		"upper <<<def; echo $UPPER_SEEN\n" +
+		"upper x; echo $?\n" +
+		"type upper; command -v upper\n" +
+		"upper() { echo func; }; upper\n"
+	if _, err := sh.Run(context.Background(), src); err != nil {
+		t.Fatal(err)
+	}
+	want := "ABC\nunset\nLOW unset\nSUB\nunset\n127\nDEF\n1\n1\nupper is a shell builtin\nupper\nfunc\n"
+	if out.String() != want || !strings.Contains(errOut.String(), "upper: x: unexpected argument") ||
+		!strings.Contains(errOut.String(), "upper: builtin added with Register is not available in a subshell process") {
+		t.Fatalf("expected %q, got %q (errors %q)", want, out.String(), errOut.String())
+	}
+	if got := sh.completions("upp", true); !slices.Contains(got, "upper") {
+		t.Fatalf("registered builtin missing from completions: %q", got)
+	}
+}
+
+func TestSetOptions(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+		code int
+	}{
+		{"{ set -x; x=1 y='a b' echo \"a b\" c; z=$(echo q 2>/dev/null); echo x | cat; for i in 1 2; do :; done; set +x; } 2>&1",
+			"+ x=1\n+ y='a b'\n+ echo 'a b' c\na b c\n+ z=q\n+ echo x\n+ cat\nx\n+ for i in 1 2\n+ :\n+ for i in 1 2\n+ :\n+ set +x\n", 0},
+		{"{ set -x; PS4='[$#] '; case a in a) echo $-;; esac; unset PS4; echo plain; } 2>&1",
+			"+ PS4='[$#] '\n[0] case a in\n[0] echo x\nx\n[0] unset PS4\necho plain\nplain\n", 0},
+		{"set -e; if false; then :; fi; while false; do :; done; false && true; ! true; false || true; { false && true; }; echo ok\n" +
+			"f() { false; echo in f; }; f || echo handled; (false); echo unreachable", "ok\nin f\n", 1},
+		{"set -e; x=$(false); echo unreachable", "", 1},
+		{"set -u; echo ${X-d} ${X:+s} $# $@; echo $X; echo unreachable", "d 0\n", 127},
+		{"set -u; for x in $NOPE; do echo $x; done; echo unreachable", "", 127},
+		{"echo a; set -n; echo never\necho never", "a\n", 0},
+		{"set -euo pipefail; echo $-; set +eu -o xtrace +o pipefail; echo $- 2>/dev/null; set -q; echo $?", "eu\nx\n2\n", 0},
+	}
+	for _, tt := range tests {
+		sh := newShell(t, "")
+		if out, code := runScript(t, sh, tt.src); out != tt.want || code != tt.code {
+			t.Errorf("%s: expected %q (code %d), got %q (code %d)", tt.src, tt.want, tt.code, out, code)
+		}
+	}
+
+	sh := newShell(t, "")
+	args, err := sh.parseShellFlags([]string{"-eu", "+e", "-o", "pipefail", "-c", "true", "-x"})
+	if err != nil || !reflect.DeepEqual(args, []string{"-c", "true", "-x"}) {
+		t.Fatalf("unexpected arguments %q (%v)", args, err)
+	}
+	if sh.setOptions["errexit"] || !sh.setOptions["nounset"] || !sh.setOptions["pipefail"] || sh.setOptions["xtrace"] {
+		t.Fatalf("unexpected options: %v", sh.setOptions)
+	}
+	for _, args := range [][]string{{"-z"}, {"-o"}, {"-o", "bogus"}} {
+		if _, err := sh.parseShellFlags(args); err == nil {
+			t.Errorf("%q: expected error", args)
+		}
+	}
+}
+
+func TestTraps(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+		code int
+	}{
+		{"trap 'echo bye $?' EXIT; trap \"echo it's\" INT; trap '' TERM; trap; trap -p INT; trap - INT TERM; trap INT; trap -p; false",
+			"trap -- 'echo bye $?' EXIT\ntrap -- 'echo it'\\''s' SIGINT\ntrap -- '' SIGTERM\ntrap -- 'echo it'\\''s' SIGINT\n" +
+				"trap -- 'echo bye $?' EXIT\nbye 1\n", 1},
+		{"trap 'exit 5' EXIT; exit 3", "", 5},
+		{"trap false EXIT; exit 3", "", 3},
+		{"trap 'echo err $?' ERR; f() { false; echo in f; }; f; false | true; true | false; if false; then :; fi; false || true; ! false; { false; }; x=$(false); (exit 4)",
+			"in f\nerr 1\nerr 1\nerr 1\nerr 4\n", 4},
+		{"set -e; trap 'echo err' ERR; false; echo unreachable", "err\n", 1},
+		{"trap x BOGUS", "", 1},
+		{"trap 'echo usr1 $?' USR1; (exit 3); kill -USR1 $$; echo $?", "usr1 0\n0\n", 0},
+		{"trap 'echo got TERM' TERM; kill -TERM $$", "got TERM\n", 0},
+		{"trap 'echo got USR1' USR1; kill -USR1 $$; echo a; echo b", "got USR1\na\nb\n", 0},
+		{"trap 'echo got TERM' TERM; kill -TERM $$; trap - TERM; echo end", "got TERM\nend\n", 0},
+		{"i=0; trap 'echo at $i' USR1; while [ $i -lt 1000 ]; do i=$((i+1)); [ $i = 3 ] && kill -USR1 $$; done", "at 3\n", 1},
+		{"sleep 0.1 & p=$!; (exit 3) & q=$!; wait $q; echo $?; sleep 0.2; wait $p $q; echo $?; wait 1; echo $?; wait %9; echo $?; wait; echo $?",
+			"3\n3\n127\n127\n0\n", 0},
+		{"sleep 5 & trap 'echo usr2' USR2; { sleep 0.2; kill -USR2 $$; } & wait %1; echo $?; kill %1; wait %1; echo $?",
+			"usr2\n140\n143\n", 0},
+		{"\"$0\" -c 'trap \"echo bye\" EXIT; kill $$; echo unreachable'; echo $?", "bye\n143\n", 0},
+		{"(trap 'echo sub' EXIT; echo in); echo out", "in\nsub\nout\n", 0},
+		{"trap '' TERM; (sh -c 'kill $$; echo ignored'); trap - TERM", "ignored\n", 0},
+		{"trap '' TERM; trap - TERM; sh -c 'kill $$'; echo $?", "143\n", 0},
+		{"trap '' TERM; trap 'echo term' TERM; trap - TERM; sh -c 'kill $$'; echo $?", "143\n", 0},
+	}
+	for _, tt := range tests {
+		sh := newShell(t, "")
+		if out, code := runScript(t, sh, tt.src); out != tt.want || code != tt.code {
+			t.Errorf("%s: expected %q (code %d), got %q (code %d)", tt.src, tt.want, tt.code, out, code)
+		}
+		if len(sh.traps) != 0 {
+			t.Errorf("%s: traps left after Run: %v", tt.src, sh.traps)
+		}
+	}
+
+	// ловушки одного Run не действуют в следующих: игнорирование снимается
+	sh := newShell(t, "")
+	runScript(t, sh, "trap '' TERM INT")
+	if out, _ := runScript(t, sh, "sh -c 'kill $$'; echo $?"); out != "143\n" {
+		t.Errorf("ignored TERM leaked into the next Run: %q", out)
+	}
+}
+
+func TestTime(t *testing.T) {
+	for src, want := range map[string]*PipelineNode{
+		"time -p ! a | b": {Time: true, TimePosix: true, Negate: true},
+		"time a":          {Time: true},
+		"time":            {Time: true},
+		"'time' a":        {},
+	} {
+		pipe := firstPipeline(t, parseLine(t, src))
+		if pipe.Time != want.Time || pipe.TimePosix != want.TimePosix || pipe.Negate != want.Negate {
+			t.Errorf("%q: unexpected pipeline %+v", src, pipe)
+		}
+	}
+
+	tests := []struct {
+		src  string
+		want string
+		code int
+	}{
+		{"TIMEFORMAT='[%0R %0lU %%]'; { time sleep 0 | cat; time ! false && time -p true; time false || echo no; } 2>&1",
+			"[0 0m0s %]\n[0 0m0s %]\nreal 0.00\nuser 0.00\nsys 0.00\n[0 0m0s %]\nno\n", 0},
+		{"TIMEFORMAT=; { time true; time; } 2>&1; type time", "time is a shell keyword\n", 0},
+		{"{ time echo x 2>/dev/null; } 2>&1 | grep -c -e '^real' -e '^user' -e '^sys' -e '^maxrss'", "4\n", 0},
+	}
+	for _, tt := range tests {
+		sh := newShell(t, "")
+		if out, code := runScript(t, sh, tt.src); out != tt.want || code != tt.code {
+			t.Errorf("%s: expected %q (code %d), got %q (code %d)", tt.src, tt.want, tt.code, out, code)
+		}
+	}
+
+	// время процессов конвейера берётся из wait4, память — наибольшая из стадий
+	sh := newShell(t, "")
+	out, _ := runScript(t, sh, "TIMEFORMAT='%3U %3S %M'; { time head -c 200000000 /dev/zero | cksum >/dev/null; } 2>&1")
+	var user, sys float64
+	var rss int64
+	if _, err := fmt.Sscanf(out, "%f %f %d", &user, &sys, &rss); err != nil || user+sys < 0.05 || rss <= 0 {
+		t.Errorf("unexpected times %q (%v)", out, err)
+	}
+
+	for _, tt := range []struct {
+		format string
+		want   string
+	}{
+		{"%R|%2lR|%1U|%S|%P|%M|%q|%", "75.250|1m15.25s|1.5|0.250|2.33|1024|%q|%"},
+		{"%5lS %9R", "0m0.250s 75.250"},
+	} {
+		got := formatTimes(tt.format, 75250*time.Millisecond, usage{user: 1500 * time.Millisecond, sys: 250 * time.Millisecond, maxRSS: 1024})
+		if got != tt.want {
+			t.Errorf("formatTimes(%q) = %q, want %q", tt.format, got, tt.want)
+		}
+	}
+}
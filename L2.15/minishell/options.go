@@ -0,0 +1,143 @@
+package minishell
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// setFlags — однобуквенные флаги set и minishell и настройки set -o, которые они меняют
+var setFlags = []struct {
+	flag byte
+	name string
+}{
+	{'e', "errexit"}, {'n', "noexec"}, {'u', "nounset"}, {'x', "xtrace"},
+}
+
+// setFlag — set -флаг или +флаг; false — флаг неизвестен
+func (sh *Interpreter) setFlag(flag byte, on bool) bool {
+	for _, f := range setFlags {
+		if f.flag == flag {
+			sh.setOptions[f.name] = on
+			return true
+		}
+	}
+	return false
+}
+
+// flagsParam — значение $-: включённые флаги, i — интерактивный shell
+func (sh *Interpreter) flagsParam() string {
+	var b strings.Builder
+	for _, f := range setFlags {
+		if sh.setOptions[f.name] {
+			b.WriteByte(f.flag)
+		}
+	}
+	if sh.interactive {
+		b.WriteByte('i')
+	}
+	return b.String()
+}
+
+// applyFlags — слово флагов set opt: -eux включает, +x выключает, o берёт имя настройки из args —
+// флаги можно совмещать: -euo pipefail. Возвращает остальные args; list — o без имени, настройки надо вывести
+func (sh *Interpreter) applyFlags(opt string, args []string) (rest []string, list bool, err error) {
+	on := opt[0] == '-'
+	for i := 1; i < len(opt); i++ {
+		if opt[i] != 'o' {
+			if !sh.setFlag(opt[i], on) {
+				return nil, false, fmt.Errorf("%c%c: invalid option", opt[0], opt[i])
+			}
+			continue
+		}
+		if len(args) == 0 {
+			return nil, true, nil
+		}
+		if _, ok := sh.setOptions[args[0]]; !ok {
+			return nil, false, fmt.Errorf("%s: invalid option name", args[0])
+		}
+		sh.setOptions[args[0]] = on
+		args = args[1:]
+	}
+	return args, false, nil
+}
+
+// parseShellFlags — флаги set в начале аргументов minishell до -c или скрипта: minishell -eux script.sh.
+// Возвращает остальные аргументы
+func (sh *Interpreter) parseShellFlags(args []string) ([]string, error) {
+	for len(args) > 0 && len(args[0]) > 1 && (args[0][0] == '-' || args[0][0] == '+') && args[0] != "-c" {
+		opt := args[0]
+		if opt == "--" {
+			return args[1:], nil
+		}
+		rest, list, err := sh.applyFlags(opt, args[1:])
+		if err != nil {
+			return nil, err
+		}
+		if list {
+			return nil, fmt.Errorf("%s: option requires an argument", opt)
+		}
+		args = rest
+	}
+	return args, nil
+}
+
+// trace — set -x: раскрытая команда в stderr shell (fds[2]) после PS4. Присваивания перед командой —
+// отдельными строками, как в bash; первый символ PS4 повторяется по уровню подстановки команды
+func (sh *Interpreter) trace(fds []*os.File, assigns, args []string) {
+	errOut := fdFile(fds, 2)
+	if !sh.setOptions["xtrace"] || errOut == nil {
+		return
+	}
+
+	prefix := ""
+	if ps4, ok := sh.lookupVar("PS4"); ok {
+		prefix = sh.expandWord(ps4)
+		if prefix != "" {
+			first, _ := utf8.DecodeRuneInString(prefix)
+			prefix = strings.Repeat(string(first), sh.traceLevel) + prefix
+		}
+	}
+
+	var b strings.Builder
+	for _, a := range assigns {
+		name, value, _ := strings.Cut(a, "=")
+		fmt.Fprintf(&b, "%s%s=%s\n", prefix, name, shellQuote(value))
+	}
+	if len(args) > 0 {
+		quoted := make([]string, len(args))
+		for i, a := range args {
+			quoted[i] = shellQuote(a)
+		}
+		fmt.Fprintf(&b, "%s%s\n", prefix, strings.Join(quoted, " "))
+	}
+	// одной записью: строки трассировки параллельных стадий конвейера не перемешиваются
+	errOut.WriteString(b.String())
+}
+
+// checkFailure — неуспешный конвейер выполняет ловушку ERR, а с set -e завершает shell. Не действует
+// в условиях if, while и until, в левой части && и ||, в конвейере с !, а также на составную команду целиком —
+// только на команды в ней. Подоболочка ( ) — команда: её неуспех завершает shell
+func (sh *Interpreter) checkFailure(pipe *PipelineNode, code int) {
+	if code == 0 || sh.noErrexit > 0 || pipe.Negate || sh.flow != flowNone {
+		return
+	}
+	if len(pipe.Cmds) == 1 {
+		switch pipe.Cmds[0].(type) {
+		case *Group, *IfNode, *LoopNode, *ForNode, *CaseNode:
+			return
+		}
+	}
+	sh.runErrTrap()
+	if sh.setOptions["errexit"] {
+		sh.flow = flowExit
+	}
+}
+
+// ignoreErrexit — выполняет fn там, где set -e и ловушка ERR не действуют: в условиях и левой части && и ||
+func (sh *Interpreter) ignoreErrexit(fn func() int) int {
+	sh.noErrexit++
+	defer func() { sh.noErrexit-- }()
+	return fn()
+}
@@ -0,0 +1,704 @@
+package minishell
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Node — узел синтаксического дерева: *ListNode, *AndOrNode, *PipelineNode, *CmdUnit,
+// составные команды (*Subshell, *Group, *IfNode, *LoopNode, *ForNode, *CaseNode) и *FuncDef
+type Node interface{}
+
+// ListNode — команды через ;, & или перевод строки
+type ListNode struct {
+	Items []*ListItem
+}
+
+// ListItem — элемент списка: and-or список, возможно запущенный в фоне
+type ListItem struct {
+	Node       Node
+	Background bool   // завершён &
+	Src        string // исходный текст без &, для фоновых заданий
+}
+
+// AndOrNode — A && B или A || B; цепочки левоассоциативны: a && b || c = (a && b) || c
+type AndOrNode struct {
+	Op          TokenKind // TK_AND или TK_OR
+	Left, Right Node
+}
+
+// PipelineNode — команды, разделённые |
+type PipelineNode struct {
+	Cmds      []Node // *CmdUnit, составные команды или *FuncDef; у time без команды пусто
+	Negate    bool   // ! перед конвейером: код инвертируется
+	Time      bool   // time перед конвейером: после него печатается затраченное время
+	TimePosix bool   // time -p: время в формате POSIX, без TIMEFORMAT
+	Src       string // исходный текст, для списка заданий
+}
+
+// Compound — общая часть составных команд: перенаправления после закрывающего слова
+type Compound struct {
+	Redirs []Redirect
+}
+
+// redirects — перенаправления составной команды
+func (c *Compound) redirects() []Redirect {
+	return c.Redirs
+}
+
+// compoundNode — составная команда
+type compoundNode interface {
+	redirects() []Redirect
+}
+
+// Subshell — ( список ), выполняется в дочернем процессе shell
+type Subshell struct {
+	Compound
+	Body *ListNode
+}
+
+// Group — { список; }, выполняется в текущем shell
+type Group struct {
+	Compound
+	Body *ListNode
+}
+
+// IfNode — if Cond; then Then; [elif ...;] [else Else;] fi. elif — вложенный *IfNode в Else
+type IfNode struct {
+	Compound
+	Cond, Then *ListNode
+	Else       Node // nil, *ListNode или *IfNode
+}
+
+// LoopNode — while Cond; do Body; done или until
+type LoopNode struct {
+	Compound
+	Until      bool
+	Cond, Body *ListNode
+}
+
+// ForNode — for Var [in Words]; do Body; done. Без in — по позиционным параметрам
+type ForNode struct {
+	Compound
+	Var   string
+	Words []string // в исходном виде
+	In    bool
+	Body  *ListNode
+}
+
+// CaseNode — case Word in pattern) list;; ... esac
+type CaseNode struct {
+	Compound
+	Word  string // в исходном виде
+	Items []*CaseItem
+}
+
+// CaseItem — ветка case: шаблоны через | и список
+type CaseItem struct {
+	Patterns []string // в исходном виде
+	Body     *ListNode
+}
+
+// FuncDef — определение функции name() { ...; }
+type FuncDef struct {
+	Name string
+	Body Node // составная команда
+}
+
+// errIncomplete — ввод закончился посреди конструкции; интерактивно дочитываются следующие строки
+var errIncomplete = errors.New("syntax error: unexpected end of file")
+
+// parser — разбор токенов строки src в дерево
+type parser struct {
+	toks    []Token
+	pos     int
+	src     string
+	aliases map[string]string // алиасы, которые раскрываются при разборе
+}
+
+// parse — строит дерево по токенам текста src:
+//
+//	list     := and_or ((';' | '&' | '\n') and_or)* [';' | '&' | '\n']
+//	and_or   := pipeline (('&&' | '||') pipeline)*
+//	pipeline := command ('|' command)*
+//	command  := simple | compound redirs | name '(' ')' compound
+//	compound := '(' list ')' | '{' list '}' | if | while | until | for | case
+//
+// Алиасы не раскрываются, для этого — Interpreter.parse
+func parse(toks []Token, src string) (*ListNode, error) {
+	return parseAliases(toks, src, nil)
+}
+
+// parse — разбор, при котором первые слова команд раскрываются как алиасы интерпретатора
+func (sh *Interpreter) parse(toks []Token, src string) (*ListNode, error) {
+	return parseAliases(toks, src, sh.aliases)
+}
+
+// parseAliases — разбор с алиасами aliases; nil — без них
+func parseAliases(toks []Token, src string, aliases map[string]string) (*ListNode, error) {
+	p := &parser{toks: toks, src: src, aliases: aliases}
+
+	list, err := p.parseList()
+	if err != nil {
+		return nil, err
+	}
+	if !p.atEnd() {
+		return nil, p.unexpected()
+	}
+	return list, nil
+}
+
+// Слова, которые заканчивают список
+var listTerminators = map[string]bool{
+	"}": true, "then": true, "elif": true, "else": true, "fi": true, "do": true, "done": true, "esac": true,
+}
+
+// peek — текущий токен; за концом — пустой токен TK_WORD
+func (p *parser) peek() Token {
+	if p.atEnd() {
+		return Token{}
+	}
+	return p.toks[p.pos]
+}
+
+// atEnd — токены закончились
+func (p *parser) atEnd() bool {
+	return p.pos >= len(p.toks)
+}
+
+// is — текущий токен имеет вид kind
+func (p *parser) is(kind TokenKind) bool {
+	return !p.atEnd() && p.toks[p.pos].Kind == kind
+}
+
+// isReserved — текущий токен — зарезервированное слово w без кавычек
+func (p *parser) isReserved(w string) bool {
+	t := p.peek()
+	return !p.atEnd() && t.Kind == TK_WORD && !t.Quoted && t.Val == w
+}
+
+// atListEnd — список здесь заканчивается: конец ввода, ), ;; или закрывающее слово
+func (p *parser) atListEnd() bool {
+	t := p.peek()
+	return p.atEnd() || t.Kind == TK_RPAREN || t.Kind == TK_DSEMI ||
+		t.Kind == TK_WORD && !t.Quoted && listTerminators[t.Val]
+}
+
+// skipNewlines — пропускает переводы строк
+func (p *parser) skipNewlines() {
+	for p.is(TK_NEWLINE) {
+		p.pos++
+	}
+}
+
+// expect — следующий токен должен быть зарезервированным словом w
+func (p *parser) expect(w string) error {
+	if !p.isReserved(w) {
+		return p.unexpected()
+	}
+	p.pos++
+	return nil
+}
+
+// unexpected — ошибка о текущем токене
+func (p *parser) unexpected() error {
+	if p.atEnd() {
+		return errIncomplete
+	}
+	val := p.peek().Val
+	if val == "\n" {
+		val = "newline"
+	}
+	return fmt.Errorf("syntax error near unexpected token `%s'", val)
+}
+
+// span — исходный текст токенов с from по to (не включая)
+func (p *parser) span(from, to int) string {
+	if from >= to {
+		return ""
+	}
+	return p.src[p.toks[from].Pos:p.toks[to-1].End]
+}
+
+// parseList — список до конца ввода, ), ;; или закрывающего слова; может быть пустым
+func (p *parser) parseList() (*ListNode, error) {
+	list := &ListNode{}
+	p.skipNewlines()
+
+	for !p.atListEnd() {
+		start := p.pos
+		node, err := p.parseAndOr()
+		if err != nil {
+			return nil, err
+		}
+		item := &ListItem{Node: node, Src: p.span(start, p.pos)}
+		list.Items = append(list.Items, item)
+
+		switch {
+		case p.is(TK_SEMI), p.is(TK_NEWLINE):
+			p.pos++
+		case p.is(TK_AMP):
+			item.Background = true
+			p.pos++
+		case !p.atListEnd():
+			return nil, p.unexpected()
+		}
+		p.skipNewlines()
+	}
+
+	return list, nil
+}
+
+// parseBody — непустой список внутри составной команды
+func (p *parser) parseBody() (*ListNode, error) {
+	list, err := p.parseList()
+	if err != nil {
+		return nil, err
+	}
+	if len(list.Items) == 0 {
+		return nil, p.unexpected()
+	}
+	return list, nil
+}
+
+// parseAndOr — конвейеры через && и ||
+func (p *parser) parseAndOr() (Node, error) {
+	pipe, err := p.parsePipeline()
+	if err != nil {
+		return nil, err
+	}
+
+	var left Node = pipe
+	for p.is(TK_AND) || p.is(TK_OR) {
+		op := p.peek().Kind
+		p.pos++
+		p.skipNewlines()
+		right, err := p.parsePipeline()
+		if err != nil {
+			return nil, err
+		}
+		left = &AndOrNode{Op: op, Left: left, Right: right}
+	}
+	return left, nil
+}
+
+// parsePipeline — команды через |, перед ними могут стоять time [-p] и !
+func (p *parser) parsePipeline() (*PipelineNode, error) {
+	start := p.pos
+	pipe := &PipelineNode{}
+	if p.isReserved("time") {
+		pipe.Time = true
+		p.pos++
+		if p.isReserved("-p") {
+			pipe.TimePosix = true
+			p.pos++
+		}
+	}
+	if p.isReserved("!") {
+		pipe.Negate = true
+		p.pos++
+	}
+
+	// time без команды замеряет пустой конвейер
+	if pipe.Time && (p.atListEnd() || p.is(TK_NEWLINE) || p.is(TK_SEMI) || p.is(TK_AMP) || p.is(TK_AND) || p.is(TK_OR)) {
+		pipe.Src = p.span(start, p.pos)
+		return pipe, nil
+	}
+
+	for {
+		cmd, err := p.parseCommand()
+		if err != nil {
+			return nil, err
+		}
+		pipe.Cmds = append(pipe.Cmds, cmd)
+
+		if !p.is(TK_PIPE) {
+			break
+		}
+		p.pos++
+		p.skipNewlines()
+	}
+
+	pipe.Src = p.span(start, p.pos)
+	return pipe, nil
+}
+
+// parseCommand — простая команда, составная команда с перенаправлениями или определение функции.
+// Первое слово команды перед разбором раскрывается как алиас
+func (p *parser) parseCommand() (Node, error) {
+	if p.isFuncDef() {
+		return p.parseFuncDef()
+	}
+	p.expandAliases(p.commandStart(), nil)
+
+	var node Node
+	var c *Compound
+	var err error
+
+	switch {
+	case p.is(TK_LPAREN):
+		p.pos++
+		s := &Subshell{}
+		s.Body, err = p.parseBody()
+		if err == nil && !p.is(TK_RPAREN) {
+			err = p.unexpected()
+		}
+		p.pos++
+		node, c = s, &s.Compound
+
+	case p.isReserved("{"):
+		p.pos++
+		g := &Group{}
+		g.Body, err = p.parseBody()
+		if err == nil {
+			err = p.expect("}")
+		}
+		node, c = g, &g.Compound
+
+	case p.isReserved("if"):
+		var n *IfNode
+		n, err = p.parseIf()
+		node = n
+		if n != nil {
+			c = &n.Compound
+		}
+
+	case p.isReserved("while"), p.isReserved("until"):
+		n := &LoopNode{Until: p.peek().Val == "until"}
+		p.pos++
+		n.Cond, err = p.parseBody()
+		if err == nil {
+			n.Body, err = p.parseDoGroup()
+		}
+		node, c = n, &n.Compound
+
+	case p.isReserved("for"):
+		var n *ForNode
+		n, err = p.parseFor()
+		node = n
+		if n != nil {
+			c = &n.Compound
+		}
+
+	case p.isReserved("case"):
+		var n *CaseNode
+		n, err = p.parseCase()
+		node = n
+		if n != nil {
+			c = &n.Compound
+		}
+
+	default:
+		return p.parseSimple()
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	c.Redirs, err = p.parseRedirects()
+	if err != nil {
+		return nil, err
+	}
+	return node, nil
+}
+
+// parseIf — if/elif ... fi; elif разбирается как вложенный if без своего fi
+func (p *parser) parseIf() (*IfNode, error) {
+	p.pos++ // if или elif
+	n := &IfNode{}
+
+	var err error
+	if n.Cond, err = p.parseBody(); err != nil {
+		return nil, err
+	}
+	if err = p.expect("then"); err != nil {
+		return nil, err
+	}
+	if n.Then, err = p.parseBody(); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case p.isReserved("elif"):
+		elif, err := p.parseIf()
+		if err != nil {
+			return nil, err
+		}
+		n.Else = elif
+		return n, nil // fi уже прочитан вложенным if
+
+	case p.isReserved("else"):
+		p.pos++
+		els, err := p.parseBody()
+		if err != nil {
+			return nil, err
+		}
+		n.Else = els
+	}
+
+	return n, p.expect("fi")
+}
+
+// parseDoGroup — do список done
+func (p *parser) parseDoGroup() (*ListNode, error) {
+	if err := p.expect("do"); err != nil {
+		return nil, err
+	}
+	body, err := p.parseBody()
+	if err != nil {
+		return nil, err
+	}
+	return body, p.expect("done")
+}
+
+// parseFor — for name [in words] ; do ... done
+func (p *parser) parseFor() (*ForNode, error) {
+	p.pos++
+	name := p.peek()
+	if name.Kind != TK_WORD || name.Quoted || !isName(name.Val) {
+		return nil, p.unexpected()
+	}
+	p.pos++
+	n := &ForNode{Var: name.Val}
+
+	p.skipNewlines()
+	if p.isReserved("in") {
+		p.pos++
+		n.In = true
+		for p.is(TK_WORD) {
+			n.Words = append(n.Words, p.peek().Raw)
+			p.pos++
+		}
+		if !p.is(TK_SEMI) && !p.is(TK_NEWLINE) {
+			return nil, p.unexpected()
+		}
+		p.pos++
+	} else if p.is(TK_SEMI) {
+		p.pos++
+	}
+	p.skipNewlines()
+
+	var err error
+	n.Body, err = p.parseDoGroup()
+	return n, err
+}
+
+// parseCase — case word in [(]pat[|pat]) list ;; ... esac
+func (p *parser) parseCase() (*CaseNode, error) {
+	p.pos++
+	if !p.is(TK_WORD) {
+		return nil, p.unexpected()
+	}
+	n := &CaseNode{Word: p.peek().Raw}
+	p.pos++
+
+	p.skipNewlines()
+	if err := p.expect("in"); err != nil {
+		return nil, err
+	}
+	p.skipNewlines()
+
+	for !p.isReserved("esac") {
+		item := &CaseItem{}
+		if p.is(TK_LPAREN) {
+			p.pos++
+		}
+		for {
+			if !p.is(TK_WORD) {
+				return nil, p.unexpected()
+			}
+			item.Patterns = append(item.Patterns, p.peek().Raw)
+			p.pos++
+			if !p.is(TK_PIPE) {
+				break
+			}
+			p.pos++
+		}
+		if !p.is(TK_RPAREN) {
+			return nil, p.unexpected()
+		}
+		p.pos++
+
+		var err error
+		if item.Body, err = p.parseList(); err != nil {
+			return nil, err
+		}
+		n.Items = append(n.Items, item)
+
+		if !p.is(TK_DSEMI) {
+			break // последняя ветка может обойтись без ;;
+		}
+		p.pos++
+		p.skipNewlines()
+	}
+
+	return n, p.expect("esac")
+}
+
+// isFuncDef — впереди name() или function name
+func (p *parser) isFuncDef() bool {
+	if p.isReserved("function") {
+		return true
+	}
+	t := p.peek()
+	return t.Kind == TK_WORD && !t.Quoted && isName(t.Val) &&
+		p.pos+2 < len(p.toks) && p.toks[p.pos+1].Kind == TK_LPAREN && p.toks[p.pos+2].Kind == TK_RPAREN
+}
+
+// parseFuncDef — name() compound или function name [()] compound
+func (p *parser) parseFuncDef() (*FuncDef, error) {
+	if p.isReserved("function") {
+		p.pos++
+		t := p.peek()
+		if t.Kind != TK_WORD || t.Quoted || !isName(t.Val) {
+			return nil, p.unexpected()
+		}
+	}
+	n := &FuncDef{Name: p.peek().Val}
+	p.pos++
+
+	if p.is(TK_LPAREN) {
+		p.pos++
+		if !p.is(TK_RPAREN) {
+			return nil, p.unexpected()
+		}
+		p.pos++
+	}
+	p.skipNewlines()
+
+	body, err := p.parseCommand()
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := body.(compoundNode); !ok {
+		return nil, fmt.Errorf("%s: function body must be a compound command", n.Name)
+	}
+	n.Body = body
+	return n, nil
+}
+
+// parseRedirects — перенаправления после составной команды
+func (p *parser) parseRedirects() ([]Redirect, error) {
+	var redirs []Redirect
+	for p.is(TK_REDIR) {
+		r, err := p.parseRedirect()
+		if err != nil {
+			return nil, err
+		}
+		redirs = append(redirs, r...)
+	}
+	return redirs, nil
+}
+
+// parseRedirect — оператор перенаправления и слово после него
+func (p *parser) parseRedirect() ([]Redirect, error) {
+	op := p.peek().Val
+	p.pos++
+	if !p.is(TK_WORD) {
+		if p.atEnd() {
+			return nil, errIncomplete
+		}
+		return nil, fmt.Errorf("expected word after %s", op)
+	}
+	word := p.peek()
+	p.pos++
+	return parseRedirect(op, word)
+}
+
+// parseSimple — простая команда: слова и перенаправления в любом порядке
+func (p *parser) parseSimple() (*CmdUnit, error) {
+	unit := &CmdUnit{Args: []string{}}
+
+	for p.is(TK_WORD) || p.is(TK_REDIR) {
+		if p.is(TK_REDIR) {
+			r, err := p.parseRedirect()
+			if err != nil {
+				return nil, err
+			}
+			unit.Redirs = append(unit.Redirs, r...)
+			continue
+		}
+
+		// подстановки делаются при выполнении
+		unit.Args = append(unit.Args, p.peek().Raw)
+		p.pos++
+	}
+
+	if len(unit.Args) == 0 {
+		if len(unit.Redirs) > 0 {
+			return nil, fmt.Errorf("empty command")
+		}
+		return nil, p.unexpected()
+	}
+	return unit, nil
+}
+
+// isName — допустимое имя переменной или функции
+func isName(s string) bool {
+	if s == "" || s[0] >= '0' && s[0] <= '9' {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if !isAlnum(s[i]) && s[i] != '_' {
+			return false
+		}
+	}
+	return true
+}
+
+// walkRedirects — вызывает fn для каждого перенаправления в дереве, в порядке записи
+func walkRedirects(n Node, fn func(*Redirect)) {
+	each := func(redirs []Redirect) {
+		for i := range redirs {
+			fn(&redirs[i])
+		}
+	}
+	lists := func(lists ...*ListNode) {
+		for _, l := range lists {
+			if l != nil {
+				walkRedirects(l, fn)
+			}
+		}
+	}
+
+	switch n := n.(type) {
+	case *ListNode:
+		for _, item := range n.Items {
+			walkRedirects(item.Node, fn)
+		}
+	case *AndOrNode:
+		walkRedirects(n.Left, fn)
+		walkRedirects(n.Right, fn)
+	case *PipelineNode:
+		for _, c := range n.Cmds {
+			walkRedirects(c, fn)
+		}
+	case *CmdUnit:
+		each(n.Redirs)
+	case *FuncDef:
+		walkRedirects(n.Body, fn)
+	case *Subshell:
+		lists(n.Body)
+		each(n.Redirs)
+	case *Group:
+		lists(n.Body)
+		each(n.Redirs)
+	case *IfNode:
+		lists(n.Cond, n.Then)
+		if n.Else != nil {
+			walkRedirects(n.Else, fn)
+		}
+		each(n.Redirs)
+	case *LoopNode:
+		lists(n.Cond, n.Body)
+		each(n.Redirs)
+	case *ForNode:
+		lists(n.Body)
+		each(n.Redirs)
+	case *CaseNode:
+		for _, item := range n.Items {
+			lists(item.Body)
+		}
+		each(n.Redirs)
+	}
+}
@@ -0,0 +1,98 @@
+package minishell
+
+// matchPattern — сопоставление строки s с шаблоном shell целиком: * — любая строка, ? — любой символ,
+// [abc], [a-z], [!a-z] — символ из набора, \x — сам символ x
+func matchPattern(pattern, s string) bool {
+	p, str := []rune(pattern), []rune(s)
+	pi, si := 0, 0
+	starP, starS := -1, 0 // позиция после последней * и сколько символов она уже поглотила
+
+	for si < len(str) {
+		if pi < len(p) {
+			switch p[pi] {
+			case '*':
+				starP, starS = pi+1, si
+				pi++
+				continue
+			case '?':
+				pi++
+				si++
+				continue
+			case '[':
+				if ok, next := matchClass(p, pi, str[si]); next > 0 {
+					if ok {
+						pi, si = next, si+1
+						continue
+					}
+					break
+				}
+				// незакрытая [ — обычный символ
+				if str[si] == '[' {
+					pi++
+					si++
+					continue
+				}
+			case '\\':
+				if pi+1 < len(p) && p[pi+1] == str[si] {
+					pi += 2
+					si++
+					continue
+				}
+			default:
+				if p[pi] == str[si] {
+					pi++
+					si++
+					continue
+				}
+			}
+		}
+
+		// несовпадение: * забирает ещё один символ
+		if starP < 0 {
+			return false
+		}
+		starS++
+		pi, si = starP, starS
+	}
+
+	for pi < len(p) && p[pi] == '*' {
+		pi++
+	}
+	return pi == len(p)
+}
+
+// matchClass — проверяет символ c по набору [...] с позиции i. next — позиция после ], 0 — набор не закрыт
+func matchClass(p []rune, i int, c rune) (ok bool, next int) {
+	i++
+	negate := false
+	if i < len(p) && (p[i] == '!' || p[i] == '^') {
+		negate = true
+		i++
+	}
+
+	for first := true; i < len(p); first = false {
+		if p[i] == ']' && !first {
+			return ok != negate, i + 1
+		}
+
+		lo := p[i]
+		if lo == '\\' && i+1 < len(p) {
+			i++
+			lo = p[i]
+		}
+		hi := lo
+		if i+2 < len(p) && p[i+1] == '-' && p[i+2] != ']' {
+			hi = p[i+2]
+			if hi == '\\' && i+3 < len(p) {
+				i++
+				hi = p[i+2]
+			}
+			i += 2
+		}
+		if lo <= c && c <= hi {
+			ok = true
+		}
+		i++
+	}
+	return false, 0
+}
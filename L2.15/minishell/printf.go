@@ -0,0 +1,308 @@
+package minishell
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// simpleEscapes — однобуквенные \-последовательности echo -e и printf
+var simpleEscapes = map[byte]string{
+	'a': "\a", 'b': "\b", 'e': "\x1b", 'E': "\x1b", 'f': "\f", 'n': "\n", 'r': "\r", 't': "\t", 'v': "\v",
+	'\\': "\\",
+}
+
+// escapeSequence — \-последовательность в начале s: текст и длина записи. stop — \c, вывод на этом заканчивается.
+// Восьмеричный код — \0nnn в echo -e и %b (zeroOctal), \nnn в формате printf.
+// Незнакомая последовательность остаётся как есть
+func escapeSequence(s string, zeroOctal bool) (text string, n int, stop bool) {
+	if len(s) < 2 {
+		return s, len(s), false
+	}
+	c := s[1]
+	if t, ok := simpleEscapes[c]; ok {
+		return t, 2, false
+	}
+
+	switch {
+	case c == 'c':
+		return "", 2, true
+
+	case c == '"' && !zeroOctal:
+		return `"`, 2, false
+
+	case c >= '0' && c <= '7' && (!zeroOctal || c == '0'):
+		start, maxLen := 1, 3
+		if zeroOctal && c == '0' {
+			start = 2
+		}
+		end := start
+		for end < len(s) && end-start < maxLen && s[end] >= '0' && s[end] <= '7' {
+			end++
+		}
+		v, _ := strconv.ParseUint(s[start:end], 8, 16)
+		return string([]byte{byte(v)}), end, false
+
+	case c == 'x' || c == 'u' || c == 'U':
+		maxLen := map[byte]int{'x': 2, 'u': 4, 'U': 8}[c]
+		end := 2
+		for end < len(s) && end-2 < maxLen && isHexDigit(s[end]) {
+			end++
+		}
+		if end == 2 {
+			return s[:2], 2, false
+		}
+		v, _ := strconv.ParseUint(s[2:end], 16, 32)
+		if c == 'x' {
+			return string([]byte{byte(v)}), end, false
+		}
+		return string(rune(v)), end, false
+	}
+	return s[:2], 2, false
+}
+
+// isHexDigit — шестнадцатеричная цифра
+func isHexDigit(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+}
+
+// expandEscapes — \-последовательности в s, как у echo -e и printf %b. stop — встретилась \c
+func expandEscapes(s string) (string, bool) {
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		if s[i] != '\\' {
+			b.WriteByte(s[i])
+			i++
+			continue
+		}
+		text, n, stop := escapeSequence(s[i:], true)
+		b.WriteString(text)
+		if stop {
+			return b.String(), true
+		}
+		i += n
+	}
+	return b.String(), false
+}
+
+// builtinEcho — echo [-neE] args: печатает аргументы через пробел. -n — без перевода строки,
+// -e — с \-последовательностями, -E — без них
+func builtinEcho(args []string, out io.Writer) (int, error) {
+	newline, escapes := true, false
+	for len(args) > 0 && len(args[0]) > 1 && args[0][0] == '-' && strings.Trim(args[0][1:], "neE") == "" {
+		for _, c := range args[0][1:] {
+			switch c {
+			case 'n':
+				newline = false
+			case 'e':
+				escapes = true
+			case 'E':
+				escapes = false
+			}
+		}
+		args = args[1:]
+	}
+
+	s := strings.Join(args, " ")
+	if escapes {
+		var stop bool
+		if s, stop = expandEscapes(s); stop {
+			newline = false
+		}
+	}
+	if newline {
+		s += "\n"
+	}
+	io.WriteString(out, s)
+	return 0, nil
+}
+
+// printfState — аргументы printf и ошибки их преобразования
+type printfState struct {
+	args []string
+	used bool // формат взял хотя бы один аргумент
+	err  error
+}
+
+// next — следующий аргумент; когда они закончились — пустая строка
+func (p *printfState) next() string {
+	if len(p.args) == 0 {
+		return ""
+	}
+	a := p.args[0]
+	p.args = p.args[1:]
+	p.used = true
+	return a
+}
+
+// number — аргумент для числовых преобразований: целое в записи Go (0x, 0 — восьмеричное)
+// или код символа после ' или "
+func (p *printfState) number(a string) int64 {
+	if a == "" {
+		return 0
+	}
+	if a[0] == '\'' || a[0] == '"' {
+		r, _ := utf8.DecodeRuneInString(a[1:])
+		return int64(r)
+	}
+	v, err := strconv.ParseInt(strings.TrimSpace(a), 0, 64)
+	if err != nil {
+		if u, uerr := strconv.ParseUint(strings.TrimSpace(a), 0, 64); uerr == nil {
+			return int64(u)
+		}
+		p.err = errors.Join(p.err, fmt.Errorf("printf: %s: invalid number", a))
+	}
+	return v
+}
+
+// float — аргумент для %e, %f, %g
+func (p *printfState) float(a string) float64 {
+	if a != "" && (a[0] == '\'' || a[0] == '"') {
+		return float64(p.number(a))
+	}
+	if a == "" {
+		return 0
+	}
+	v, err := strconv.ParseFloat(strings.TrimSpace(a), 64)
+	if err != nil {
+		p.err = errors.Join(p.err, fmt.Errorf("printf: %s: invalid number", a))
+	}
+	return v
+}
+
+// builtinPrintf — printf [-v var] format [args]: формат с преобразованиями %s %b %q %c %d %i %u %o %x %X
+// %e %f %g и флагами, шириной и точностью (* — из аргумента). Формат повторяется, пока есть аргументы.
+// -v — результат в переменную вместо вывода
+func (sh *Interpreter) builtinPrintf(args []string, out io.Writer) (int, error) {
+	varName := ""
+	if len(args) > 0 && args[0] == "-v" {
+		if len(args) < 2 || !isName(args[1]) {
+			return 2, fmt.Errorf("printf: -v: variable name required")
+		}
+		varName, args = args[1], args[2:]
+	}
+	if len(args) > 0 && args[0] == "--" {
+		args = args[1:]
+	}
+	if len(args) == 0 {
+		return 2, fmt.Errorf("printf: usage: printf [-v var] format [arguments]")
+	}
+
+	format := args[0]
+	p := &printfState{args: args[1:]}
+	var b strings.Builder
+	for {
+		p.used = false
+		stop, err := p.format(&b, format)
+		if err != nil {
+			return 1, err
+		}
+		if stop || len(p.args) == 0 || !p.used {
+			break
+		}
+	}
+
+	if varName != "" {
+		sh.setVar(varName, b.String())
+	} else {
+		io.WriteString(out, b.String())
+	}
+	if p.err != nil {
+		return 1, p.err
+	}
+	return 0, nil
+}
+
+// format — один проход формата; stop — встретилась \c в формате или %b
+func (p *printfState) format(b *strings.Builder, format string) (stop bool, err error) {
+	for i := 0; i < len(format); {
+		c := format[i]
+		if c == '\\' {
+			text, n, stop := escapeSequence(format[i:], false)
+			b.WriteString(text)
+			if stop {
+				return true, nil
+			}
+			i += n
+			continue
+		}
+		if c != '%' {
+			b.WriteByte(c)
+			i++
+			continue
+		}
+		if i+1 < len(format) && format[i+1] == '%' {
+			b.WriteByte('%')
+			i += 2
+			continue
+		}
+
+		// %[флаги][ширина][.точность]преобразование
+		j := i + 1
+		spec := "%"
+		for j < len(format) && strings.IndexByte("-+ #0", format[j]) >= 0 {
+			spec += format[j : j+1]
+			j++
+		}
+		for _, part := range []string{"width", "precision"} {
+			if part == "precision" {
+				if j >= len(format) || format[j] != '.' {
+					break
+				}
+				spec += "."
+				j++
+			}
+			if j < len(format) && format[j] == '*' {
+				spec += strconv.FormatInt(p.number(p.next()), 10)
+				j++
+				continue
+			}
+			for j < len(format) && format[j] >= '0' && format[j] <= '9' {
+				spec += format[j : j+1]
+				j++
+			}
+		}
+		if j >= len(format) {
+			return false, fmt.Errorf("printf: `%s': missing format character", format[i:])
+		}
+
+		verb := format[j]
+		i = j + 1
+		switch verb {
+		case 's':
+			fmt.Fprintf(b, spec+"s", p.next())
+		case 'b':
+			s, stop := expandEscapes(p.next())
+			fmt.Fprintf(b, spec+"s", s)
+			if stop {
+				return true, nil
+			}
+		case 'q':
+			fmt.Fprintf(b, spec+"s", shellQuote(p.next()))
+		case 'c':
+			r, _ := utf8.DecodeRuneInString(p.next())
+			s := ""
+			if r != utf8.RuneError {
+				s = string(r)
+			}
+			fmt.Fprintf(b, spec+"s", s)
+		case 'd', 'i':
+			fmt.Fprintf(b, spec+"d", p.number(p.next()))
+		case 'u':
+			fmt.Fprintf(b, spec+"d", uint64(p.number(p.next())))
+		case 'o', 'x', 'X':
+			fmt.Fprintf(b, spec+string(verb), uint64(p.number(p.next())))
+		case 'e', 'E', 'f', 'F', 'g', 'G':
+			if verb == 'F' {
+				verb = 'f'
+			}
+			fmt.Fprintf(b, spec+string(verb), p.float(p.next()))
+		default:
+			return false, fmt.Errorf("printf: `%c': invalid format character", verb)
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,164 @@
+package minishell
+
+import (
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Приглашения по умолчанию: PS1 — перед командой, PS2 — перед строкой продолжения,
+// PS4 — перед командой в трассировке set -x
+const (
+	defaultPS1 = `\w\$ `
+	defaultPS2 = "> "
+	defaultPS4 = "+ "
+)
+
+// initPrompts — задаёт PS1 и PS2, если их нет в окружении
+func (sh *Interpreter) initPrompts() {
+	if _, ok := sh.lookupVar("PS1"); !ok {
+		sh.setVar("PS1", defaultPS1)
+	}
+	if _, ok := sh.lookupVar("PS2"); !ok {
+		sh.setVar("PS2", defaultPS2)
+	}
+}
+
+// promptString — приглашение из переменной name (PS1 или PS2) с раскрытыми \-последовательностями и подстановками.
+// Подстановки раскрываются после \-последовательностей, но в их значениях не выполняются: каталог
+// с именем '$(cmd)' показывается как есть
+func (sh *Interpreter) promptString(name string) string {
+	ps, _ := sh.lookupVar(name)
+	return sh.expandEnv(sh.expandPromptEscapes(ps))
+}
+
+// expandPromptEscapes — \-последовательности приглашения:
+// \u — пользователь, \h — имя хоста до точки, \H — полное, \w — текущий каталог (домашний — ~), \W — его имя,
+// \$ — # для root, иначе $, \? — код последней команды, \g — ветка git, \s — имя shell,
+// \t — время ЧЧ:ММ:СС, \A — ЧЧ:ММ, \d — дата, \n — перевод строки, \e — ESC, \a — звонок, \\ — \.
+// \[ и \] (границы невидимого текста) отбрасываются. $, ` и \ в именах пользователя, хоста, каталога,
+// ветки и shell экранируются для expandEnv
+func (sh *Interpreter) expandPromptEscapes(ps string) string {
+	var b strings.Builder
+	for i := 0; i < len(ps); i++ {
+		if ps[i] != '\\' || i+1 == len(ps) {
+			b.WriteByte(ps[i])
+			continue
+		}
+
+		i++
+		switch c := ps[i]; c {
+		case 'u':
+			b.WriteString(promptQuoter.Replace(sh.userName()))
+		case 'h', 'H':
+			host, _ := os.Hostname()
+			if c == 'h' {
+				host, _, _ = strings.Cut(host, ".")
+			}
+			b.WriteString(promptQuoter.Replace(host))
+		case 'w':
+			b.WriteString(promptQuoter.Replace(sh.promptDir()))
+		case 'W':
+			dir := sh.promptDir()
+			if dir != "~" && dir != "/" {
+				dir = filepath.Base(dir)
+			}
+			b.WriteString(promptQuoter.Replace(dir))
+		case '$':
+			if os.Geteuid() == 0 {
+				b.WriteByte('#')
+			} else {
+				b.WriteByte('$')
+			}
+		case '?':
+			b.WriteString(strconv.Itoa(sh.lastStatus))
+		case 'g':
+			b.WriteString(promptQuoter.Replace(sh.gitBranch()))
+		case 's':
+			b.WriteString(promptQuoter.Replace(filepath.Base(sh.scriptName)))
+		case 't':
+			b.WriteString(time.Now().Format("15:04:05"))
+		case 'A':
+			b.WriteString(time.Now().Format("15:04"))
+		case 'd':
+			b.WriteString(time.Now().Format("Mon Jan 02"))
+		case 'n':
+			b.WriteByte('\n')
+		case 'e':
+			b.WriteByte('\x1b')
+		case 'a':
+			b.WriteByte('\a')
+		case '\\':
+			b.WriteByte('\\')
+		case '[', ']':
+		default:
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// promptQuoter — экранирует текст, который приглашение показывает как есть, от подстановок expandEnv
+var promptQuoter = strings.NewReplacer(`\`, `\\`, "$", `\$`, "`", "\\`")
+
+// userName — имя текущего пользователя
+func (sh *Interpreter) userName() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	name, _ := sh.lookupVar("USER")
+	return name
+}
+
+// promptDir — текущий каталог; домашний каталог в начале заменяется на ~
+func (sh *Interpreter) promptDir() string {
+	cwd := sh.dir
+	home, _ := sh.lookupVar("HOME")
+	home = strings.TrimSuffix(home, "/")
+	if home != "" && (cwd == home || strings.HasPrefix(cwd, home+"/")) {
+		return "~" + cwd[len(home):]
+	}
+	return cwd
+}
+
+// gitBranch — ветка git-репозитория, в котором находится текущий каталог; для отсоединённого HEAD — начало хеша.
+// Вне репозитория пусто
+func (sh *Interpreter) gitBranch() string {
+	dir := sh.dir
+	for {
+		gitDir := filepath.Join(dir, ".git")
+		if fi, err := os.Stat(gitDir); err == nil {
+			// .git-файл рабочего дерева: gitdir: путь
+			if !fi.IsDir() {
+				data, err := os.ReadFile(gitDir)
+				if err != nil {
+					return ""
+				}
+				gitDir = strings.TrimSpace(strings.TrimPrefix(string(data), "gitdir:"))
+				if !filepath.IsAbs(gitDir) {
+					gitDir = filepath.Join(dir, gitDir)
+				}
+			}
+
+			head, err := os.ReadFile(filepath.Join(gitDir, "HEAD"))
+			if err != nil {
+				return ""
+			}
+			ref := strings.TrimSpace(string(head))
+			if branch, ok := strings.CutPrefix(ref, "ref: refs/heads/"); ok {
+				return branch
+			}
+			return ref[:min(len(ref), 7)]
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
@@ -0,0 +1,533 @@
+package minishell
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// clockTicks — единицы времени в /proc/[pid]/stat (USER_HZ), в Linux всегда 100 в секунду
+const clockTicks = 100
+
+// procInfo — процесс из /proc/[pid]/stat, status и cmdline
+type procInfo struct {
+	pid, ppid, pgid, sid, tpgid int
+	uid                         int
+	state                       byte
+	comm                        string
+	args                        []string // пусто у потоков ядра
+	tty                         int      // номер устройства терминала, 0 — без терминала
+	utime, stime                uint64   // в тиках
+	start                       uint64   // тики с загрузки системы
+	priority, nice, threads     int
+	vsize                       uint64 // байты
+	rss                         uint64 // страницы
+
+	prefix string // отступ команды в дереве процессов
+}
+
+// readProc — процесс pid; ошибка, если он уже завершился
+func readProc(pid int) (*procInfo, error) {
+	dir := "/proc/" + strconv.Itoa(pid)
+	stat, err := os.ReadFile(dir + "/stat")
+	if err != nil {
+		return nil, err
+	}
+
+	// comm в скобках может содержать пробелы и скобки — поля идут после последней )
+	open, end := bytes.IndexByte(stat, '('), bytes.LastIndexByte(stat, ')')
+	if open < 0 || end < open {
+		return nil, fmt.Errorf("%s/stat: bad format", dir)
+	}
+	f := strings.Fields(string(stat[end+1:]))
+	if len(f) < 22 {
+		return nil, fmt.Errorf("%s/stat: bad format", dir)
+	}
+	num := func(i int) int {
+		v, _ := strconv.Atoi(f[i])
+		return v
+	}
+	unum := func(i int) uint64 {
+		v, _ := strconv.ParseUint(f[i], 10, 64)
+		return v
+	}
+
+	p := &procInfo{
+		pid: pid, comm: string(stat[open+1 : end]), state: f[0][0],
+		ppid: num(1), pgid: num(2), sid: num(3), tty: num(4), tpgid: num(5),
+		utime: unum(11), stime: unum(12), priority: num(15), nice: num(16), threads: num(17),
+		start: unum(19), vsize: unum(20), rss: unum(21),
+	}
+
+	if status, err := os.ReadFile(dir + "/status"); err == nil {
+		for _, line := range strings.Split(string(status), "\n") {
+			if v, ok := strings.CutPrefix(line, "Uid:"); ok {
+				if ids := strings.Fields(v); len(ids) > 1 {
+					p.uid, _ = strconv.Atoi(ids[1]) // действующий uid
+				}
+				break
+			}
+		}
+	}
+	if cmdline, err := os.ReadFile(dir + "/cmdline"); err == nil && len(cmdline) > 0 {
+		p.args = strings.Split(strings.TrimRight(string(cmdline), "\x00"), "\x00")
+	}
+	return p, nil
+}
+
+// listProcs — все процессы по возрастанию pid
+func listProcs() ([]*procInfo, error) {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, err
+	}
+	var procs []*procInfo
+	for _, e := range entries {
+		pid, err := strconv.Atoi(e.Name())
+		if err != nil {
+			continue
+		}
+		if p, err := readProc(pid); err == nil {
+			procs = append(procs, p)
+		}
+	}
+	sort.Slice(procs, func(i, j int) bool { return procs[i].pid < procs[j].pid })
+	return procs, nil
+}
+
+// procSystem — сведения о системе для столбцов времени и памяти
+type procSystem struct {
+	now      time.Time
+	boot     time.Time // время загрузки
+	uptime   float64   // секунды с загрузки
+	memTotal uint64    // КиБ
+	pageKB   uint64
+}
+
+// readSystem — время загрузки из /proc/stat, uptime и объём памяти
+func readSystem() *procSystem {
+	s := &procSystem{now: time.Now(), pageKB: uint64(os.Getpagesize() / 1024)}
+	if data, err := os.ReadFile("/proc/uptime"); err == nil {
+		if f := strings.Fields(string(data)); len(f) > 0 {
+			s.uptime, _ = strconv.ParseFloat(f[0], 64)
+		}
+	}
+	s.boot = s.now.Add(-time.Duration(s.uptime * float64(time.Second)))
+	if data, err := os.ReadFile("/proc/stat"); err == nil {
+		for _, line := range strings.Split(string(data), "\n") {
+			if v, ok := strings.CutPrefix(line, "btime "); ok {
+				if sec, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64); err == nil {
+					s.boot = time.Unix(sec, 0)
+				}
+			}
+		}
+	}
+	if data, err := os.ReadFile("/proc/meminfo"); err == nil {
+		for _, line := range strings.Split(string(data), "\n") {
+			if v, ok := strings.CutPrefix(line, "MemTotal:"); ok {
+				if f := strings.Fields(v); len(f) > 0 {
+					s.memTotal, _ = strconv.ParseUint(f[0], 10, 64)
+				}
+			}
+		}
+	}
+	return s
+}
+
+// elapsed — секунды с запуска процесса
+func (s *procSystem) elapsed(p *procInfo) float64 {
+	return max(s.uptime-float64(p.start)/clockTicks, 0)
+}
+
+// psColumn — столбец ps: заголовок, ширина, выравнивание вправо (числа) и значение
+type psColumn struct {
+	header string
+	width  int
+	right  bool
+	value  func(p *procInfo, s *procSystem) string
+}
+
+// psColumns — столбцы ps -o по именам, как в procps
+var psColumns = map[string]psColumn{
+	"pid":  {"PID", 7, true, func(p *procInfo, _ *procSystem) string { return strconv.Itoa(p.pid) }},
+	"ppid": {"PPID", 7, true, func(p *procInfo, _ *procSystem) string { return strconv.Itoa(p.ppid) }},
+	"pgid": {"PGID", 7, true, func(p *procInfo, _ *procSystem) string { return strconv.Itoa(p.pgid) }},
+	"sid":  {"SID", 7, true, func(p *procInfo, _ *procSystem) string { return strconv.Itoa(p.sid) }},
+	"uid":  {"UID", 5, true, func(p *procInfo, _ *procSystem) string { return strconv.Itoa(p.uid) }},
+	"user": {"USER", 8, false, func(p *procInfo, _ *procSystem) string { return uidName(p.uid) }},
+	"comm": {"COMMAND", 15, false, func(p *procInfo, _ *procSystem) string { return p.prefix + p.comm }},
+	"args": {"COMMAND", 0, false, func(p *procInfo, _ *procSystem) string { return p.prefix + procArgs(p) }},
+	"stat": {"STAT", 4, false, func(p *procInfo, _ *procSystem) string { return procStat(p) }},
+	"s":    {"S", 1, false, func(p *procInfo, _ *procSystem) string { return string(p.state) }},
+	"tty":  {"TT", 8, false, func(p *procInfo, _ *procSystem) string { return ttyName(p.tty) }},
+	"time": {"TIME", 8, true, func(p *procInfo, _ *procSystem) string {
+		return cpuTime((p.utime + p.stime) / clockTicks)
+	}},
+	"etime": {"ELAPSED", 11, true, func(p *procInfo, s *procSystem) string {
+		return elapsedTime(uint64(s.elapsed(p)))
+	}},
+	"stime": {"STIME", 5, false, func(p *procInfo, s *procSystem) string {
+		return startTime(s.boot.Add(time.Duration(p.start)*time.Second/clockTicks), s.now)
+	}},
+	"c": {"C", 2, true, func(p *procInfo, s *procSystem) string {
+		return strconv.Itoa(int(cpuPercent(p, s)))
+	}},
+	"%cpu": {"%CPU", 4, true, func(p *procInfo, s *procSystem) string {
+		return strconv.FormatFloat(cpuPercent(p, s), 'f', 1, 64)
+	}},
+	"%mem": {"%MEM", 4, true, func(p *procInfo, s *procSystem) string {
+		if s.memTotal == 0 {
+			return "0.0"
+		}
+		return strconv.FormatFloat(float64(p.rss*s.pageKB)*100/float64(s.memTotal), 'f', 1, 64)
+	}},
+	"rss":  {"RSS", 5, true, func(p *procInfo, s *procSystem) string { return strconv.FormatUint(p.rss*s.pageKB, 10) }},
+	"vsz":  {"VSZ", 6, true, func(p *procInfo, _ *procSystem) string { return strconv.FormatUint(p.vsize/1024, 10) }},
+	"ni":   {"NI", 3, true, func(p *procInfo, _ *procSystem) string { return strconv.Itoa(p.nice) }},
+	"pri":  {"PRI", 3, true, func(p *procInfo, _ *procSystem) string { return strconv.Itoa(p.priority) }},
+	"nlwp": {"NLWP", 4, true, func(p *procInfo, _ *procSystem) string { return strconv.Itoa(p.threads) }},
+}
+
+// psAliases — другие имена столбцов ps -o
+var psAliases = map[string]string{
+	"command": "args", "cmd": "args", "ucmd": "comm", "ucomm": "comm", "tt": "tty", "tname": "tty",
+	"state": "s", "start_time": "stime", "nice": "ni", "thcount": "nlwp", "pcpu": "%cpu", "pmem": "%mem",
+	"rssize": "rss", "vsize": "vsz", "euid": "uid", "euser": "user", "pgrp": "pgid", "session": "sid",
+}
+
+// psHeaders — заголовки, которые у procps отличаются от имени столбца
+var psHeaders = map[string]string{"cmd": "CMD"}
+
+// Столбцы ps по умолчанию, ps -f и ps без параметров
+var (
+	psDefaultFormat = []string{"pid", "tty=TTY", "time", "ucmd=CMD"}
+	psFullFormat    = []string{"user=UID", "pid", "ppid", "c", "stime", "tty=TTY", "time", "cmd"}
+	psPlainFormat   = []string{"pid", "ppid", "comm"}
+)
+
+// psSelected — столбец ps с заголовком
+type psSelected struct {
+	psColumn
+	header string
+}
+
+// splitPsFormat — список ps -o: столбцы через запятую или пробел; заголовок после = идёт до конца списка
+func splitPsFormat(format string) []string {
+	var items []string
+	for format != "" {
+		i := strings.IndexAny(format, ", ")
+		if eq := strings.IndexByte(format, '='); i < 0 || eq >= 0 && eq < i {
+			items = append(items, format)
+			break
+		}
+		if i > 0 {
+			items = append(items, format[:i])
+		}
+		format = format[i+1:]
+	}
+	return items
+}
+
+// psFormat — столбцы по элементам name или name=Заголовок
+func psFormat(items []string) ([]psSelected, error) {
+	var cols []psSelected
+	for _, item := range items {
+		name, header, custom := strings.Cut(item, "=")
+		key := name
+		if a, ok := psAliases[key]; ok {
+			key = a
+		}
+		col, ok := psColumns[key]
+		if !ok {
+			return nil, fmt.Errorf("ps: unknown user-defined format specifier \"%s\"", name)
+		}
+		if !custom {
+			header = col.header
+			if h, ok := psHeaders[name]; ok {
+				header = h
+			}
+		}
+		cols = append(cols, psSelected{col, header})
+	}
+	return cols, nil
+}
+
+// builtinPs — список процессов из /proc, как у procps:
+// без параметров — все процессы в столбцах PID, PPID, COMMAND;
+// иначе процессы этого пользователя на терминале shell, -e и -A — все, -p pid,... — заданные.
+// -f — полный формат, -o столбцы — свой; -H и --forest — дерево процессов
+func builtinPs(args []string, out io.Writer) (int, error) {
+	all := len(args) == 0
+	var pids []int
+	full, tree, forest := false, false, false
+	var format []string
+
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		if a == "--forest" {
+			tree, forest = true, true
+			continue
+		}
+		if len(a) < 2 || a[0] != '-' {
+			return 1, fmt.Errorf("ps: unsupported option %s", a)
+		}
+
+		for j := 1; j < len(a); j++ {
+			switch c := a[j]; c {
+			case 'e', 'A':
+				all = true
+			case 'f':
+				full = true
+			case 'H':
+				tree = true
+			case 'o', 'p':
+				val := a[j+1:]
+				if val == "" {
+					if i+1 == len(args) {
+						return 1, fmt.Errorf("ps: option -%c requires an argument", c)
+					}
+					i++
+					val = args[i]
+				}
+				if c == 'o' {
+					format = append(format, splitPsFormat(val)...)
+				} else {
+					for _, s := range strings.FieldsFunc(val, func(r rune) bool { return r == ',' || r == ' ' }) {
+						pid, err := strconv.Atoi(s)
+						if err != nil {
+							return 1, fmt.Errorf("ps: process ID list syntax error")
+						}
+						pids = append(pids, pid)
+					}
+				}
+				j = len(a)
+			default:
+				return 1, fmt.Errorf("ps: unknown option -%c", c)
+			}
+		}
+	}
+
+	switch {
+	case len(args) == 0:
+		format = psPlainFormat
+	case format != nil:
+	case full:
+		format = psFullFormat
+	default:
+		format = psDefaultFormat
+	}
+	cols, err := psFormat(format)
+	if err != nil {
+		return 1, err
+	}
+
+	procs, err := listProcs()
+	if err != nil {
+		return 1, err
+	}
+	procs = selectProcs(procs, all, pids)
+	if tree {
+		procs = procTree(procs, forest)
+	}
+	printPs(out, cols, procs, readSystem())
+	if len(pids) > 0 && len(procs) == 0 {
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// selectProcs — процессы для вывода: все, по списку pid или, как ps без -e, — этого пользователя на терминале shell
+func selectProcs(procs []*procInfo, all bool, pids []int) []*procInfo {
+	if all && len(pids) == 0 {
+		return procs
+	}
+	self, _ := readProc(os.Getpid())
+	var out []*procInfo
+	for _, p := range procs {
+		switch {
+		case len(pids) > 0:
+			if all || slices.Contains(pids, p.pid) {
+				out = append(out, p)
+			}
+		case self != nil && p.uid == os.Geteuid() && p.tty == self.tty:
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// procTree — процессы в порядке обхода дерева: потомки сразу после родителя, с отступом команды.
+// Корни — процессы, чей родитель не попал в список. forest — ветки \_ как у --forest, иначе пробелы как у -H
+func procTree(procs []*procInfo, forest bool) []*procInfo {
+	byPid := map[int]bool{}
+	children := map[int][]*procInfo{}
+	for _, p := range procs {
+		byPid[p.pid] = true
+	}
+	var roots []*procInfo
+	for _, p := range procs {
+		if byPid[p.ppid] && p.ppid != p.pid {
+			children[p.ppid] = append(children[p.ppid], p)
+		} else {
+			roots = append(roots, p)
+		}
+	}
+
+	out := make([]*procInfo, 0, len(procs))
+	var walk func(p *procInfo, depth int)
+	walk = func(p *procInfo, depth int) {
+		switch {
+		case depth > 0 && forest:
+			p.prefix = strings.Repeat("    ", depth-1) + " \\_ "
+		case depth > 0:
+			p.prefix = strings.Repeat("  ", depth)
+		}
+		out = append(out, p)
+		for _, c := range children[p.pid] {
+			walk(c, depth+1)
+		}
+	}
+	for _, r := range roots {
+		walk(r, 0)
+	}
+	return out
+}
+
+// printPs — таблица: числа выровнены вправо, текст влево, последний столбец не дополняется пробелами
+func printPs(out io.Writer, cols []psSelected, procs []*procInfo, sys *procSystem) {
+	var b strings.Builder
+	row := func(cell func(i int) string) {
+		line := ""
+		for i, c := range cols {
+			if i > 0 {
+				line += " "
+			}
+			text := cell(i)
+			width := max(c.width, len(c.header))
+			switch {
+			case c.right:
+				line += fmt.Sprintf("%*s", width, text)
+			case i < len(cols)-1:
+				line += fmt.Sprintf("%-*s", width, text)
+			default:
+				line += text
+			}
+		}
+		b.WriteString(strings.TrimRight(line, " ") + "\n")
+	}
+
+	// заголовок не печатается, если все заголовки пустые: ps -o pid=
+	if slices.ContainsFunc(cols, func(c psSelected) bool { return c.header != "" }) {
+		row(func(i int) string { return cols[i].header })
+	}
+	for _, p := range procs {
+		row(func(i int) string { return cols[i].value(p, sys) })
+	}
+	io.WriteString(out, b.String())
+}
+
+// procArgs — командная строка; у потоков ядра — имя в квадратных скобках
+func procArgs(p *procInfo) string {
+	if len(p.args) == 0 {
+		return "[" + p.comm + "]"
+	}
+	return strings.Join(p.args, " ")
+}
+
+// procStat — состояние с флагами: < и N — приоритет, s — лидер сессии, l — несколько потоков,
+// + — в группе переднего плана терминала
+func procStat(p *procInfo) string {
+	s := string(p.state)
+	switch {
+	case p.nice < 0:
+		s += "<"
+	case p.nice > 0:
+		s += "N"
+	}
+	if p.sid == p.pid {
+		s += "s"
+	}
+	if p.threads > 1 {
+		s += "l"
+	}
+	if p.tty != 0 && p.pgid == p.tpgid {
+		s += "+"
+	}
+	return s
+}
+
+// ttyName — имя терминала по номеру устройства: pts/N, ttyN, ttySN; без терминала — ?
+func ttyName(dev int) string {
+	if dev == 0 {
+		return "?"
+	}
+	major := dev >> 8 & 0xfff
+	minor := dev&0xff | dev>>12&0xfff00
+	switch {
+	case major >= 136 && major <= 143:
+		return "pts/" + strconv.Itoa(minor+(major-136)*256)
+	case major == 4 && minor < 64:
+		return "tty" + strconv.Itoa(minor)
+	case major == 4:
+		return "ttyS" + strconv.Itoa(minor-64)
+	case major == 5 && minor == 1:
+		return "console"
+	}
+	return "?"
+}
+
+// uidName — имя пользователя по uid; неизвестный — число
+func uidName(uid int) string {
+	if u, err := user.LookupId(strconv.Itoa(uid)); err == nil {
+		return u.Username
+	}
+	return strconv.Itoa(uid)
+}
+
+// cpuTime — время процессора [ДД-]ЧЧ:ММ:СС
+func cpuTime(sec uint64) string {
+	s := fmt.Sprintf("%02d:%02d:%02d", sec/3600%24, sec/60%60, sec%60)
+	if days := sec / 86400; days > 0 {
+		s = fmt.Sprintf("%d-%s", days, s)
+	}
+	return s
+}
+
+// elapsedTime — время работы [[ДД-]ЧЧ:]ММ:СС
+func elapsedTime(sec uint64) string {
+	s := fmt.Sprintf("%02d:%02d", sec/60%60, sec%60)
+	switch {
+	case sec >= 86400:
+		s = fmt.Sprintf("%d-%02d:%s", sec/86400, sec/3600%24, s)
+	case sec >= 3600:
+		s = fmt.Sprintf("%02d:%s", sec/3600, s)
+	}
+	return s
+}
+
+// startTime — время запуска: ЧЧ:ММ за последние сутки, МесДД в этом году, иначе год
+func startTime(t, now time.Time) string {
+	switch {
+	case now.Sub(t) < 24*time.Hour:
+		return t.Format("15:04")
+	case t.Year() == now.Year():
+		return t.Format("Jan02")
+	}
+	return t.Format("2006")
+}
+
+// cpuPercent — доля процессора за время жизни процесса, в процентах
+func cpuPercent(p *procInfo, s *procSystem) float64 {
+	el := s.elapsed(p)
+	if el <= 0 {
+		return 0
+	}
+	return float64(p.utime+p.stime) / clockTicks * 100 / el
+}
@@ -0,0 +1,243 @@
+package minishell
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Вид перенаправления
+type RedirOp int
+
+const (
+	REDIR_IN         RedirOp = iota // N<file
+	REDIR_OUT                       // N>file
+	REDIR_APPEND                    // N>>file
+	REDIR_DUP                       // N>&M, N<&M — копия дескриптора M, N>&- — закрыть N
+	REDIR_HEREDOC                   // N<<DELIM — тело читается со следующих строк
+	REDIR_HERESTRING                // N<<<word
+)
+
+// Redirect — одно перенаправление дескриптора Fd
+type Redirect struct {
+	Fd        int
+	Op        RedirOp
+	Target    string // файл, номер дескриптора или "-", разделитель here-doc, слово here-string (до подстановок)
+	Body      string // тело here-doc в исходном виде
+	StripTabs bool   // <<- — убрать ведущие табуляции в теле
+	Quoted    bool   // разделитель here-doc в кавычках — тело не раскрывается
+}
+
+// scanRedirect — оператор перенаправления в начале s: [N]<, [N]>, [N]>>, [N]>|, [N]<<, [N]<<-, [N]<<<,
+// [N]>&, [N]<&, &>, &>>. Пустая строка — оператора нет
+func scanRedirect(s string) string {
+	j := 0
+	for j < len(s) && s[j] >= '0' && s[j] <= '9' {
+		j++
+	}
+
+	rest := s[j:]
+	if j == 0 && strings.HasPrefix(rest, "&>") {
+		if strings.HasPrefix(rest, "&>>") {
+			return "&>>"
+		}
+		return "&>"
+	}
+	if rest == "" || (rest[0] != '<' && rest[0] != '>') {
+		return ""
+	}
+
+	for _, op := range []string{"<<<", "<<-", "<<", "<&", ">>", ">&", ">|", "<", ">"} {
+		if strings.HasPrefix(rest, op) {
+			return s[:j] + op
+		}
+	}
+	return ""
+}
+
+// parseRedirect — перенаправления для оператора op и следующего за ним слова.
+// Подстановки в имени файла делаются при выполнении
+func parseRedirect(op string, word Token) ([]Redirect, error) {
+	j := 0
+	for j < len(op) && op[j] >= '0' && op[j] <= '9' {
+		j++
+	}
+	kind := op[j:]
+
+	fd := 1
+	if kind[0] == '<' {
+		fd = 0
+	}
+	if j > 0 {
+		n, err := strconv.Atoi(op[:j])
+		if err != nil {
+			return nil, fmt.Errorf("%s: bad file descriptor", op[:j])
+		}
+		fd = n
+	}
+
+	switch kind {
+	case "<":
+		return []Redirect{{Fd: fd, Op: REDIR_IN, Target: word.Raw}}, nil
+	case ">", ">|":
+		return []Redirect{{Fd: fd, Op: REDIR_OUT, Target: word.Raw}}, nil
+	case ">>":
+		return []Redirect{{Fd: fd, Op: REDIR_APPEND, Target: word.Raw}}, nil
+	case "<<", "<<-":
+		return []Redirect{{Fd: fd, Op: REDIR_HEREDOC, Target: word.Val, StripTabs: kind == "<<-", Quoted: word.Quoted}}, nil
+	case "<<<":
+		return []Redirect{{Fd: fd, Op: REDIR_HERESTRING, Target: word.Raw}}, nil
+	case "&>", "&>>":
+		out := REDIR_OUT
+		if kind == "&>>" {
+			out = REDIR_APPEND
+		}
+		return []Redirect{{Fd: 1, Op: out, Target: word.Raw}, {Fd: 2, Op: REDIR_DUP, Target: "1"}}, nil
+	}
+
+	// >& и <&: номер дескриптора или "-"; >&file без номера слева — то же, что &>file
+	if word.Val == "-" || isNumber(word.Val) {
+		return []Redirect{{Fd: fd, Op: REDIR_DUP, Target: word.Val}}, nil
+	}
+	if kind == ">&" && j == 0 {
+		return []Redirect{{Fd: 1, Op: REDIR_OUT, Target: word.Raw}, {Fd: 2, Op: REDIR_DUP, Target: "1"}}, nil
+	}
+	return nil, fmt.Errorf("%s: ambiguous redirect", word.Val)
+}
+
+// isNumber — строка из одних цифр
+func isNumber(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// pendingHeredocs — here-doc дерева в порядке записи
+func pendingHeredocs(n Node) []*Redirect {
+	var docs []*Redirect
+	walkRedirects(n, func(r *Redirect) {
+		if r.Op == REDIR_HEREDOC {
+			docs = append(docs, r)
+		}
+	})
+	return docs
+}
+
+// readHeredocs — читает из r тела here-doc до строк-разделителей; prompt печатается перед каждой строкой
+func (sh *Interpreter) readHeredocs(r lineReader, docs []*Redirect, prompt string) error {
+	for _, doc := range docs {
+		var body strings.Builder
+		for {
+			line, err := r.readLine(prompt)
+			if err != nil && !errors.Is(err, io.EOF) {
+				return err
+			}
+			eof := err != nil
+
+			if line != "" {
+				text := strings.TrimSuffix(line, "\n")
+				if doc.StripTabs {
+					text = strings.TrimLeft(text, "\t")
+				}
+				if text == doc.Target {
+					break
+				}
+				body.WriteString(text + "\n")
+			}
+
+			if eof {
+				fmt.Fprintf(sh.errOut(), "warning: here-document delimited by end-of-file (wanted `%s')\n", doc.Target)
+				break
+			}
+		}
+
+		doc.Body = body.String()
+	}
+	return nil
+}
+
+// stageFiles — таблица дескрипторов команды после её перенаправлений поверх base: fds[N] — файл для дескриптора N,
+// nil — дескриптор закрыт. opened — файлы, открытые здесь; их закрывают после запуска или завершения команды
+func (sh *Interpreter) stageFiles(base []*os.File, redirs []Redirect) (fds, opened []*os.File, err error) {
+	fds = append([]*os.File(nil), base...)
+
+	for _, rd := range redirs {
+		for len(fds) <= rd.Fd {
+			fds = append(fds, nil)
+		}
+
+		var f *os.File
+		switch rd.Op {
+		case REDIR_IN:
+			f, err = sh.openFile(sh.expandWord(rd.Target), os.O_RDONLY, 0)
+		case REDIR_OUT:
+			f, err = sh.openFile(sh.expandWord(rd.Target), os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+		case REDIR_APPEND:
+			f, err = sh.openFile(sh.expandWord(rd.Target), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+		case REDIR_HEREDOC:
+			body := rd.Body
+			if !rd.Quoted {
+				body = sh.expandEnv(body)
+			}
+			f, err = bodyReader(body)
+		case REDIR_HERESTRING:
+			f, err = bodyReader(sh.expandWord(rd.Target) + "\n")
+		case REDIR_DUP:
+			if rd.Target == "-" {
+				fds[rd.Fd] = nil
+				continue
+			}
+			src, _ := strconv.Atoi(rd.Target)
+			if src >= len(fds) || fds[src] == nil {
+				err = fmt.Errorf("%d: bad file descriptor", src)
+				break
+			}
+			fds[rd.Fd] = fds[src]
+			continue
+		}
+		if err == nil {
+			if _, err = sh.expansionFailed(); err != nil {
+				f.Close()
+			}
+		}
+
+		if err != nil {
+			closeFiles(opened)
+			return nil, nil, err
+		}
+		fds[rd.Fd] = f
+		opened = append(opened, f)
+	}
+
+	return fds, opened, nil
+}
+
+// bodyReader — читающий конец пайпа, в который в фоне пишется тело here-doc
+func bodyReader(body string) (*os.File, error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		_, _ = io.WriteString(w, body) // читатель может закрыть пайп не дочитав
+		w.Close()
+	}()
+	return r, nil
+}
+
+// fdFile — файл дескриптора fd или nil, если он закрыт
+func fdFile(fds []*os.File, fd int) *os.File {
+	if fd < len(fds) {
+		return fds[fd]
+	}
+	return nil
+}
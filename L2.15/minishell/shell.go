@@ -0,0 +1,952 @@
+package minishell
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// Тип токена — слово, &&, ||, |, &, ;, ;;, скобки, перенаправление, перевод строки
+type TokenKind int
+
+const (
+	TK_WORD    TokenKind = iota // обычное слово (команда или аргумент)
+	TK_AND                      // &&
+	TK_OR                       // ||
+	TK_PIPE                     // |
+	TK_AMP                      // & — запуск в фоне
+	TK_REDIR                    // оператор перенаправления: >, 2>>, <<, &>, 2>& ...
+	TK_SEMI                     // ;
+	TK_LPAREN                   // (
+	TK_RPAREN                   // )
+	TK_DSEMI                    // ;; — конец ветки case
+	TK_NEWLINE                  // перевод строки — разделитель команд, как ;
+)
+
+// Структура токена
+type Token struct {
+	Kind   TokenKind
+	Val    string // значение после снятия кавычек, без подстановок
+	Raw    string // исходный текст слова с кавычками, по нему делаются подстановки
+	Quoted bool   // в слове были кавычки или \
+	Pos    int    // начало токена в строке
+	End    int    // позиция после токена
+}
+
+// Одна команда (ls, echo ...) с перенаправлениями
+type CmdUnit struct {
+	Args   []string   // аргументы, включая имя команды, в исходном виде — подстановки делаются при запуске
+	Redirs []Redirect // перенаправления в порядке записи, применяются слева направо
+}
+
+// Main — командная строка minishell: args — аргументы без имени программы. Выполняет строку -c, скрипт
+// или читает команды со stdin (на терминале — интерактивно) и возвращает код выхода процесса
+func Main(args []string) int {
+	sh, err := NewInterpreter("", os.Environ())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	// флаги set перед командой или скриптом: minishell -eux script.sh
+	args, err = sh.parseShellFlags(args)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "minishell:", err)
+		return 2
+	}
+
+	switch {
+	// minishell -c 'команды' [имя [аргументы...]] — выполнить строку и выйти
+	case len(args) > 1 && args[0] == "-c":
+		if len(args) > 2 {
+			sh.scriptName, sh.positional = args[2], args[3:]
+		}
+		return sh.beforeExit(sh.runScript(&fileLines{bufio.NewReader(strings.NewReader(args[1]))}))
+
+	// minishell script.sh [аргументы...] — выполнить файл
+	case len(args) > 0:
+		f, err := os.Open(args[0])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 127
+		}
+		sh.scriptName, sh.positional = args[0], args[1:]
+		return sh.beforeExit(sh.runScript(&fileLines{bufio.NewReader(f)}))
+	}
+
+	sh.interactive = initJobControl()
+
+	// сам shell по Ctrl+C не завершается: SIGINT получает задание переднего плана —
+	// от терминала, как группа переднего плана, или как процесс из группы shell без управления заданиями.
+	// Интерактивный shell не завершается и по Ctrl+\ и kill, не останавливается по Ctrl+Z и при работе
+	// с терминалом из фона, а по SIGHUP рассылает его заданиям и завершается. signal.Ignore здесь не подходит:
+	// SIG_IGN наследуется через exec, и задания перестали бы реагировать на эти сигналы.
+	// Перехваченный сигнал в потомке сбрасывается в SIG_DFL
+	own := []syscall.Signal{syscall.SIGINT}
+	if sh.interactive {
+		own = append(own, syscall.SIGQUIT, syscall.SIGTERM, syscall.SIGHUP,
+			syscall.SIGTSTP, syscall.SIGTTIN, syscall.SIGTTOU)
+	}
+	sh.catchSignals(own...)
+
+	// на терминале — редактор строки с историей, иначе строки читаются как есть
+	sh.initPrompts()
+	var code int
+	if sh.interactive {
+		sh.sourceRcFile()
+		sh.history = loadHistory(sh.historyFile())
+		code, err = sh.runLines(&lineEditor{in: os.Stdin, out: os.Stdout, fd: ttyFd, hist: sh.history, sh: sh}, true)
+	} else {
+		code, err = sh.runLines(&fileLines{bufio.NewReader(os.Stdin)}, true)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+
+	if sh.flow != flowExit {
+		fmt.Println() // Ctrl+D
+		code = 0
+	}
+	return sh.beforeExit(code)
+}
+
+// runScript — выполняет скрипт и возвращает код завершения; синтаксическая ошибка печатается в stderr
+func (sh *Interpreter) runScript(r lineReader) int {
+	code, err := sh.runLines(r, false)
+	if err != nil {
+		fmt.Fprintln(sh.errOut(), err)
+	}
+	return code
+}
+
+// runLines — читает из r и выполняет команды до конца ввода или exit; prompt — интерактивный режим
+// с приглашениями и историей. Без prompt синтаксическая ошибка прекращает выполнение, как в скрипте,
+// и возвращается с кодом 2. Возвращает код завершения последней команды
+func (sh *Interpreter) runLines(r lineReader, prompt bool) (int, error) {
+	status := 0
+	in := &commandReader{sh: sh, r: r, prompt: prompt}
+	if prompt {
+		in.hist = sh.history
+	}
+
+	for {
+		// сообщаем о завершившихся фоновых заданиях перед приглашением
+		if sh.interactive {
+			sh.notifyJobs(sh.errOut())
+		} else {
+			sh.notifyJobs(io.Discard)
+		}
+		// сигналы, пришедшие во время чтения команды
+		if sh.handleSignals(); sh.flow == flowExit {
+			return sh.lastStatus, nil
+		}
+
+		// Команда целиком, со всеми строками продолжения и телами here-doc
+		list, err := in.next()
+		var serr *syntaxError
+		switch {
+		case errors.Is(err, io.EOF): // Ctrl+D
+			return status, nil
+		case errors.Is(err, errInterrupted): // Ctrl+C — набранная команда отбрасывается
+			status = 130
+			sh.lastStatus = status
+			continue
+		case errors.Is(err, errNoEvent):
+			fmt.Fprintln(sh.errOut(), err)
+			continue
+		case errors.As(err, &serr):
+			status = 2
+			sh.lastStatus = status
+			if !prompt {
+				return status, serr
+			}
+			fmt.Fprintln(sh.errOut(), serr)
+			continue
+		case err != nil:
+			return status, fmt.Errorf("read error: %w", err)
+		}
+
+		if len(list.Items) > 0 {
+			status = sh.runList(list, sh.stdFiles())
+		}
+		// exit или return в файле source
+		if sh.flow == flowReturn || sh.flow == flowExit {
+			return status, nil
+		}
+	}
+}
+
+// isSpace — проверка пробела
+func isSpace(b byte) bool {
+	return b == ' ' || b == '\t'
+}
+
+// errUnterminated — кавычка не закрыта до конца текста
+var errUnterminated = errors.New("unterminated quote")
+
+// tokenize — разбивает текст на токены: слова, |, ||, &&, &, ;, ;;, перенаправления, переводы строк.
+// Комментарии # пропускаются, \ перед переводом строки склеивает строки
+func tokenize(line string) ([]Token, error) {
+	var toks []Token
+	i := 0
+	n := len(line)
+
+	for i < n {
+
+		if isSpace(line[i]) {
+			i++
+			continue
+		}
+
+		// продолжение на следующей строке
+		if line[i] == '\\' && i+1 < n && line[i+1] == '\n' {
+			if i+2 == n {
+				return nil, errIncomplete
+			}
+			i += 2
+			continue
+		}
+
+		// комментарий до конца строки
+		if line[i] == '#' {
+			for i < n && line[i] != '\n' {
+				i++
+			}
+			continue
+		}
+
+		if line[i] == '\n' {
+			toks = append(toks, Token{Kind: TK_NEWLINE, Val: "\n", Pos: i, End: i + 1})
+			i++
+			continue
+		}
+
+		if strings.HasPrefix(line[i:], ";;") {
+			toks = append(toks, Token{Kind: TK_DSEMI, Val: ";;", Pos: i, End: i + 2})
+			i += 2
+			continue
+		}
+
+		// && и || операторы
+		if i+1 < n && (line[i] == '&' || line[i] == '|') && line[i+1] == line[i] {
+			kind := TK_AND
+			if line[i] == '|' {
+				kind = TK_OR
+			}
+			toks = append(toks, Token{Kind: kind, Val: line[i : i+2], Pos: i, End: i + 2})
+			i += 2
+			continue
+		}
+
+		// перенаправления: 2>, >>, <<, &>, 2>&1 ...
+		if op := scanRedirect(line[i:]); op != "" {
+			toks = append(toks, Token{Kind: TK_REDIR, Val: op, Pos: i, End: i + len(op)})
+			i += len(op)
+			continue
+		}
+
+		// односимвольные операторы | & ; ( )
+		if kind, ok := singleOps[line[i]]; ok {
+			toks = append(toks, Token{Kind: kind, Val: line[i : i+1], Pos: i, End: i + 1})
+			i++
+			continue
+		}
+
+		// Слово: обычные символы, кавычки и \ до пробела или оператора
+		tok, next, err := scanWord(line, i)
+		if err != nil {
+			return nil, err
+		}
+		toks = append(toks, tok)
+		i = next
+	}
+	return toks, nil
+}
+
+// Односимвольные операторы
+var singleOps = map[byte]TokenKind{
+	'|': TK_PIPE,
+	'&': TK_AMP,
+	';': TK_SEMI,
+	'(': TK_LPAREN,
+	')': TK_RPAREN,
+}
+
+// isOperatorStart — символ, с которого начинается оператор и на котором заканчивается слово
+func isOperatorStart(b byte) bool {
+	_, ok := singleOps[b]
+	return ok || b == '<' || b == '>'
+}
+
+// scanWord — читает слово, начинающееся в line[i]: соседние части в кавычках склеиваются
+// ("a"'b'c — одно слово), \ экранирует следующий символ. Возвращает токен и позицию после слова
+func scanWord(line string, i int) (Token, int, error) {
+	var val strings.Builder
+	start := i
+	quoted := false
+	n := len(line)
+
+	for i < n && !isSpace(line[i]) && !isOperatorStart(line[i]) && line[i] != '\n' {
+		switch line[i] {
+		case '\\':
+			if i+1 < n && line[i+1] == '\n' { // продолжение строки
+				if i+2 == n {
+					return Token{}, 0, errIncomplete
+				}
+				i += 2
+				continue
+			}
+			quoted = true
+			if i+1 < n {
+				val.WriteByte(line[i+1])
+			}
+			i += 2
+
+		case '\'':
+			quoted = true
+			end := strings.IndexByte(line[i+1:], '\'')
+			if end < 0 {
+				return Token{}, 0, errUnterminated
+			}
+			val.WriteString(line[i+1 : i+1+end])
+			i += end + 2
+
+		case '"':
+			quoted = true
+			i++
+			for i < n && line[i] != '"' {
+				if line[i] == '\\' && i+1 < n && line[i+1] == '\n' {
+					i += 2
+					continue
+				}
+				if k, err := scanSubst(line, i); k > 0 || err != nil {
+					if err != nil {
+						return Token{}, 0, err
+					}
+					val.WriteString(line[i:k])
+					i = k
+					continue
+				}
+				// внутри "..." \ экранирует только $ ` " \
+				if line[i] == '\\' && i+1 < n && strings.IndexByte("$`\"\\", line[i+1]) >= 0 {
+					i++
+				}
+				val.WriteByte(line[i])
+				i++
+			}
+			if i >= n {
+				return Token{}, 0, errUnterminated
+			}
+			i++
+
+		case '$', '`':
+			k, err := scanSubst(line, i)
+			if err != nil {
+				return Token{}, 0, err
+			}
+			if k == 0 {
+				k = i + 1
+			}
+			val.WriteString(line[i:k])
+			i = k
+
+		default:
+			val.WriteByte(line[i])
+			i++
+		}
+	}
+
+	if i > n { // \ в конце строки
+		i = n
+	}
+	return Token{Kind: TK_WORD, Val: val.String(), Raw: line[start:i], Quoted: quoted, Pos: start, End: i}, i, nil
+}
+
+// scanSubst — подстановка ${...}, $(...), $((...)) или `...` с позиции i читается целиком,
+// вместе с пробелами, кавычками и операторами внутри. Возвращает позицию после неё, 0 — здесь нет подстановки
+func scanSubst(line string, i int) (int, error) {
+	j := substEnd(line, i)
+	if j < 0 {
+		return 0, errIncomplete
+	}
+	return j, nil
+}
+
+// isAlnum — буква или цифра
+func isAlnum(b byte) bool {
+	return (b >= '0' && b <= '9') ||
+		(b >= 'a' && b <= 'z') ||
+		(b >= 'A' && b <= 'Z')
+}
+
+// reportError — печатает ошибку запуска в stderr из fds; при ошибке код не бывает нулевым
+func reportError(code int, err error, fds []*os.File) int {
+	if err == nil {
+		return code
+	}
+	if errOut := fdFile(fds, 2); errOut != nil {
+		fmt.Fprintln(errOut, "exec error:", err)
+	}
+	if code == 0 {
+		code = 1
+	}
+	return code
+}
+
+// runList — выполняет список команд; код — последней команды, запуск в фоне даёт 0.
+// break, continue и return прерывают список
+func (sh *Interpreter) runList(list *ListNode, fds []*os.File) int {
+	status := 0
+	for _, item := range list.Items {
+		// отмена Run прекращает выполнение, как exit
+		if sh.ctx.Err() != nil {
+			sh.flow = flowExit
+			break
+		}
+		// set -n — дальше команды только разбираются; интерактивный shell его не учитывает
+		if sh.setOptions["noexec"] && !sh.interactive {
+			break
+		}
+
+		if item.Background {
+			status = sh.runBackground(item, fds)
+		} else {
+			status = sh.runNode(item.Node, fds)
+		}
+		sh.lastStatus = status
+		sh.handleSignals()
+
+		if sh.flow != flowNone {
+			break
+		}
+	}
+	return status
+}
+
+// runNode — выполняет узел дерева с таблицей дескрипторов fds и возвращает код завершения
+func (sh *Interpreter) runNode(n Node, fds []*os.File) int {
+	switch n := n.(type) {
+	case *ListNode:
+		return sh.runList(n, fds)
+
+	case *AndOrNode:
+		status := sh.ignoreErrexit(func() int { return sh.runNode(n.Left, fds) })
+		// && — правая часть только после успеха, || — только после неудачи
+		if sh.flow == flowNone && (n.Op == TK_AND) == (status == 0) {
+			status = sh.runNode(n.Right, fds)
+		}
+		return status
+
+	case *PipelineNode:
+		// time замеряет конвейер целиком, вместе с !
+		var report func()
+		if n.Time {
+			report = sh.startTiming(n, fds)
+		}
+		run := func() int {
+			code, err := sh.runPipeline(n, fds)
+			return reportError(code, err, fds)
+		}
+		// внутри конвейера с ! set -e не действует
+		var code int
+		if n.Negate {
+			code = sh.ignoreErrexit(run)
+		} else {
+			code = run()
+		}
+		if len(n.Cmds) == 1 {
+			sh.pipeStatus = []int{code}
+		}
+		// ! инвертирует код конвейера, PIPESTATUS остаётся как есть; код exit не инвертируется
+		if n.Negate && sh.flow != flowExit {
+			if code == 0 {
+				code = 1
+			} else {
+				code = 0
+			}
+		}
+		if report != nil {
+			report()
+		}
+		sh.lastStatus = code
+		sh.checkFailure(n, code)
+		return sh.lastStatus
+	}
+
+	return reportError(1, fmt.Errorf("unknown node %T", n), fds)
+}
+
+// runPipeline — конвейер из одной команды выполняется в текущем shell (кроме подоболочки),
+// из нескольких — заданием
+func (sh *Interpreter) runPipeline(pipe *PipelineNode, fds []*os.File) (int, error) {
+	if len(pipe.Cmds) == 0 { // time без команды
+		return 0, nil
+	}
+	if len(pipe.Cmds) > 1 {
+		return sh.runJob(pipe.Cmds, fds, false, pipe.Src)
+	}
+
+	switch c := pipe.Cmds[0].(type) {
+	case *CmdUnit:
+		return sh.runSingle(c, fds)
+	case *Subshell:
+		if sh.interactive {
+			return sh.runJob(pipe.Cmds, fds, false, pipe.Src)
+		}
+		code, err := sh.inlineSubshell(c.Body, c.Redirs, fds)
+		sh.pipeStatus = []int{code}
+		return code, err
+	case *FuncDef:
+		sh.functions[c.Name] = c
+		return 0, nil
+	case compoundNode:
+		return sh.runCompound(c, fds)
+	}
+	return 1, fmt.Errorf("unknown command %T", pipe.Cmds[0])
+}
+
+// runSingle — выполнение одиночной команды (присваивания, функция, builtin или внешняя)
+func (sh *Interpreter) runSingle(unit *CmdUnit, fds []*os.File) (int, error) {
+	sh.substStatus = 0
+	assigns, words := sh.splitAssignments(unit.Args)
+	args := sh.expandArgs(words)
+	if code, err := sh.expansionFailed(); err != nil {
+		return code, err
+	}
+
+	// только присваивания: переменные остаются в shell, перенаправления всё равно выполняются.
+	// Код — последней подстановки команды в значениях
+	if len(args) == 0 {
+		_, opened, err := sh.stageFiles(fds, unit.Redirs)
+		closeFiles(opened)
+		if err != nil {
+			return 1, err
+		}
+		sh.trace(fds, assigns, nil)
+		sh.assignVars(assigns)
+		return sh.substStatus, nil
+	}
+
+	// command name args — в обход функций. Внешние команды трассирует runJob
+	traced := args
+	rest, noFunctions := commandPrefix(args)
+	if noFunctions {
+		args = rest
+	}
+
+	if fn, ok := sh.functions[args[0]]; ok && !noFunctions {
+		sh.trace(fds, assigns, traced)
+		return sh.withAssignments(assigns, func() (int, error) {
+			return sh.callFunction(fn, args[1:], unit.Redirs, fds)
+		})
+	}
+
+	if sh.isBuiltin(args[0]) {
+		sh.trace(fds, assigns, traced)
+		cmdFds, opened, err := sh.stageFiles(fds, unit.Redirs)
+		if err != nil {
+			return 1, err
+		}
+		defer closeFiles(opened)
+
+		// ошибку builtin пишем туда, куда перенаправлен его stderr
+		code, err := sh.withAssignments(assigns, func() (int, error) {
+			return sh.runBuiltin(args[0], args[1:], fdFile(cmdFds, 0), fdFile(cmdFds, 1))
+		})
+		return reportError(code, err, cmdFds), nil
+	}
+
+	// слова уже раскрыты — задание получает их в кавычках, чтобы не раскрывать второй раз
+	expanded := &CmdUnit{Args: quoteCommand(assigns, args), Redirs: unit.Redirs}
+	return sh.runJob([]Node{expanded}, fds, false, strings.Join(unit.Args, " "))
+}
+
+// runBackground — запускает элемент списка фоновым заданием: конвейер простых команд как есть,
+// остальное (&&, ||, составные команды, time) — целиком в подоболочке
+func (sh *Interpreter) runBackground(item *ListItem, fds []*os.File) int {
+	stages := []Node{&Subshell{Body: &ListNode{Items: []*ListItem{{Node: item.Node, Src: item.Src}}}}}
+
+	if pipe, ok := item.Node.(*PipelineNode); ok && allSimple(pipe.Cmds) && !pipe.Time {
+		stages = pipe.Cmds
+	}
+
+	code, err := sh.runJob(stages, fds, true, item.Src)
+	return reportError(code, err, fds)
+}
+
+// allSimple — все команды конвейера простые
+func allSimple(cmds []Node) bool {
+	for _, c := range cmds {
+		if _, ok := c.(*CmdUnit); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// runJob — запускает конвейер как задание cmd: внешние команды и подоболочки — процессами
+// (с управлением заданиями в одной группе), builtin — в горутинах. Составные команды и функции
+// в конвейере выполняются в подоболочках. Ошибка запуска стадии печатается в её stderr и даёт ей код
+// 127, 126 или 1, остальные стадии работают дальше.
+// На переднем плане ждёт завершения или остановки задания; код — как у Job.exitCode, коды стадий — в PIPESTATUS
+func (sh *Interpreter) runJob(stages []Node, fds []*os.File, background bool, cmd string) (int, error) {
+	n := len(stages)
+
+	job := &Job{Cmd: cmd, Background: background, Codes: make([]int, n)}
+
+	var wg sync.WaitGroup
+	var nextIn *os.File            // читающий конец пайпа для следующей стадии
+	builtinCodes := make([]int, n) // коды builtin в горутинах; в задание переносятся после их завершения
+
+	// fail — стадия i не запускается, остальные работают дальше. Ошибка пишется в stderr стадии
+	fail := func(i, code int, err error, owned, fds []*os.File) {
+		closeFiles(owned)
+		job.Codes[i] = reportError(code, err, fds)
+	}
+
+	for i, stage := range stages {
+		base := append([]*os.File(nil), fds...)
+		var owned []*os.File // дескрипторы стадии, которые закрываются после её запуска или завершения
+
+		// stdin
+		if i > 0 {
+			base[0] = nextIn
+			owned = append(owned, nextIn)
+		}
+
+		// stdout. Без пайпа эта и следующие стадии не запускаются, уже запущенные дожидаемся:
+		// закрытый читающий конец завершит предыдущую стадию по SIGPIPE
+		if i < n-1 {
+			r, w, err := os.Pipe()
+			if err != nil {
+				fail(i, 1, err, owned, base)
+				for j := i + 1; j < n; j++ {
+					job.Codes[j] = 1
+				}
+				break
+			}
+			base[1], nextIn = w, r
+			owned = append(owned, w)
+		}
+
+		var args, assigns, env []string
+		var redirs []Redirect
+		var sub Node // что выполнить в подоболочке; перенаправления тогда применяет она сама
+
+		switch st := stage.(type) {
+		case *CmdUnit:
+			assigns, args = sh.splitAssignments(st.Args)
+			args = sh.expandArgs(args)
+			if code, err := sh.expansionFailed(); err != nil {
+				fail(i, code, err, owned, base)
+				continue
+			}
+
+			// функции, присваивания без команды и builtin, которые меняют shell (cd, exit, export...), —
+			// в подоболочке: конвейер не должен менять сам shell. Добавленные через Register в подоболочке
+			// не найти — они, как и pipeBuiltins, работают в горутине с копией интерпретатора
+			if len(args) == 0 || sh.functions[args[0]] != nil ||
+				sh.isBuiltin(args[0]) && !pipeBuiltins[args[0]] && sh.builtins[args[0]] == nil {
+				sub = &PipelineNode{Cmds: []Node{&CmdUnit{Args: quoteCommand(assigns, args), Redirs: st.Redirs}}}
+			} else {
+				redirs, env = st.Redirs, sh.environ(assigns)
+				sh.trace(fds, assigns, args) // подоболочка трассирует себя сама
+			}
+		case *Subshell:
+			sub, redirs = st.Body, st.Redirs
+		default:
+			sub = &PipelineNode{Cmds: []Node{st}}
+		}
+
+		// перенаправления применяются к каждой стадии поверх пайпов
+		stageFds, opened, err := sh.stageFiles(base, redirs)
+		owned = append(owned, opened...)
+		if err != nil {
+			fail(i, 1, err, owned, base)
+			continue
+		}
+
+		if sub == nil && sh.isBuiltin(args[0]) {
+			// builtin работает параллельно с shell — на его копии. Встроенным из pipeBuiltins нужны задания
+			// и история самого shell: таблица заданий общая, история — снимок на момент запуска
+			copied := sh.subshell()
+			copied.inPipeline = true
+			if sh.builtins[args[0]] == nil {
+				copied.jobs, copied.history = sh.jobs, sh.history.snapshot()
+			}
+			run := func() (int, error) {
+				return copied.withAssignments(assigns, func() (int, error) {
+					return copied.runBuiltin(args[0], args[1:], fdFile(stageFds, 0), fdFile(stageFds, 1))
+				})
+			}
+
+			wg.Add(1)
+			go func(i int, fds, owned []*os.File) {
+				defer wg.Done()
+				code, err := run()
+				builtinCodes[i] = reportError(code, err, fds)
+				closeFiles(owned) // закрытие пишущего конца даёт следующей стадии EOF
+			}(i, stageFds, owned)
+			continue
+		}
+
+		var pid int
+		if sub != nil {
+			pid, err = sh.startSubshell(sub, stageFds, job.Pgid, !background)
+		} else {
+			pid, err = sh.startProcess(args, stageFds, env, job.Pgid, !background)
+		}
+		closeFiles(owned) // у потомка свои копии дескрипторов
+		if err != nil {
+			fail(i, startErrorCode(err), err, nil, stageFds)
+			continue
+		}
+
+		if sh.interactive && job.Pgid == 0 {
+			job.Pgid = pid
+		}
+		job.Procs = append(job.Procs, &Process{Pid: pid, Stage: i})
+	}
+
+	// collect — коды builtin переносятся в задание, когда их горутины завершились
+	collect := func() {
+		wg.Wait()
+		for i, code := range builtinCodes {
+			if code != 0 {
+				job.Codes[i] = code
+			}
+		}
+		sh.pipeStatus = job.stageCodes()
+	}
+
+	if len(job.Procs) == 0 { // внешних команд нет или ни одна не запустилась
+		collect()
+		return job.exitCode(sh.setOptions["pipefail"]), nil
+	}
+
+	if background {
+		sh.lastBgPid = job.Procs[len(job.Procs)-1].Pid
+		sh.jobs.add(job)
+		if sh.interactive {
+			fmt.Fprintf(sh.errOut(), "[%d] %d\n", job.ID, job.Procs[len(job.Procs)-1].Pid)
+		}
+		return 0, nil
+	}
+
+	sh.foreground(job)
+
+	// ждём builtin; остановленное задание так и остаётся остановленным
+	collect()
+	return job.exitCode(sh.setOptions["pipefail"]), nil
+}
+
+// closeFiles — закрывает дескрипторы
+func closeFiles(files []*os.File) {
+	for _, f := range files {
+		f.Close()
+	}
+}
+
+// pipeBuiltins — builtin, которые в конвейере и в фоне выполняются горутиной с копией shell, а не подоболочкой:
+// они только читают его состояние, а jobs и history должны видеть задания и историю этого shell
+var pipeBuiltins = map[string]bool{
+	"echo": true, "pwd": true, "jobs": true, "ps": true, "kill": true, "history": true,
+	"test": true, "[": true, "true": true, "false": true, ":": true, "type": true, "which": true,
+}
+
+// isBuiltin — проверяет встроенные команды, в том числе добавленные через Register
+func (sh *Interpreter) isBuiltin(name string) bool {
+	return slices.Contains(builtinNames, name) || sh.builtins[name] != nil
+}
+
+// builtinNames — встроенные команды
+var builtinNames = []string{
+	"cd", "pwd", "echo", "kill", "ps", "exit", "jobs", "fg", "bg", "break", "continue", "return",
+	"export", "unset", "set", "shopt", "history", "source", ".", "alias", "unalias", "type", "command",
+	"which", "read", "test", "[", "printf", "true", "false", ":", "umask", "trap", "wait",
+}
+
+// runBuiltin — запуск встроенных команд; добавленные через Register важнее одноимённых встроенных
+func (sh *Interpreter) runBuiltin(name string, args []string, in io.Reader, out io.Writer) (int, error) {
+	if fn := sh.builtins[name]; fn != nil {
+		return fn(sh, args, in, out)
+	}
+
+	switch name {
+
+	case "cd":
+		return sh.builtinCd(args, out)
+
+	case "pwd":
+		return sh.builtinPwd(out)
+
+	case "echo":
+		return builtinEcho(args, out)
+
+	case "kill":
+		return sh.builtinKill(args, out)
+
+	case "ps":
+		return builtinPs(args, out)
+
+	case "jobs":
+		return sh.builtinJobs(args, out)
+
+	case "fg":
+		return sh.builtinFg(args, out)
+
+	case "bg":
+		return sh.builtinBg(args, out)
+
+	case "break", "continue":
+		return sh.builtinLoopControl(name, args)
+
+	case "return":
+		return sh.builtinReturn(args)
+
+	case "export":
+		return sh.builtinExport(args, out)
+
+	case "unset":
+		return sh.builtinUnset(args)
+
+	case "set":
+		return sh.builtinSet(args, out)
+
+	case "shopt":
+		return sh.builtinShopt(args, out)
+
+	case "history":
+		return sh.builtinHistory(args, out)
+
+	case "source", ".":
+		return sh.builtinSource(name, args)
+
+	case "alias":
+		return sh.builtinAlias(args, out)
+
+	case "unalias":
+		return sh.builtinUnalias(args)
+
+	case "type":
+		return sh.builtinType(args, out)
+
+	case "command":
+		return sh.builtinCommand(args, out)
+
+	case "which":
+		return sh.builtinWhich(args, out)
+
+	case "read":
+		return sh.builtinRead(args, in)
+
+	case "test", "[":
+		return sh.builtinTest(name, args)
+
+	case "printf":
+		return sh.builtinPrintf(args, out)
+
+	case "true", ":":
+		return 0, nil
+
+	case "false":
+		return 1, nil
+
+	case "umask":
+		return builtinUmask(args, out)
+
+	case "trap":
+		return sh.builtinTrap(args, out)
+
+	case "wait":
+		return sh.builtinWait(args)
+
+	case "exit":
+		return sh.builtinExit(args)
+	}
+
+	return 1, fmt.Errorf("unknown builtin %s", name)
+}
+
+// builtinCd — смена директории: без аргументов — в HOME, cd - — в OLDPWD с выводом нового каталога.
+// PWD и OLDPWD обновляются
+func (sh *Interpreter) builtinCd(args []string, out io.Writer) (int, error) {
+	target := ""
+
+	switch {
+	case len(args) == 0:
+		target, _ = sh.lookupVar("HOME")
+		if target == "" {
+			target = "/"
+		}
+	case args[0] == "-":
+		var ok bool
+		if target, ok = sh.lookupVar("OLDPWD"); !ok || target == "" {
+			return 1, fmt.Errorf("cd: OLDPWD not set")
+		}
+	default:
+		target = args[0]
+	}
+
+	// путь логический: .. убирает последний компонент, символические ссылки не раскрываются
+	dir := filepath.Clean(sh.abs(target))
+	if err := enterable(dir); err != nil {
+		return 1, &fs.PathError{Op: "chdir", Path: target, Err: err}
+	}
+
+	old := sh.dir
+	sh.dir = dir
+	sh.setVar("OLDPWD", old)
+	sh.setVar("PWD", dir)
+	if len(args) > 0 && args[0] == "-" {
+		fmt.Fprintln(out, dir)
+	}
+	return 0, nil
+}
+
+// enterable — в каталог можно перейти: он существует, это каталог и на него есть право поиска
+func enterable(dir string) error {
+	var st syscall.Stat_t
+	if err := syscall.Stat(dir, &st); err != nil {
+		return err
+	}
+	if st.Mode&syscall.S_IFMT != syscall.S_IFDIR {
+		return syscall.ENOTDIR
+	}
+	const searchOK = 1 // X_OK
+	return syscall.Access(dir, searchOK)
+}
+
+// builtinExit — exit [N]: завершает shell с кодом N, без аргумента — с кодом последней команды.
+// Выполнение раскручивается до runLines, процесс завершает main
+func (sh *Interpreter) builtinExit(args []string) (int, error) {
+	code := sh.lastStatus
+	switch {
+	case len(args) > 1:
+		return 1, fmt.Errorf("exit: too many arguments")
+	case len(args) == 1:
+		n, err := strconv.Atoi(args[0])
+		if err != nil {
+			fmt.Fprintf(sh.errOut(), "exit: %s: numeric argument required\n", args[0])
+			n = 2
+		}
+		code = n & 0xff
+	}
+	sh.flow = flowExit
+	return code, nil
+}
+
+// builtinPwd — вывод текущей директории
+func (sh *Interpreter) builtinPwd(out io.Writer) (int, error) {
+	fmt.Fprintln(out, sh.dir)
+	return 0, nil
+}
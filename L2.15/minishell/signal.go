@@ -0,0 +1,204 @@
+package minishell
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// signalNames — имена сигналов без SIG в порядке номеров
+var signalNames = []struct {
+	name string
+	sig  syscall.Signal
+}{
+	{"HUP", syscall.SIGHUP}, {"INT", syscall.SIGINT}, {"QUIT", syscall.SIGQUIT}, {"ILL", syscall.SIGILL},
+	{"TRAP", syscall.SIGTRAP}, {"ABRT", syscall.SIGABRT}, {"BUS", syscall.SIGBUS}, {"FPE", syscall.SIGFPE},
+	{"KILL", syscall.SIGKILL}, {"USR1", syscall.SIGUSR1}, {"SEGV", syscall.SIGSEGV}, {"USR2", syscall.SIGUSR2},
+	{"PIPE", syscall.SIGPIPE}, {"ALRM", syscall.SIGALRM}, {"TERM", syscall.SIGTERM}, {"STKFLT", syscall.SIGSTKFLT},
+	{"CHLD", syscall.SIGCHLD}, {"CONT", syscall.SIGCONT}, {"STOP", syscall.SIGSTOP}, {"TSTP", syscall.SIGTSTP},
+	{"TTIN", syscall.SIGTTIN}, {"TTOU", syscall.SIGTTOU}, {"URG", syscall.SIGURG}, {"XCPU", syscall.SIGXCPU},
+	{"XFSZ", syscall.SIGXFSZ}, {"VTALRM", syscall.SIGVTALRM}, {"PROF", syscall.SIGPROF}, {"WINCH", syscall.SIGWINCH},
+	{"IO", syscall.SIGIO}, {"PWR", syscall.SIGPWR}, {"SYS", syscall.SIGSYS},
+}
+
+// parseSignal — сигнал по номеру или имени: 9, KILL, SIGKILL, kill
+func parseSignal(spec string) (syscall.Signal, error) {
+	if n, err := strconv.Atoi(spec); err == nil {
+		if n >= 0 && n < 65 {
+			return syscall.Signal(n), nil
+		}
+		return 0, fmt.Errorf("%s: invalid signal specification", spec)
+	}
+	name := strings.TrimPrefix(strings.ToUpper(spec), "SIG")
+	for _, s := range signalNames {
+		if s.name == name {
+			return s.sig, nil
+		}
+	}
+	return 0, fmt.Errorf("%s: invalid signal specification", spec)
+}
+
+// signalName — имя сигнала без SIG; неизвестный — номер
+func signalName(sig syscall.Signal) string {
+	for _, s := range signalNames {
+		if s.sig == sig {
+			return s.name
+		}
+	}
+	return strconv.Itoa(int(sig))
+}
+
+// builtinKill — kill [-s сигнал | -n номер | -сигнал] pid | %задание ...: посылает сигнал (по умолчанию TERM)
+// процессам и заданиям; отрицательный pid после -- — группе процессов.
+// kill -l — список сигналов, kill -l сигнал|код — имя по номеру или коду завершения и номер по имени
+func (sh *Interpreter) builtinKill(args []string, out io.Writer) (int, error) {
+	const usage = "kill: usage: kill [-s sigspec | -n signum | -sigspec] pid | jobspec ... or kill -l [sigspec]"
+	sig := syscall.SIGTERM
+
+	if len(args) > 0 {
+		switch a := args[0]; {
+		case a == "-l" || a == "-L":
+			return listSignals(args[1:], out)
+		case a == "-s" || a == "-n":
+			if len(args) < 2 {
+				return 2, fmt.Errorf("kill: %s: option requires an argument", a)
+			}
+			s, err := parseSignal(args[1])
+			if err != nil {
+				return 1, fmt.Errorf("kill: %w", err)
+			}
+			sig, args = s, args[2:]
+		case a == "--":
+			args = args[1:]
+		case len(a) > 1 && a[0] == '-':
+			s, err := parseSignal(a[1:])
+			if err != nil {
+				return 1, fmt.Errorf("kill: %w", err)
+			}
+			sig, args = s, args[1:]
+		}
+	}
+	if len(args) > 0 && args[0] == "--" {
+		args = args[1:]
+	}
+	if len(args) == 0 {
+		return 2, errors.New(usage)
+	}
+
+	var errs error
+	self := false // сигнал дошёл до самого shell
+	for _, target := range args {
+		if err := sh.signalTarget(target, sig); err != nil {
+			errs = errors.Join(errs, fmt.Errorf("kill: %w", err))
+			continue
+		}
+		self = self || signalsSelf(target)
+	}
+	code := 0
+	if errs != nil {
+		code = 1
+	}
+
+	// перехваченный сигнал себе обрабатывается до следующей команды, $? в ловушке — код kill.
+	// builtin в конвейере работает параллельно с shell — тогда сигнал обработается после конвейера
+	if self && !sh.inPipeline && sh.catches(sig) {
+		sh.lastStatus = code
+		sh.awaitSignal(sig)
+	}
+	return code, errs
+}
+
+// signalTarget — посылает сигнал процессу, группе (-pgid) или заданию (%spec).
+// Остановленное задание после TERM или HUP продолжается, чтобы сигнал дошёл
+func (sh *Interpreter) signalTarget(target string, sig syscall.Signal) error {
+	if strings.HasPrefix(target, "%") {
+		j, err := sh.jobs.find(target)
+		if err != nil {
+			return err
+		}
+		if err := j.signal(sig); err != nil {
+			return fmt.Errorf("%s: %w", target, err)
+		}
+		if j.State() == JobStopped && (sig == syscall.SIGTERM || sig == syscall.SIGHUP) {
+			return j.continueJob()
+		}
+		return nil
+	}
+
+	pid, err := strconv.Atoi(target)
+	if err != nil {
+		return fmt.Errorf("%s: arguments must be process or job IDs", target)
+	}
+	kill := syscall.Kill
+	if pid == os.Getpid() {
+		kill = func(int, syscall.Signal) error { return raise(sig) }
+	}
+	if err := kill(pid, sig); err != nil {
+		return fmt.Errorf("(%d) - %w", pid, err)
+	}
+	return nil
+}
+
+// raise — посылает сигнал потоку, который его вызвал. Такой сигнал обрабатывается до возврата из tgkill:
+// с действием по умолчанию процесс завершается здесь же, как после kill себе в bash. Сигнал процессу
+// Go получает в каком-нибудь другом потоке, и shell успел бы выполнить ещё несколько команд
+func raise(sig syscall.Signal) error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	return syscall.Tgkill(os.Getpid(), syscall.Gettid(), sig)
+}
+
+// signalsSelf — kill target доставляет сигнал самому процессу shell: его pid, 0 или -pgid его группы
+func signalsSelf(target string) bool {
+	pid, err := strconv.Atoi(target)
+	return err == nil && (pid == os.Getpid() || pid == 0 || pid == -syscall.Getpgrp())
+}
+
+// listSignals — kill -l: без аргументов таблица номеров и имён, иначе имя по номеру
+// (код завершения больше 128 — сигнал, которым процесс убит) или номер по имени
+func listSignals(args []string, out io.Writer) (int, error) {
+	if len(args) == 0 {
+		var b strings.Builder
+		for i, s := range signalNames {
+			fmt.Fprintf(&b, "%2d) SIG%-7s", int(s.sig), s.name)
+			if i%5 == 4 || i == len(signalNames)-1 {
+				b.WriteString("\n")
+			} else {
+				b.WriteString("\t")
+			}
+		}
+		io.WriteString(out, b.String())
+		return 0, nil
+	}
+
+	var errs error
+	for _, a := range args {
+		if n, err := strconv.Atoi(a); err == nil {
+			if n > 128 {
+				n -= 128
+			}
+			name := signalName(syscall.Signal(n))
+			if name == strconv.Itoa(n) {
+				errs = errors.Join(errs, fmt.Errorf("kill: %s: invalid signal specification", a))
+				continue
+			}
+			fmt.Fprintln(out, name)
+			continue
+		}
+		sig, err := parseSignal(a)
+		if err != nil {
+			errs = errors.Join(errs, fmt.Errorf("kill: %w", err))
+			continue
+		}
+		fmt.Fprintln(out, int(sig))
+	}
+	if errs != nil {
+		return 1, errs
+	}
+	return 0, nil
+}
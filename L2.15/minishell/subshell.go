@@ -0,0 +1,254 @@
+package minishell
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// subshellState — что подоболочка получает от родителя: команду, функции, неэкспортированные переменные,
+// настройки shopt и set -o, алиасы, параметры, контекст set -e и set -x, игнорируемые сигналы и имена
+// builtin из Register. Экспортированные переменные приходят с окружением, остальные ловушки в подоболочке
+// сняты, как в bash
+type subshellState struct {
+	Node       Node
+	Functions  map[string]*FuncDef
+	Vars       map[string]string
+	Options    map[string]bool
+	SetOptions map[string]bool
+	Aliases    map[string]string
+	Positional []string
+	ScriptName string
+	ShellPid   int
+	LastStatus int
+	LastBgPid  int
+	NoErrexit  bool // подоболочка в условии: set -e в ней не действует
+	TraceLevel int
+	Ignored    []string // сигналы с ловушкой '': Go в подоболочке ставит им свои обработчики вместо SIG_IGN
+	Registered []string // builtin из Register: сами функции в другой процесс не передать
+}
+
+// subshellFlag и subshellEnv — подоболочка-процесс запускается с аргументами --subshell N
+// и переменной окружения MINISHELL_SUBSHELL
+const (
+	subshellFlag = "--subshell"
+	subshellEnv  = "MINISHELL_SUBSHELL"
+)
+
+func init() {
+	// узлы дерева лежат в полях-интерфейсах
+	for _, n := range []Node{
+		&ListNode{}, &AndOrNode{}, &PipelineNode{}, &CmdUnit{}, &Subshell{}, &Group{},
+		&IfNode{}, &LoopNode{}, &ForNode{}, &CaseNode{}, &FuncDef{},
+	} {
+		gob.Register(n)
+	}
+
+	// подоболочка-процесс — та же программа, запущенная заново. Она выполняет команду ещё до main,
+	// поэтому программе, в которую встроен интерпретатор, и тестам с ним ничего делать не нужно
+	if os.Getenv(subshellEnv) != "" && len(os.Args) > 2 && os.Args[1] == subshellFlag {
+		os.Unsetenv(subshellEnv)
+		os.Exit(runSubshell(os.Args[2]))
+	}
+}
+
+// inlineSubshell — выполняет body подоболочкой в самом процессе, на копии интерпретатора, с перенаправлениями
+// redirs. Так без управления заданиями работают ( ) и $( ), и в них есть builtin из Register. Как и у процесса,
+// изменения подоболочки до shell не доходят: ловушки в ней сняты, кроме игнорирования, после неё
+// восстанавливаются umask и сигналы shell, её фоновые задания shell не видит
+func (sh *Interpreter) inlineSubshell(body Node, redirs []Redirect, fds []*os.File) (int, error) {
+	stageFds, opened, err := sh.stageFiles(fds, redirs)
+	defer closeFiles(opened)
+	if err != nil {
+		return 1, err
+	}
+
+	sub := sh.subshell()
+	sub.traps = map[string]string{}
+	for name, action := range sh.traps {
+		if action == "" && name != "EXIT" && name != "ERR" {
+			sub.traps[name] = ""
+		}
+	}
+	ignored := maps.Clone(sub.traps)
+	mask := syscall.Umask(0)
+	syscall.Umask(mask)
+
+	code := sub.runExitTrap(sub.runNode(body, stageFds))
+	if sub.fatalSignal != 0 {
+		code = 128 + int(sub.fatalSignal)
+	}
+
+	syscall.Umask(mask)
+	if sub.signals != nil { // подоболочка ставила ловушки: сигналы процесса снова как у shell
+		sub.restoreTraps(ignored)
+		signal.Stop(sub.signals)
+		sh.updateSignals()
+	}
+	sh.childUsage = sub.childUsage
+	// её фоновые задания ждёт горутина, чтобы они не оставались зомби
+	if jobs := sub.jobs.snapshot(); len(jobs) > 0 {
+		go func() {
+			for _, j := range jobs {
+				waitJob(j)
+			}
+		}()
+	}
+	return code, nil
+}
+
+// startSubshell — запускает node в дочернем shell: тот же исполняемый файл с --subshell N,
+// состояние передаётся через пайп на дескрипторе N после таблицы fds
+func (sh *Interpreter) startSubshell(node Node, fds []*os.File, pgid int, fg bool) (int, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return 0, err
+	}
+
+	vars := map[string]string{}
+	for name, value := range sh.vars {
+		if !sh.exported[name] {
+			vars[name] = value
+		}
+	}
+
+	var payload bytes.Buffer
+	state := &subshellState{
+		Node:       node,
+		Functions:  sh.functions,
+		Vars:       vars,
+		Options:    sh.shellOpts,
+		SetOptions: sh.setOptions,
+		Aliases:    sh.aliases,
+		Positional: sh.positional,
+		ScriptName: sh.scriptName,
+		ShellPid:   sh.shellPid,
+		LastStatus: sh.lastStatus,
+		LastBgPid:  sh.lastBgPid,
+		NoErrexit:  sh.noErrexit > 0,
+		TraceLevel: sh.traceLevel,
+	}
+	for name, action := range sh.traps {
+		if action == "" && name != "EXIT" && name != "ERR" {
+			state.Ignored = append(state.Ignored, name)
+		}
+	}
+	for name := range sh.builtins {
+		state.Registered = append(state.Registered, name)
+	}
+	if err := gob.NewEncoder(&payload).Encode(state); err != nil {
+		return 0, err
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		return 0, err
+	}
+	fds = append(fds[:len(fds):len(fds)], r)
+
+	args := []string{exe, subshellFlag, strconv.Itoa(len(fds) - 1)}
+	pid, err := sh.startProcess(args, fds, sh.environ([]string{subshellEnv + "=1"}), pgid, fg)
+	r.Close()
+	if err != nil {
+		w.Close()
+		return 0, err
+	}
+
+	go func() {
+		_, _ = payload.WriteTo(w) // подоболочка могла упасть не дочитав
+		w.Close()
+	}()
+	return pid, nil
+}
+
+// runSubshell — точка входа подоболочки: читает состояние с дескриптора fdArg и выполняет команду
+func runSubshell(fdArg string) int {
+	n, err := strconv.Atoi(fdArg)
+	if err != nil || n < 3 {
+		fmt.Fprintln(os.Stderr, "subshell: bad descriptor", fdArg)
+		return 2
+	}
+
+	f := os.NewFile(uintptr(n), "subshell")
+	var state subshellState
+	err = gob.NewDecoder(f).Decode(&state)
+	f.Close()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "subshell:", err)
+		return 2
+	}
+
+	sh, err := NewInterpreter("", os.Environ())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "subshell:", err)
+		return 2
+	}
+	sh.positional, sh.scriptName, sh.shellPid = state.Positional, state.ScriptName, state.ShellPid
+	sh.lastStatus, sh.lastBgPid = state.LastStatus, state.LastBgPid
+	if state.NoErrexit {
+		sh.noErrexit = 1
+	}
+	sh.traceLevel = state.TraceLevel
+	if state.Functions != nil {
+		sh.functions = state.Functions
+	}
+	maps.Copy(sh.vars, state.Vars)
+	if state.Options != nil {
+		sh.shellOpts = state.Options
+	}
+	if state.SetOptions != nil {
+		sh.setOptions = state.SetOptions
+	}
+	if state.Aliases != nil {
+		sh.aliases = state.Aliases
+	}
+	for _, name := range state.Registered {
+		sh.Register(name, unavailableBuiltin(name))
+	}
+	if len(state.Ignored) > 0 {
+		for _, name := range state.Ignored {
+			sh.traps[name] = ""
+		}
+		sh.updateSignals()
+	}
+
+	// дескрипторы 3..N-1 от родителя; закрытые в таблице родителя здесь тоже закрыты
+	fds := sh.stdFiles()
+	for fd := 3; fd < n; fd++ {
+		var file *os.File
+		if _, _, errno := syscall.Syscall(syscall.SYS_FCNTL, uintptr(fd), syscall.F_GETFD, 0); errno == 0 {
+			file = os.NewFile(uintptr(fd), "fd"+strconv.Itoa(fd))
+		}
+		fds = append(fds, file)
+	}
+
+	return sh.beforeExit(sh.runNode(state.Node, fds))
+}
+
+// unavailableBuiltin — builtin name из Register в подоболочке-процессе: вместо поиска в PATH — понятная ошибка
+func unavailableBuiltin(name string) BuiltinFunc {
+	return func(*Interpreter, []string, io.Reader, io.Writer) (int, error) {
+		return 127, fmt.Errorf("%s: builtin added with Register is not available in a subshell process "+
+			"(pipeline stage or background job)", name)
+	}
+}
+
+// quoteCommand — раскрытые присваивания и слова команды в виде, который при повторном раскрытии даёт их же
+func quoteCommand(assigns, args []string) []string {
+	words := make([]string, 0, len(assigns)+len(args))
+	for _, a := range assigns {
+		name, value, _ := strings.Cut(a, "=")
+		words = append(words, name+"="+shellQuote(value))
+	}
+	for _, a := range args {
+		words = append(words, shellQuote(a))
+	}
+	return words
+}
@@ -0,0 +1,205 @@
+package minishell
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// substitute — подстановка команды $(...), `...` или арифметическая $((...)) в начале s.
+// n — длина записи, 0 — это не подстановка
+func (sh *Interpreter) substitute(s string) (value string, n int) {
+	if !strings.HasPrefix(s, "$(") && !strings.HasPrefix(s, "`") {
+		return "", 0
+	}
+	end := substEnd(s, 0)
+	if end <= 0 {
+		return "", 0
+	}
+
+	switch {
+	case s[0] == '`':
+		return sh.commandSubst(unescapeBackquote(s[1 : end-1])), end
+
+	// $((...)) — арифметика, если вторая скобка закрывается прямо перед последней; иначе это $( (...) )
+	case strings.HasPrefix(s, "$((") && closing(s, 3, ')') == end-2:
+		return sh.arithSubst(s[3 : end-2]), end
+	}
+	return sh.commandSubst(s[2 : end-1]), end
+}
+
+// commandSubst — выполняет src в подоболочке (без управления заданиями — в самом процессе, см. inlineSubshell)
+// и возвращает её вывод без завершающих переводов строк
+func (sh *Interpreter) commandSubst(src string) string {
+	toks, err := tokenize(src)
+	var list *ListNode
+	if err == nil {
+		list, err = parse(toks, src)
+	}
+	if err != nil {
+		fmt.Fprintln(sh.errOut(), "parse:", err)
+		sh.substStatus = 2
+		return ""
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		sh.substStatus = reportError(1, err, sh.stdFiles())
+		return ""
+	}
+
+	var out bytes.Buffer
+	done := make(chan struct{})
+	go func() {
+		_, _ = io.Copy(&out, r)
+		r.Close()
+		close(done)
+	}()
+
+	fds := sh.stdFiles()
+	fds[1] = w
+	sh.traceLevel++ // трассировка подстановки — с PS4 на уровень глубже
+	var code int
+	if sh.interactive {
+		code, err = sh.runJob([]Node{&Subshell{Body: list}}, fds, false, src)
+	} else {
+		code, err = sh.inlineSubshell(list, nil, fds)
+	}
+	sh.traceLevel--
+	w.Close()
+	<-done
+
+	sh.substStatus = reportError(code, err, fds)
+	return strings.TrimRight(out.String(), "\n")
+}
+
+// arithSubst — значение $((expr)); в выражении сначала раскрываются параметры и подстановки команд
+func (sh *Interpreter) arithSubst(expr string) string {
+	v, err := sh.evalArith(sh.expandWord(expr))
+	if err != nil {
+		if sh.expandErr == nil {
+			sh.expandErr = err
+		}
+		return ""
+	}
+	return fmt.Sprint(v)
+}
+
+// errUnbound — подстановка незаданной переменной при set -u
+var errUnbound = errors.New("unbound variable")
+
+// expansionFailed — ошибка раскрытия слов последней команды и код для неё; сбрасывается.
+// Незаданная переменная при set -u завершает неинтерактивный shell с кодом 127
+func (sh *Interpreter) expansionFailed() (int, error) {
+	err := sh.expandErr
+	sh.expandErr = nil
+	if errors.Is(err, errUnbound) && !sh.interactive {
+		sh.flow = flowExit
+		return 127, err
+	}
+	return 1, err
+}
+
+// unescapeBackquote — текст команды из `...`: \ перед $ ` \ снимается
+func unescapeBackquote(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) && strings.IndexByte("$`\\", s[i+1]) >= 0 {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// substEnd — позиция после подстановки ${...}, $(...), $((...)) или `...`, начинающейся в s[i];
+// 0 — здесь нет подстановки, -1 — она не закрыта
+func substEnd(s string, i int) int {
+	var j int
+	switch {
+	case strings.HasPrefix(s[i:], "${"):
+		j = closing(s, i+2, '}')
+	case strings.HasPrefix(s[i:], "$("):
+		j = closing(s, i+2, ')')
+	case s[i] == '`':
+		j = i + 1
+		for j < len(s) && s[j] != '`' {
+			if s[j] == '\\' {
+				j++
+			}
+			j++
+		}
+		if j >= len(s) {
+			j = -1
+		}
+	default:
+		return 0
+	}
+
+	if j < 0 {
+		return -1
+	}
+	return j + 1
+}
+
+// closing — позиция закрывающего символа close для уже открытой скобки, начиная с i:
+// кавычки и вложенные подстановки пропускаются, вложенные пары скобок считаются. -1 — скобка не закрыта
+func closing(s string, i int, close byte) int {
+	depth := 1
+	for ; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\\':
+			i++
+		case c == '\'':
+			end := strings.IndexByte(s[i+1:], '\'')
+			if end < 0 {
+				return -1
+			}
+			i += end + 1
+		case c == '"':
+			if i = closeDouble(s, i+1); i < 0 {
+				return -1
+			}
+		case c == '$' || c == '`':
+			j := substEnd(s, i)
+			if j < 0 {
+				return -1
+			}
+			if j > 0 {
+				i = j - 1
+			}
+		case c == '(' && close == ')', c == '{' && close == '}':
+			depth++
+		case c == close:
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// closeDouble — позиция закрывающей " для строки, начатой перед i; -1 — не закрыта
+func closeDouble(s string, i int) int {
+	for ; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		case '$', '`':
+			j := substEnd(s, i)
+			if j < 0 {
+				return -1
+			}
+			if j > 0 {
+				i = j - 1
+			}
+		}
+	}
+	return -1
+}
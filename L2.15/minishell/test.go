@@ -0,0 +1,245 @@
+package minishell
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// tester — вычисление выражения test: ! — отрицание, -a и -o — и/или, скобки ( ), унарные проверки
+// файлов и строк, двоичные сравнения строк и чисел
+type tester struct {
+	sh   *Interpreter // относительно его каталога проверяются файлы
+	args []string
+	pos  int
+}
+
+// builtinTest — test выражение и [ выражение ]: 0 — истина, 1 — ложь, 2 — ошибка в выражении
+func (sh *Interpreter) builtinTest(name string, args []string) (int, error) {
+	if name == "[" {
+		if len(args) == 0 || args[len(args)-1] != "]" {
+			return 2, fmt.Errorf("[: missing `]'")
+		}
+		args = args[:len(args)-1]
+	}
+	if len(args) == 0 {
+		return 1, nil
+	}
+
+	t := &tester{sh: sh, args: args}
+	ok, err := t.or()
+	if err == nil && t.pos < len(t.args) {
+		err = fmt.Errorf("%s: too many arguments", t.args[t.pos])
+	}
+	if err != nil {
+		return 2, fmt.Errorf("%s: %w", name, err)
+	}
+	if ok {
+		return 0, nil
+	}
+	return 1, nil
+}
+
+// left — сколько аргументов осталось
+func (t *tester) left() int {
+	return len(t.args) - t.pos
+}
+
+// or — выражение -o выражение
+func (t *tester) or() (bool, error) {
+	v, err := t.and()
+	for err == nil && t.left() > 1 && t.args[t.pos] == "-o" {
+		t.pos++
+		var r bool
+		r, err = t.and()
+		v = v || r
+	}
+	return v, err
+}
+
+// and — выражение -a выражение
+func (t *tester) and() (bool, error) {
+	v, err := t.not()
+	for err == nil && t.left() > 1 && t.args[t.pos] == "-a" {
+		t.pos++
+		var r bool
+		r, err = t.not()
+		v = v && r
+	}
+	return v, err
+}
+
+// not — ! выражение; ! перед двоичным оператором — это строка
+func (t *tester) not() (bool, error) {
+	if t.left() > 1 && t.args[t.pos] == "!" && !(t.left() == 3 && testBinary[t.args[t.pos+1]]) {
+		t.pos++
+		v, err := t.not()
+		return !v, err
+	}
+	return t.primary()
+}
+
+// primary — ( выражение ), двоичная или унарная проверка, непустая строка
+func (t *tester) primary() (bool, error) {
+	if t.left() == 0 {
+		return false, fmt.Errorf("argument expected")
+	}
+	a := t.args[t.pos]
+
+	// двоичный оператор важнее: [ -f = -f ] — сравнение строк
+	if t.left() >= 3 && testBinary[t.args[t.pos+1]] {
+		op, b := t.args[t.pos+1], t.args[t.pos+2]
+		t.pos += 3
+		return t.sh.testBinaryOp(a, op, b)
+	}
+
+	if a == "(" && t.left() >= 3 {
+		t.pos++
+		v, err := t.or()
+		if err != nil {
+			return false, err
+		}
+		if t.left() == 0 || t.args[t.pos] != ")" {
+			return false, fmt.Errorf("`)' expected")
+		}
+		t.pos++
+		return v, nil
+	}
+
+	if len(a) == 2 && a[0] == '-' && strings.IndexByte(testUnary, a[1]) >= 0 && t.left() >= 2 {
+		arg := t.args[t.pos+1]
+		t.pos += 2
+		return t.sh.testUnaryOp(a[1], arg)
+	}
+	if len(a) == 2 && a[0] == '-' && t.left() == 2 {
+		return false, fmt.Errorf("%s: unary operator expected", a)
+	}
+
+	t.pos++
+	return a != "", nil
+}
+
+// testUnary — буквы унарных проверок
+const testUnary = "bcdefghLnprsStuwxzO"
+
+// testBinary — двоичные операторы
+var testBinary = map[string]bool{
+	"=": true, "==": true, "!=": true, "<": true, ">": true,
+	"-eq": true, "-ne": true, "-lt": true, "-le": true, "-gt": true, "-ge": true,
+	"-nt": true, "-ot": true, "-ef": true,
+}
+
+// testUnaryOp — унарная проверка op над arg
+func (sh *Interpreter) testUnaryOp(op byte, arg string) (bool, error) {
+	switch op {
+	case 'z':
+		return arg == "", nil
+	case 'n':
+		return arg != "", nil
+	case 't':
+		fd, err := strconv.Atoi(arg)
+		if err != nil {
+			return false, fmt.Errorf("%s: integer expression expected", arg)
+		}
+		return isTerminal(fd), nil
+	case 'r', 'w', 'x':
+		mode := map[byte]uint32{'r': 4, 'w': 2, 'x': 1}[op]
+		return syscall.Access(sh.abs(arg), mode) == nil, nil
+	case 'L', 'h':
+		fi, err := sh.lstat(arg)
+		return err == nil && fi.Mode()&os.ModeSymlink != 0, nil
+	}
+
+	fi, err := sh.stat(arg)
+	if err != nil {
+		return false, nil
+	}
+	m := fi.Mode()
+	switch op {
+	case 'e':
+		return true, nil
+	case 'f':
+		return m.IsRegular(), nil
+	case 'd':
+		return m.IsDir(), nil
+	case 's':
+		return fi.Size() > 0, nil
+	case 'p':
+		return m&os.ModeNamedPipe != 0, nil
+	case 'S':
+		return m&os.ModeSocket != 0, nil
+	case 'b':
+		return m&os.ModeDevice != 0 && m&os.ModeCharDevice == 0, nil
+	case 'c':
+		return m&os.ModeCharDevice != 0, nil
+	case 'u':
+		return m&os.ModeSetuid != 0, nil
+	case 'g':
+		return m&os.ModeSetgid != 0, nil
+	case 'O':
+		st, ok := fi.Sys().(*syscall.Stat_t)
+		return ok && int(st.Uid) == os.Geteuid(), nil
+	}
+	return false, nil
+}
+
+// testBinaryOp — двоичное сравнение a op b
+func (sh *Interpreter) testBinaryOp(a, op, b string) (bool, error) {
+	switch op {
+	case "=", "==":
+		return a == b, nil
+	case "!=":
+		return a != b, nil
+	case "<":
+		return a < b, nil
+	case ">":
+		return a > b, nil
+
+	case "-nt", "-ot":
+		fa, errA := sh.stat(a)
+		fb, errB := sh.stat(b)
+		if op == "-ot" {
+			fa, fb, errA, errB = fb, fa, errB, errA
+		}
+		// несуществующий файл старше любого
+		return errA == nil && (errB != nil || fa.ModTime().After(fb.ModTime())), nil
+
+	case "-ef":
+		fa, errA := sh.stat(a)
+		fb, errB := sh.stat(b)
+		return errA == nil && errB == nil && os.SameFile(fa, fb), nil
+	}
+
+	x, err := testInt(a)
+	if err != nil {
+		return false, err
+	}
+	y, err := testInt(b)
+	if err != nil {
+		return false, err
+	}
+	switch op {
+	case "-eq":
+		return x == y, nil
+	case "-ne":
+		return x != y, nil
+	case "-lt":
+		return x < y, nil
+	case "-le":
+		return x <= y, nil
+	case "-gt":
+		return x > y, nil
+	}
+	return x >= y, nil
+}
+
+// testInt — целое для -eq и других сравнений; пробелы вокруг допускаются
+func testInt(s string) (int64, error) {
+	v, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s: integer expression expected", s)
+	}
+	return v, nil
+}
@@ -0,0 +1,142 @@
+package minishell
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// defaultTimeFormat — формат time, когда TIMEFORMAT не задана: как в bash, и ещё пиковая память
+const defaultTimeFormat = "\nreal\t%3lR\nuser\t%3lU\nsys\t%3lS\nmaxrss\t%M KB"
+
+// usage — процессорное время и пиковая память
+type usage struct {
+	user, sys time.Duration
+	maxRSS    int64 // КБ
+	procs     int   // сколько процессов учтено
+}
+
+// add — добавляет ресурсы процесса ru: время складывается, память — наибольшая
+func (u *usage) add(ru *syscall.Rusage) {
+	u.user += time.Duration(ru.Utime.Nano())
+	u.sys += time.Duration(ru.Stime.Nano())
+	u.maxRSS = max(u.maxRSS, ru.Maxrss)
+	u.procs++
+}
+
+// selfUsage — ресурсы самого процесса shell: на него приходятся builtin и функции
+func selfUsage() usage {
+	var ru syscall.Rusage
+	_ = syscall.Getrusage(syscall.RUSAGE_SELF, &ru)
+	var u usage
+	u.add(&ru)
+	return u
+}
+
+// startTiming — начинает замер конвейера time; report печатает затраченное время в stderr конвейера (fds[2]).
+// user и sys — время стадий-процессов по wait4 (со всеми их потомками) и время самого shell, общее для всех
+// его горутин. maxrss — наибольший RSS среди процессов, без процессов — RSS самого shell
+func (sh *Interpreter) startTiming(pipe *PipelineNode, fds []*os.File) (report func()) {
+	// пиковая память считается заново для этого замера, потом снова становится общей
+	start, self, children := time.Now(), selfUsage(), sh.childUsage
+	sh.childUsage.maxRSS = 0
+
+	return func() {
+		elapsed, now, after := time.Since(start), selfUsage(), sh.childUsage
+		sh.childUsage.maxRSS = max(children.maxRSS, after.maxRSS)
+		if sh.flow == flowExit { // exit в замеряемой команде, как в bash, без отчёта
+			return
+		}
+
+		used := usage{
+			user:   now.user - self.user + after.user - children.user,
+			sys:    now.sys - self.sys + after.sys - children.sys,
+			maxRSS: after.maxRSS,
+		}
+		if after.procs == children.procs {
+			used.maxRSS = now.maxRSS
+		}
+
+		format, ok := sh.lookupVar("TIMEFORMAT")
+		switch {
+		case pipe.TimePosix:
+			format = "real %2R\nuser %2U\nsys %2S"
+		case !ok:
+			format = defaultTimeFormat
+		case format == "":
+			return
+		}
+		if errOut := fdFile(fds, 2); errOut != nil {
+			errOut.WriteString(formatTimes(format, elapsed, used) + "\n")
+		}
+	}
+}
+
+// formatTimes — отчёт time по формату TIMEFORMAT: %[p][l]R, %[p][l]U, %[p][l]S — реальное, пользовательское
+// и системное время в секундах с p знаками после точки (по умолчанию 3), с l — в виде 1m2.345s;
+// %P — загрузка процессора в процентах, %M — пиковая память в КБ, %% — сам %
+func formatTimes(format string, elapsed time.Duration, u usage) string {
+	var b strings.Builder
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' || i+1 == len(format) {
+			b.WriteByte(format[i])
+			continue
+		}
+
+		j := i + 1
+		prec, long := 3, false
+		if c := format[j]; c >= '0' && c <= '9' {
+			prec = min(int(c-'0'), 3)
+			j++
+		}
+		if j < len(format) && format[j] == 'l' {
+			long = true
+			j++
+		}
+		if j == len(format) {
+			b.WriteString(format[i:])
+			break
+		}
+
+		var d time.Duration
+		switch format[j] {
+		case 'R':
+			d = elapsed
+		case 'U':
+			d = u.user
+		case 'S':
+			d = u.sys
+		case 'P':
+			pct := 0.0
+			if elapsed > 0 {
+				pct = float64(u.user+u.sys) / float64(elapsed) * 100
+			}
+			fmt.Fprintf(&b, "%.2f", pct)
+			i = j
+			continue
+		case 'M':
+			fmt.Fprint(&b, u.maxRSS)
+			i = j
+			continue
+		case '%':
+			b.WriteByte('%')
+			i = j
+			continue
+		default: // неизвестный символ формата выводится как есть
+			b.WriteString(format[i : j+1])
+			i = j
+			continue
+		}
+
+		if long {
+			m := d / time.Minute
+			fmt.Fprintf(&b, "%dm%.*fs", m, prec, (d - m*time.Minute).Seconds())
+		} else {
+			fmt.Fprintf(&b, "%.*f", prec, d.Seconds())
+		}
+		i = j
+	}
+	return b.String()
+}
@@ -0,0 +1,311 @@
+package minishell
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// trapName — условие ловушки по спецификации: EXIT или 0, ERR, сигнал по имени или номеру (INT, SIGINT, 2).
+// sig — 0 для EXIT и ERR
+func trapName(spec string) (name string, sig syscall.Signal, err error) {
+	switch strings.ToUpper(spec) {
+	case "EXIT", "0":
+		return "EXIT", 0, nil
+	case "ERR":
+		return "ERR", 0, nil
+	}
+	sig, err = parseSignal(spec)
+	if err != nil {
+		return "", 0, err
+	}
+	name = signalName(sig)
+	if name == strconv.Itoa(int(sig)) {
+		return "", 0, fmt.Errorf("%s: invalid signal specification", spec)
+	}
+	return name, sig, nil
+}
+
+// trapLabel — условие в выводе trap: сигналы с SIG, как в bash
+func trapLabel(name string) string {
+	if name == "EXIT" || name == "ERR" {
+		return name
+	}
+	return "SIG" + name
+}
+
+// builtinTrap — trap [-lp] [[действие] условие...]: действие выполняется при сигнале, перед выходом shell (EXIT)
+// или после неуспешной команды (ERR). Пустое действие — сигнал игнорируется, в том числе командами shell;
+// - или одно условие без действия — ловушка снимается. Без аргументов и с -p печатает ловушки, -l — сигналы
+func (sh *Interpreter) builtinTrap(args []string, out io.Writer) (int, error) {
+	if len(args) > 0 {
+		switch args[0] {
+		case "-l":
+			return listSignals(nil, out)
+		case "-p":
+			return sh.printTraps(args[1:], out)
+		case "--":
+			args = args[1:]
+		}
+	}
+	if len(args) == 0 {
+		return sh.printTraps(nil, out)
+	}
+
+	action, conds := args[0], args[1:]
+	reset := action == "-"
+	if len(args) == 1 {
+		reset, conds = true, args
+	}
+
+	var errs error
+	for _, spec := range conds {
+		name, sig, err := trapName(spec)
+		if err != nil {
+			errs = errors.Join(errs, fmt.Errorf("trap: %w", err))
+			continue
+		}
+		// игнорирование снимается только сбросом: Notify для канала shell его не отменяет
+		if old, ok := sh.traps[name]; ok && old == "" && sig != 0 && (reset || action != "") {
+			resetSignal(sig)
+		}
+		if reset {
+			delete(sh.traps, name)
+		} else {
+			sh.traps[name] = action
+		}
+	}
+	sh.updateSignals()
+	if errs != nil {
+		return 1, errs
+	}
+	return 0, nil
+}
+
+// printTraps — ловушки в виде команд trap: заданные для условий specs, без specs — все, в порядке номеров сигналов
+func (sh *Interpreter) printTraps(specs []string, out io.Writer) (int, error) {
+	var names []string
+	var errs error
+	if len(specs) == 0 {
+		names = append(names, "EXIT")
+		for _, s := range signalNames {
+			names = append(names, s.name)
+		}
+		names = append(names, "ERR")
+	}
+	for _, spec := range specs {
+		name, _, err := trapName(spec)
+		if err != nil {
+			errs = errors.Join(errs, fmt.Errorf("trap: %w", err))
+			continue
+		}
+		names = append(names, name)
+	}
+
+	for _, name := range names {
+		if action, ok := sh.traps[name]; ok {
+			fmt.Fprintf(out, "trap -- '%s' %s\n", strings.ReplaceAll(action, "'", `'\''`), trapLabel(name))
+		}
+	}
+	if errs != nil {
+		return 1, errs
+	}
+	return 0, nil
+}
+
+// resetSignal — возвращает сигналу действие по умолчанию. signal.Reset не снимает signal.Ignore с сигналов,
+// которые Go обрабатывает сам, — сначала Notify ставит обработчик Go, и сброс оставляет действие по умолчанию
+func resetSignal(sig syscall.Signal) {
+	signal.Notify(make(chan os.Signal, 1), sig)
+	signal.Reset(sig)
+}
+
+// restoreTraps — возвращает ловушки traps, которые были до Run: сигналы, которые Run начал игнорировать,
+// снова получают действие по умолчанию, перехват ловушек Run снимается
+func (sh *Interpreter) restoreTraps(traps map[string]string) {
+	for name, action := range sh.traps {
+		if old, ok := traps[name]; action != "" || ok && old == "" {
+			continue
+		}
+		if _, sig, err := trapName(name); err == nil && sig != 0 {
+			resetSignal(sig)
+		}
+	}
+	sh.traps = traps
+	sh.updateSignals()
+}
+
+// exitSignals — сигналы, которые без ловушки завершают shell; при ловушке EXIT они перехватываются,
+// чтобы перед смертью выполнить её
+var exitSignals = []syscall.Signal{syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM}
+
+// catchSignals — сигналы, которые shell перехватывает сам, без ловушек: без ловушки они не действуют,
+// кроме SIGHUP — он завершает shell
+func (sh *Interpreter) catchSignals(sigs ...syscall.Signal) {
+	sh.ownSignals = sigs
+	sh.updateSignals()
+}
+
+// updateSignals — настраивает сигналы процесса по ловушкам: с пустым действием сигнал игнорируется,
+// с ловушкой, свой сигнал shell и сигнал завершения при ловушке EXIT перехватываются в канал signals.
+// Канал каждый раз новый: у прежнего нельзя отписать отдельные сигналы, а без подписки сигнал
+// на мгновение получил бы действие по умолчанию
+func (sh *Interpreter) updateSignals() {
+	var caught []os.Signal
+	for _, s := range signalNames {
+		if s.sig == syscall.SIGKILL || s.sig == syscall.SIGSTOP {
+			continue
+		}
+		switch action, trapped := sh.traps[s.name]; {
+		case trapped && action == "":
+			signal.Ignore(s.sig)
+		case sh.catches(s.sig):
+			caught = append(caught, s.sig)
+		}
+	}
+
+	ch := make(chan os.Signal, len(signalNames))
+	if len(caught) > 0 {
+		signal.Notify(ch, caught...)
+	}
+	if sh.signals != nil {
+		signal.Stop(sh.signals)
+		// ещё не обработанные сигналы переходят в новый канал
+		for len(sh.signals) > 0 {
+			select {
+			case ch <- <-sh.signals:
+			default:
+			}
+		}
+	}
+	sh.signals = ch
+}
+
+// catches — сигнал sig перехватывается в канал signals: у него есть ловушка, это свой сигнал shell
+// или сигнал завершения при ловушке EXIT
+func (sh *Interpreter) catches(sig syscall.Signal) bool {
+	action, trapped := sh.traps[signalName(sig)]
+	_, exitTrap := sh.traps["EXIT"]
+	if trapped {
+		return action != ""
+	}
+	return slices.Contains(sh.ownSignals, sig) || exitTrap && slices.Contains(exitSignals, sig)
+}
+
+// handleSignals — обрабатывает пришедшие сигналы; вызывается между командами
+func (sh *Interpreter) handleSignals() {
+	for {
+		select {
+		case s := <-sh.signals:
+			sh.handleSignal(s.(syscall.Signal))
+		default:
+			return
+		}
+	}
+}
+
+// awaitSignal — дожидается сигнала sig, который shell послал себе сам (kill $$), и обрабатывает его
+// вместе с пришедшими раньше. Go доставляет сигнал в канал не сразу, а ловушка, как в bash, должна
+// выполниться до следующей команды
+func (sh *Interpreter) awaitSignal(sig syscall.Signal) {
+	timeout := time.After(time.Second) // на случай, если сигнал не дошёл: канал был переполнен
+	for {
+		select {
+		case s := <-sh.signals:
+			sh.handleSignal(s.(syscall.Signal))
+			if s == sig || sh.flow == flowExit {
+				return
+			}
+		case <-timeout:
+			return
+		}
+	}
+}
+
+// handleSignal — выполняет ловушку сигнала. Свой сигнал shell без ловушки пропускается, остальные
+// (SIGHUP, сигнал завершения при ловушке EXIT) завершают shell: ловушку EXIT и выход выполняет beforeExit
+func (sh *Interpreter) handleSignal(sig syscall.Signal) {
+	if action, ok := sh.traps[signalName(sig)]; ok {
+		if action != "" {
+			sh.runTrap(action)
+		}
+		return
+	}
+	if slices.Contains(sh.ownSignals, sig) && sig != syscall.SIGHUP {
+		return
+	}
+	sh.fatalSignal, sh.flow = sig, flowExit
+	sh.lastStatus = 128 + int(sig)
+}
+
+// runTrap — выполняет действие ловушки. $? в нём — код последней команды, после него $? восстанавливается;
+// exit в ловушке завершает shell
+func (sh *Interpreter) runTrap(action string) {
+	status := sh.lastStatus
+	sh.trapDepth++
+	_, err := sh.runLines(&fileLines{bufio.NewReader(strings.NewReader(action))}, false)
+	sh.trapDepth--
+	if err != nil {
+		fmt.Fprintln(sh.errOut(), err)
+	}
+	if sh.flow != flowExit {
+		sh.lastStatus = status
+	}
+}
+
+// runErrTrap — ловушка ERR после неуспешной команды; как в bash без set -E, в функциях
+// и в других ловушках не действует
+func (sh *Interpreter) runErrTrap() {
+	if action, ok := sh.traps["ERR"]; ok && action != "" && sh.funcDepth == 0 && sh.trapDepth == 0 {
+		sh.runTrap(action)
+	}
+}
+
+// runExitTrap — ловушка EXIT при выходе shell с кодом code; выполняется один раз, после ловушек сигналов,
+// которые пришли, но ещё не обработаны. exit в ней меняет код выхода
+func (sh *Interpreter) runExitTrap(code int) int {
+	sh.handleSignals()
+	if sh.fatalSignal != 0 {
+		code = 128 + int(sh.fatalSignal)
+	}
+
+	action, ok := sh.traps["EXIT"]
+	if !ok {
+		return code
+	}
+	delete(sh.traps, "EXIT")
+	sh.updateSignals()
+	if action == "" {
+		return code
+	}
+
+	sh.flow, sh.lastStatus = flowNone, code
+	sh.runTrap(action)
+	if sh.flow == flowExit {
+		return sh.lastStatus
+	}
+	return code
+}
+
+// beforeExit — выход процесса shell с кодом code: ловушка EXIT, SIGHUP заданиям интерактивного shell.
+// Shell, который завершает сигнал, умирает от этого же сигнала. Возвращает код выхода
+func (sh *Interpreter) beforeExit(code int) int {
+	code = sh.runExitTrap(code)
+	if sh.interactive {
+		sh.hangupJobs()
+	}
+	if sig := sh.fatalSignal; sig != 0 {
+		signal.Reset(sig)
+		_ = raise(sig) // процесс умирает здесь же
+		return 128 + int(sig)
+	}
+	return code
+}
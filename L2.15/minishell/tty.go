@@ -0,0 +1,92 @@
+package minishell
+
+import (
+	"runtime"
+	"syscall"
+	"unsafe"
+)
+
+// Состояние терминала для управления заданиями
+var (
+	ttyFd     = 0 // дескриптор управляющего терминала
+	shellPgid int // группа процессов shell
+)
+
+// isTerminal — является ли дескриптор терминалом
+func isTerminal(fd int) bool {
+	var t syscall.Termios
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), syscall.TCGETS, uintptr(unsafe.Pointer(&t)))
+	return errno == 0
+}
+
+// initJobControl — если stdin терминал, забираем его себе; false — stdin не терминал и управления заданиями нет.
+// SIGTTOU на время смены группы терминала блокирует tcsetpgrp, остальные сигналы перехватывает main
+func initJobControl() bool {
+	if !isTerminal(ttyFd) {
+		return false
+	}
+
+	_ = syscall.Setpgid(0, 0) // у лидера сессии не получится, тогда он уже лидер своей группы
+	shellPgid = syscall.Getpgrp()
+	_ = tcsetpgrp(ttyFd, shellPgid)
+	return true
+}
+
+// tcsetpgrp делает группу pgid активной на терминале.
+// SIGTTOU блокируется на время вызова, иначе ядро будет перезапускать ioctl из фоновой группы
+func tcsetpgrp(fd, pgid int) error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	const sigBlock, sigSetmask = 0, 2
+	set := uint64(1) << (uint(syscall.SIGTTOU) - 1)
+	var old uint64
+	syscall.RawSyscall6(syscall.SYS_RT_SIGPROCMASK, sigBlock,
+		uintptr(unsafe.Pointer(&set)), uintptr(unsafe.Pointer(&old)), 8, 0, 0)
+	defer syscall.RawSyscall6(syscall.SYS_RT_SIGPROCMASK, sigSetmask,
+		uintptr(unsafe.Pointer(&old)), 0, 8, 0, 0)
+
+	p := int32(pgid)
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), syscall.TIOCSPGRP, uintptr(unsafe.Pointer(&p)))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// makeRaw — переводит терминал в посимвольный режим без эха и сигналов от клавиш;
+// возвращает прежние настройки для restoreTerminal. Непрочитанный ввод не сбрасывается
+func makeRaw(fd int) (*syscall.Termios, error) {
+	var old syscall.Termios
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), syscall.TCGETS, uintptr(unsafe.Pointer(&old))); errno != 0 {
+		return nil, errno
+	}
+
+	raw := old
+	raw.Iflag &^= syscall.ICRNL | syscall.IXON | syscall.INLCR | syscall.IGNCR
+	raw.Lflag &^= syscall.ICANON | syscall.ECHO | syscall.ISIG | syscall.IEXTEN
+	raw.Cc[syscall.VMIN] = 1
+	raw.Cc[syscall.VTIME] = 0
+	if err := restoreTerminal(fd, &raw); err != nil {
+		return nil, err
+	}
+	return &old, nil
+}
+
+// restoreTerminal — устанавливает настройки терминала t
+func restoreTerminal(fd int, t *syscall.Termios) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), syscall.TCSETS, uintptr(unsafe.Pointer(t))); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// terminalWidth — число колонок терминала; 80, если узнать не удалось
+func terminalWidth(fd int) int {
+	var ws struct{ Row, Col, X, Y uint16 }
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), syscall.TIOCGWINSZ, uintptr(unsafe.Pointer(&ws)))
+	if errno != 0 || ws.Col == 0 {
+		return 80
+	}
+	return int(ws.Col)
+}
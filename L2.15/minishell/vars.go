@@ -0,0 +1,310 @@
+package minishell
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// defaultSetOptions — настройки set -o нового интерпретатора
+func defaultSetOptions() map[string]bool {
+	return map[string]bool{
+		"pipefail": false, // код конвейера — код последней неуспешной стадии, а не последней стадии
+		"errexit":  false, // set -e: неуспешная команда завершает shell
+		"noexec":   false, // set -n: команды только разбираются, но не выполняются
+		"nounset":  false, // set -u: подстановка незаданной переменной — ошибка
+		"xtrace":   false, // set -x: команды перед выполнением печатаются в stderr
+	}
+}
+
+// lookupVar — значение переменной, позиционного или специального параметра; ok — параметр задан
+func (sh *Interpreter) lookupVar(name string) (value string, ok bool) {
+	switch name {
+	case "?":
+		return strconv.Itoa(sh.lastStatus), true
+	case "$":
+		return strconv.Itoa(sh.shellPid), true
+	case "!":
+		if sh.lastBgPid == 0 {
+			return "", false
+		}
+		return strconv.Itoa(sh.lastBgPid), true
+	case "#":
+		return strconv.Itoa(len(sh.positional)), true
+	case "-":
+		return sh.flagsParam(), true
+	case "0":
+		return sh.scriptName, true
+	case "@", "*":
+		return strings.Join(sh.positional, " "), len(sh.positional) > 0
+	case "PIPESTATUS":
+		return strings.Join(sh.arrayValues(name), " "), true
+	}
+
+	// NAME[индекс], NAME[@] — элемент массива или все элементы через пробел
+	if base, sub, ok := strings.Cut(name, "["); ok && strings.HasSuffix(sub, "]") {
+		return sh.arrayElement(base, strings.TrimSuffix(sub, "]"))
+	}
+
+	if isNumber(name) {
+		n, _ := strconv.Atoi(name)
+		if n >= 1 && n <= len(sh.positional) {
+			return sh.positional[n-1], true
+		}
+		return "", false
+	}
+
+	v, ok := sh.vars[name]
+	return v, ok
+}
+
+// arrayValues — элементы массива name. Массив только PIPESTATUS; обычная переменная — массив из одного элемента
+func (sh *Interpreter) arrayValues(name string) []string {
+	if name == "PIPESTATUS" {
+		values := make([]string, len(sh.pipeStatus))
+		for i, code := range sh.pipeStatus {
+			values[i] = strconv.Itoa(code)
+		}
+		return values
+	}
+	if v, ok := sh.lookupVar(name); ok {
+		return []string{v}
+	}
+	return nil
+}
+
+// arrayElement — элемент sub массива name: номер с 0 (отрицательный — с конца) или @ и * — все через пробел
+func (sh *Interpreter) arrayElement(name, sub string) (string, bool) {
+	values := sh.arrayValues(name)
+	if sub == "@" || sub == "*" {
+		return strings.Join(values, " "), len(values) > 0
+	}
+
+	i, err := sh.evalArith(sub)
+	if err != nil {
+		return "", false
+	}
+	if i < 0 {
+		i += int64(len(values))
+	}
+	if i < 0 || i >= int64(len(values)) {
+		return "", false
+	}
+	return values[i], true
+}
+
+// setVar — присваивание; экспортированная переменная остаётся в окружении
+func (sh *Interpreter) setVar(name, value string) {
+	sh.vars[name] = value
+}
+
+// exportVar — переносит переменную в окружение; незаданная экспортируется пустой
+func (sh *Interpreter) exportVar(name string) {
+	sh.vars[name] = sh.vars[name]
+	sh.exported[name] = true
+}
+
+// unsetVar — удаляет переменную
+func (sh *Interpreter) unsetVar(name string) {
+	delete(sh.vars, name)
+	delete(sh.exported, name)
+}
+
+// splitAssignments — отделяет присваивания NAME=value в начале команды от её слов.
+// Значения раскрываются без разбиения на поля, результат — строки NAME=value
+func (sh *Interpreter) splitAssignments(words []string) (assigns, rest []string) {
+	for i, w := range words {
+		name, value, ok := strings.Cut(w, "=")
+		if !ok || !isName(name) {
+			return assigns, words[i:]
+		}
+		assigns = append(assigns, name+"="+sh.expandAssignment(value))
+	}
+	return assigns, nil
+}
+
+// assignVars — присваивания без команды: переменные остаются в shell
+func (sh *Interpreter) assignVars(assigns []string) {
+	for _, a := range assigns {
+		name, value, _ := strings.Cut(a, "=")
+		sh.setVar(name, value)
+	}
+}
+
+// environ — окружение для внешней команды с присваиваниями FOO=1 перед ней: экспортированные переменные
+// по возрастанию имён, затем присваивания
+func (sh *Interpreter) environ(assigns []string) []string {
+	override := map[string]bool{}
+	for _, a := range assigns {
+		name, _, _ := strings.Cut(a, "=")
+		override[name] = true
+	}
+
+	var env []string
+	for _, name := range slices.Sorted(maps.Keys(sh.exported)) {
+		if !override[name] {
+			env = append(env, name+"="+sh.vars[name])
+		}
+	}
+	return append(env, assigns...)
+}
+
+// withAssignments — выполняет builtin или функцию с присваиваниями FOO=1 перед ней:
+// на время выполнения переменные экспортируются, затем прежние значения возвращаются
+func (sh *Interpreter) withAssignments(assigns []string, fn func() (int, error)) (int, error) {
+	type saved struct {
+		name, value   string
+		set, exported bool
+	}
+	var old []saved
+
+	for _, a := range assigns {
+		name, value, _ := strings.Cut(a, "=")
+		v, set := sh.vars[name]
+		old = append(old, saved{name, v, set, sh.exported[name]})
+
+		sh.vars[name], sh.exported[name] = value, true
+	}
+
+	defer func() {
+		for i := len(old) - 1; i >= 0; i-- {
+			s := old[i]
+			sh.unsetVar(s.name)
+			if s.set {
+				sh.vars[s.name] = s.value
+			}
+			if s.exported {
+				sh.exported[s.name] = true
+			}
+		}
+	}()
+
+	return fn()
+}
+
+// shellQuote — слово в кавычках, если в нём есть что раскрывать; при раскрытии даёт s
+func shellQuote(s string) string {
+	safe := s != ""
+	for i := 0; i < len(s) && safe; i++ {
+		safe = isAlnum(s[i]) || strings.IndexByte("_-./:=@%+,", s[i]) >= 0
+	}
+	if safe {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// builtinExport — export NAME[=value]...; без аргументов или с -p печатает экспортированные переменные
+func (sh *Interpreter) builtinExport(args []string, out io.Writer) (int, error) {
+	if len(args) == 0 || len(args) == 1 && args[0] == "-p" {
+		for _, kv := range sh.environ(nil) {
+			name, value, _ := strings.Cut(kv, "=")
+			fmt.Fprintf(out, "export %s=%s\n", name, shellQuote(value))
+		}
+		return 0, nil
+	}
+
+	var err error
+	for _, a := range args {
+		name, value, hasValue := strings.Cut(a, "=")
+		if !isName(name) {
+			err = fmt.Errorf("export: `%s': not a valid identifier", a)
+			continue
+		}
+		if hasValue {
+			sh.setVar(name, value)
+		}
+		sh.exportVar(name)
+	}
+	if err != nil {
+		return 1, err
+	}
+	return 0, nil
+}
+
+// builtinUnset — unset [-v] NAME... удаляет переменные, unset -f NAME... — функции
+func (sh *Interpreter) builtinUnset(args []string) (int, error) {
+	funcs := false
+	if len(args) > 0 && (args[0] == "-f" || args[0] == "-v") {
+		funcs = args[0] == "-f"
+		args = args[1:]
+	}
+
+	for _, name := range args {
+		if funcs {
+			delete(sh.functions, name)
+			continue
+		}
+		if !isName(name) {
+			return 1, fmt.Errorf("unset: `%s': not a valid identifier", name)
+		}
+		sh.unsetVar(name)
+	}
+	return 0, nil
+}
+
+// builtinSet — без аргументов печатает все переменные, set [--] args... задаёт позиционные параметры
+func (sh *Interpreter) builtinSet(args []string, out io.Writer) (int, error) {
+	if len(args) == 0 {
+		for _, name := range slices.Sorted(maps.Keys(sh.vars)) {
+			fmt.Fprintf(out, "%s=%s\n", name, shellQuote(sh.vars[name]))
+		}
+		return 0, nil
+	}
+
+	// -флаги включают настройки, +флаги выключают: set -eux, set +x. -o имя и +o имя — настройка по имени,
+	// без имени — вывод всех; флаги и o можно совмещать: set -euo pipefail. -- — дальше только параметры
+	setArgs := false
+	for len(args) > 0 {
+		opt := args[0]
+		if opt == "--" {
+			args, setArgs = args[1:], true
+			break
+		}
+		if len(opt) < 2 || opt[0] != '-' && opt[0] != '+' {
+			break
+		}
+
+		rest, list, err := sh.applyFlags(opt, args[1:])
+		if err != nil {
+			return 2, fmt.Errorf("set: %w", err)
+		}
+		if list {
+			sh.printSetOptions(out, opt[0] == '-')
+			return 0, nil
+		}
+		args = rest
+	}
+
+	if len(args) > 0 || setArgs {
+		sh.positional = append([]string(nil), args...)
+	}
+	return 0, nil
+}
+
+// printSetOptions — настройки set -o: таблицей (set -o) или командами, которые их восстановят (set +o)
+func (sh *Interpreter) printSetOptions(out io.Writer, table bool) {
+	names := make([]string, 0, len(sh.setOptions))
+	for name := range sh.setOptions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		on := sh.setOptions[name]
+		switch {
+		case table && on:
+			fmt.Fprintf(out, "%-15s\ton\n", name)
+		case table:
+			fmt.Fprintf(out, "%-15s\toff\n", name)
+		case on:
+			fmt.Fprintf(out, "set -o %s\n", name)
+		default:
+			fmt.Fprintf(out, "set +o %s\n", name)
+		}
+	}
+}